@@ -1,16 +1,23 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/calvinwijaya/card-games-be/internal/api"
 	"github.com/calvinwijaya/card-games-be/internal/db"
+	"github.com/calvinwijaya/card-games-be/internal/game"
+	"github.com/calvinwijaya/card-games-be/internal/metrics"
 	"github.com/calvinwijaya/card-games-be/internal/store"
 	"github.com/gorilla/mux"
 	"github.com/rs/cors"
@@ -20,10 +27,46 @@ func main() {
 	// Parse command line flags
 	var (
 		port        = flag.String("port", "8080", "Server port")
-		frontendURL = flag.String("frontend", "http://localhost:5173", "Frontend URL for CORS")
+		frontendURL = flag.String("frontend", "http://localhost:5173", "Comma-separated frontend URLs allowed by CORS")
+		corsAll     = flag.Bool("cors-allow-all", false, "Allow CORS requests from any origin (local development only)")
+		wsRate      = flag.Float64("ws-rate", 10, "Maximum inbound WebSocket messages per second per client")
+		wsBurst     = flag.Int("ws-burst", 20, "Maximum inbound WebSocket message burst per client")
+		actionRate  = flag.Float64("action-rate", api.DefaultActionRate, "Maximum game actions per second per player over HTTP (0 for no limit)")
+		actionBurst = flag.Int("action-burst", api.DefaultActionBurst, "Maximum game action burst per player over HTTP")
+		wsMaxConns  = flag.Int("ws-max-conns", 1000, "Maximum concurrent WebSocket connections (0 for no limit)")
+		seedTables  = flag.Int("seed-tables", 0, "Number of default tables to create on startup if they don't exist")
+		snapshot    = flag.Bool("snapshot", false, "Record every game state change in game_snapshots (debugging only)")
+		debug       = flag.Bool("debug", false, "Serve raw game state, deck order included, on /api/game/{id}/debug (never in production)")
+		adminToken  = flag.String("admin-token", os.Getenv("ADMIN_TOKEN"), "Bearer token for admin endpoints (disabled if empty)")
+		logLevel    = flag.String("log-level", "info", "Minimum level for structured logs (debug, info, warn or error)")
+		staleAge    = flag.Duration("stale-game-age", 24*time.Hour, "Delete waiting and completed games not updated for this long")
+		cleanup     = flag.Duration("cleanup-interval", time.Hour, "How often to delete stale games (0 to disable)")
+		redisAddr   = flag.String("redis", os.Getenv("REDIS_ADDR"), "Redis address for sharing games between server instances (games are kept in the database if empty)")
+		startingBal = flag.Int("starting-balance", envInt("STARTING_BALANCE", api.DefaultStartingBalance), "Balance new players start with")
+		drainTime   = flag.Duration("shutdown-timeout", 15*time.Second, "How long to wait for in-flight requests to finish when shutting down")
 	)
 	flag.Parse()
 
+	if *startingBal < 0 {
+		log.Fatalf("Invalid starting balance %d: must not be negative", *startingBal)
+	}
+
+	// Browsers may only call the API and open WebSockets from these origins
+	origins := parseOrigins(*frontendURL)
+	if *corsAll {
+		log.Println("CORS allows every origin, without credentials")
+	} else if len(origins) == 0 {
+		log.Fatalf("No CORS origins given: set -frontend or -cors-allow-all")
+	}
+
+	// Set up structured logging; debug output is opt-in
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(*logLevel)); err != nil {
+		log.Fatalf("Invalid log level %q: %v", *logLevel, err)
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+	game.SetLogger(logger)
+
 	// Initialize the database
 	database, err := db.NewDatabase()
 	if err != nil {
@@ -32,38 +75,94 @@ func main() {
 	defer database.Close()
 	log.Println("Database initialized successfully")
 
+	if *snapshot {
+		database.EnableSnapshots()
+		log.Println("Game state snapshots enabled")
+	}
+
 	// Initialize the store
-	gameStore := store.NewDatabaseStore(database)
-	log.Println("Database game store initialized")
+	var gameStore store.Store
+	if *redisAddr != "" {
+		redisStore, err := store.NewRedisStore(*redisAddr)
+		if err != nil {
+			log.Fatalf("Failed to connect to Redis: %v", err)
+		}
+		defer redisStore.Close()
+		gameStore = redisStore
+		log.Printf("Redis game store initialized at %s", *redisAddr)
+	} else {
+		gameStore = store.NewDatabaseStore(database)
+		log.Println("Database game store initialized")
+	}
+
+	// Create the default lobby tables
+	if *seedTables > 0 {
+		created, err := seedDefaultTables(gameStore, *seedTables)
+		if err != nil {
+			log.Fatalf("Failed to seed tables: %v", err)
+		}
+		log.Printf("Seeded %d default tables", created)
+	}
+
+	// Clean up abandoned games
+	if *cleanup > 0 {
+		go deleteStaleGames(gameStore, *cleanup, *staleAge)
+	}
 
 	// Initialize WebSocket hub
 	hub := api.NewHub()
+	hub.SetMessageRateLimit(*wsRate, *wsBurst)
+	hub.SetMaxClients(*wsMaxConns)
+	if !*corsAll {
+		hub.SetAllowedOrigins(origins)
+	}
 	go hub.Run()
 	log.Println("WebSocket hub started")
 
 	// Initialize API handlers
 	handlers := api.NewHandlers(gameStore, database, hub)
+	handlers.SetAdminToken(*adminToken)
+	handlers.SetLogger(logger)
+	handlers.SetActionRateLimit(*actionRate, *actionBurst)
+	handlers.SetStartingBalance(*startingBal)
+	if *debug {
+		handlers.SetDebug(true)
+		log.Println("Debug endpoint enabled; don't run this in production")
+	}
+
+	// Advance tables that open betting and deal by themselves
+	go handlers.RunTableSweeper(api.DefaultSweepInterval)
 
 	// Set up router
 	r := mux.NewRouter()
 	handlers.RegisterRoutes(r)
 
-	// Add middleware for logging
+	// Return structured money amounts to clients that ask for them
+	r.Use(api.MoneyFormatMiddleware)
+
+	// Add middleware for logging and request metrics
 	r.Use(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			start := time.Now()
-			next.ServeHTTP(w, r)
-			log.Printf("%s %s %s", r.Method, r.RequestURI, time.Since(start))
+
+			// WebSocket upgrades need the real connection, so don't wrap them
+			if r.Header.Get("Upgrade") != "" {
+				next.ServeHTTP(w, r)
+				log.Printf("%s %s %s", r.Method, r.RequestURI, time.Since(start))
+				return
+			}
+
+			rec := metrics.NewStatusRecorder(w)
+			next.ServeHTTP(rec, r)
+			elapsed := time.Since(start)
+
+			metrics.ObserveRequest(metrics.RouteName(r), r.Method, rec.Status, elapsed)
+			log.Printf("%s %s %d %s", r.Method, r.RequestURI, rec.Status, elapsed)
 		})
 	})
 
 	// Configure CORS
-	c := cors.New(cors.Options{
-		AllowedOrigins:   []string{*frontendURL},
-		AllowedMethods:   []string{"GET", "POST", "OPTIONS"},
-		AllowedHeaders:   []string{"Content-Type", "Authorization"},
-		AllowCredentials: true,
-	})
+	c := newCORS(origins, *corsAll)
 
 	// Create server
 	srv := &http.Server{
@@ -90,4 +189,109 @@ func main() {
 	<-stop
 
 	log.Println("Shutting down server...")
+
+	if err := shutdown(srv, hub, *drainTime); err != nil {
+		log.Printf("Error shutting down server: %v", err)
+	}
+	log.Println("Server stopped")
+}
+
+// shutdown stops srv accepting connections and gives in-flight requests up to
+// timeout to finish, then closes every WebSocket connection through the hub
+// and waits for its Run loop to return
+func shutdown(srv *http.Server, hub *api.Hub, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err := srv.Shutdown(ctx)
+
+	// WebSocket connections aren't tracked by the server, so close them
+	// through the hub
+	hub.Shutdown()
+	return err
+}
+
+// envInt returns the environment variable as an integer, or def if it is
+// unset. An invalid value is fatal rather than silently ignored.
+func envInt(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("Invalid %s %q: %v", key, value, err)
+	}
+	return n
+}
+
+// newCORS builds the CORS handler for the allowed origins. Allowing every
+// origin turns credentials off, since otherwise any site could make requests
+// with a visitor's credentials.
+func newCORS(origins []string, allowAll bool) *cors.Cors {
+	opts := cors.Options{
+		AllowedOrigins:   origins,
+		AllowedMethods:   []string{"GET", "POST", "OPTIONS"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization", "Accept-Currency", "Idempotency-Key"},
+		AllowCredentials: true,
+	}
+	if allowAll {
+		opts.AllowedOrigins = []string{"*"}
+		opts.AllowCredentials = false
+	}
+	return cors.New(opts)
+}
+
+// parseOrigins splits a comma-separated list of origins, dropping blanks and
+// any trailing slash, which browsers never send in an Origin header
+func parseOrigins(list string) []string {
+	var origins []string
+	for _, origin := range strings.Split(list, ",") {
+		origin = strings.TrimSuffix(strings.TrimSpace(origin), "/")
+		if origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+// deleteStaleGames removes games that haven't been touched for maxAge, every
+// interval, for as long as the server runs
+func deleteStaleGames(s store.Store, interval, maxAge time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		deleted, err := s.DeleteStaleGames(maxAge)
+		if err != nil {
+			log.Printf("Error deleting stale games: %v", err)
+			continue
+		}
+		if deleted > 0 {
+			log.Printf("Deleted %d stale games", deleted)
+		}
+	}
+}
+
+// seedDefaultTables makes sure tables "table-1" to "table-n" exist with the
+// standard rules. Tables that already have an active game are left alone, so
+// it is safe to run on every startup. It returns how many tables it created.
+func seedDefaultTables(s store.Store, n int) (int, error) {
+	created := 0
+
+	for i := 1; i <= n; i++ {
+		tableID := fmt.Sprintf("table-%d", i)
+
+		if g, _ := s.GetActiveTableGame(tableID); g != nil {
+			continue
+		}
+
+		g := game.NewBlackjackGame(tableID, 10, 1000, game.DefaultNumDecks) // Default min/max bets
+		if err := s.SaveGame(g); err != nil {
+			return created, fmt.Errorf("error creating %s: %v", tableID, err)
+		}
+		created++
+	}
+
+	return created, nil
 }
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/calvinwijaya/card-games-be/internal/api"
+	"github.com/calvinwijaya/card-games-be/internal/game"
+	"github.com/calvinwijaya/card-games-be/internal/store"
+	"github.com/gorilla/websocket"
+)
+
+func TestParseOrigins(t *testing.T) {
+	tests := []struct {
+		list string
+		want []string
+	}{
+		{"http://localhost:5173", []string{"http://localhost:5173"}},
+		{"https://a.example.com, https://b.example.com/", []string{"https://a.example.com", "https://b.example.com"}},
+		{" , ", nil},
+		{"", nil},
+	}
+
+	for _, tt := range tests {
+		if got := parseOrigins(tt.list); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseOrigins(%q) = %q, want %q", tt.list, got, tt.want)
+		}
+	}
+}
+
+func TestNewCORS(t *testing.T) {
+	origins := parseOrigins("https://staging.example.com,https://example.com")
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		name            string
+		allowAll        bool
+		origin          string
+		wantOrigin      string
+		wantCredentials string
+	}{
+		{"listed origin", false, "https://example.com", "https://example.com", "true"},
+		{"second listed origin", false, "https://staging.example.com", "https://staging.example.com", "true"},
+		{"unlisted origin", false, "https://evil.example.net", "", ""},
+		{"allow all", true, "https://evil.example.net", "*", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := newCORS(origins, tt.allowAll).Handler(ok)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/tables", nil)
+			req.Header.Set("Origin", tt.origin)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != tt.wantCredentials {
+				t.Errorf("Access-Control-Allow-Credentials = %q, want %q", got, tt.wantCredentials)
+			}
+		})
+	}
+}
+
+// tableStore keeps games in memory, implementing the parts of store.Store
+// that seeding uses
+type tableStore struct {
+	store.Store
+	games map[string]*game.BlackjackGame
+}
+
+func (s *tableStore) SaveGame(g *game.BlackjackGame) error {
+	s.games[g.ID] = g
+	return nil
+}
+
+func (s *tableStore) GetActiveTableGame(tableID string) (*game.BlackjackGame, error) {
+	for _, g := range s.games {
+		if g.TableID == tableID && g.Status != game.Completed {
+			return g, nil
+		}
+	}
+	return nil, errors.New("no active game found for table")
+}
+
+func TestSeedDefaultTables(t *testing.T) {
+	s := &tableStore{games: make(map[string]*game.BlackjackGame)}
+
+	// A table already in play keeps its game
+	existing := game.NewBlackjackGame("table-2", 25, 500, 1)
+	s.SaveGame(existing)
+
+	created, err := seedDefaultTables(s, 3)
+	if err != nil || created != 2 {
+		t.Fatalf("first seed = %d, %v, want 2 tables created", created, err)
+	}
+	created, err = seedDefaultTables(s, 3)
+	if err != nil || created != 0 {
+		t.Fatalf("second seed = %d, %v, want nothing created", created, err)
+	}
+
+	perTable := make(map[string]int)
+	for _, g := range s.games {
+		perTable[g.TableID]++
+	}
+	want := map[string]int{"table-1": 1, "table-2": 1, "table-3": 1}
+	if !reflect.DeepEqual(perTable, want) {
+		t.Errorf("games per table = %v, want %v", perTable, want)
+	}
+	if g, _ := s.GetActiveTableGame("table-2"); g != existing {
+		t.Error("the existing table-2 game was replaced")
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	hub := api.NewHub()
+	go hub.Run()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", hub.WebSocketHandler)
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		io.WriteString(w, "done")
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws?playerId=p1&tableId=table-1", nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	type result struct {
+		body string
+		err  error
+	}
+	slow := make(chan result, 1)
+	go func() {
+		resp, err := http.Get(server.URL + "/slow")
+		if err != nil {
+			slow <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		slow <- result{string(body), err}
+	}()
+	<-started
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- shutdown(server.Config, hub, 5*time.Second) }()
+
+	// The in-flight request holds shutdown up until it finishes
+	select {
+	case err := <-stopped:
+		t.Fatalf("shutdown returned %v while a request was in flight", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+	if res := <-slow; res.err != nil || res.body != "done" {
+		t.Errorf("in-flight request = %q, %v, want it to finish", res.body, res.err)
+	}
+
+	select {
+	case err := <-stopped:
+		if err != nil {
+			t.Errorf("shutdown = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("shutdown didn't return after the request finished")
+	}
+
+	// The hub closed the WebSocket on its way out
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				t.Fatal("the WebSocket stayed open after shutdown")
+			}
+			break
+		}
+	}
+
+	if _, err := http.Get(server.URL + "/slow"); err == nil {
+		t.Error("the server took a new request after shutdown")
+	}
+}
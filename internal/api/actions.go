@@ -0,0 +1,264 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/calvinwijaya/card-games-be/internal/game"
+	"github.com/calvinwijaya/card-games-be/internal/metrics"
+)
+
+// actionError is a failed game action and the HTTP status it maps to
+type actionError struct {
+	status  int
+	message string
+}
+
+func (e *actionError) Error() string {
+	return e.message
+}
+
+// notParticipantMessage is returned when a player acts on a game they aren't seated in
+const notParticipantMessage = "Not a participant in this game"
+
+// requireParticipant rejects actions from players who aren't seated in the game
+func requireParticipant(g *game.BlackjackGame, playerID string) *actionError {
+	if !g.HasPlayer(playerID) {
+		return &actionError{http.StatusForbidden, notParticipantMessage}
+	}
+	return nil
+}
+
+// runAction applies an action to a game under its lock, then saves and
+// publishes the result. It is shared by the REST handlers and WebSocket
+// messages so both go through the same checks.
+func (h *Handlers) runAction(gameID string, act func(g *game.BlackjackGame) *actionError) (*game.BlackjackGame, *actionError) {
+	unlock := h.locks.lock(gameID)
+	defer unlock()
+
+	g, err := h.store.GetGame(gameID)
+	if err != nil {
+		return nil, &actionError{http.StatusNotFound, "Game not found"}
+	}
+	before := g.Status
+
+	if aerr := act(g); aerr != nil {
+		return nil, aerr
+	}
+
+	if err := h.storeGame(g, before); err != nil {
+		if errors.Is(err, errStatusConflict) {
+			return nil, &actionError{http.StatusConflict, "Game was changed by another request, please refresh"}
+		}
+		return nil, &actionError{http.StatusInternalServerError, "Failed to update game"}
+	}
+
+	// Broadcast game update to all players
+	h.broadcastGame(g)
+
+	// If the action completed the round, save results to database
+	if g.Status == game.Completed && before != game.Completed {
+		h.saveRoundResults(g)
+	}
+
+	return g, nil
+}
+
+// hit draws a card for the player's active hand
+func (h *Handlers) hit(gameID, playerID string, handIndex int) (*game.BlackjackGame, game.Card, *actionError) {
+	var card game.Card
+	g, aerr := h.runAction(gameID, func(g *game.BlackjackGame) *actionError {
+		if aerr := requireParticipant(g, playerID); aerr != nil {
+			return aerr
+		}
+
+		// Make sure the action targets the hand being played
+		if err := g.ValidateHandIndex(playerID, handIndex); err != nil {
+			return &actionError{http.StatusBadRequest, "Invalid hand index: " + err.Error()}
+		}
+
+		var success bool
+		if card, success = g.Hit(playerID); !success {
+			return &actionError{http.StatusBadRequest, "Unable to hit"}
+		}
+		metrics.Actions.WithLabelValues("hit").Inc()
+		return nil
+	})
+
+	// The whole table sees the card in the game update; the player also gets
+	// it on its own so their client can animate the draw
+	if aerr == nil && h.hub != nil {
+		h.hub.SendToPlayer(playerID, Message{
+			Type:     "youDrew",
+			GameID:   g.ID,
+			TableID:  g.TableID,
+			PlayerID: playerID,
+			Data: map[string]interface{}{
+				"card":      card,
+				"handIndex": handIndex,
+			},
+		})
+	}
+	return g, card, aerr
+}
+
+// stand ends play on the player's active hand
+func (h *Handlers) stand(gameID, playerID string, handIndex int) (*game.BlackjackGame, *actionError) {
+	return h.runAction(gameID, func(g *game.BlackjackGame) *actionError {
+		if aerr := requireParticipant(g, playerID); aerr != nil {
+			return aerr
+		}
+
+		// Make sure the action targets the hand being played
+		if err := g.ValidateHandIndex(playerID, handIndex); err != nil {
+			return &actionError{http.StatusBadRequest, "Invalid hand index: " + err.Error()}
+		}
+
+		if success := g.Stand(playerID); !success {
+			return &actionError{http.StatusBadRequest, "Unable to stand"}
+		}
+		metrics.Actions.WithLabelValues("stand").Inc()
+		return nil
+	})
+}
+
+// placeBet places the player's bet for the round on one of their seats
+func (h *Handlers) placeBet(gameID, playerID string, seat, amount int) (*game.BlackjackGame, *actionError) {
+	var tableID string
+	var balance int
+	g, aerr := h.runAction(gameID, func(g *game.BlackjackGame) *actionError {
+		tableID = g.TableID
+		balance, _ = g.PlayerBalance(playerID)
+		if aerr := requireParticipant(g, playerID); aerr != nil {
+			return aerr
+		}
+
+		if err := g.PlaceSeatBet(playerID, seat, amount); err != nil {
+			return &actionError{http.StatusBadRequest, betFailureReason(g, err)}
+		}
+		balance, _ = g.PlayerBalance(playerID)
+		metrics.Actions.WithLabelValues("bet").Inc()
+		return nil
+	})
+
+	// The table sees the bet in the game update; the player also gets a
+	// direct answer so their client can tell its own bet was taken
+	if h.hub != nil {
+		msgType := "betAccepted"
+		data := map[string]interface{}{
+			"seat":    seat,
+			"amount":  amount,
+			"balance": balance,
+		}
+		if aerr != nil {
+			msgType = "betRejected"
+			data["reason"] = aerr.message
+		}
+		h.hub.SendToPlayer(playerID, Message{
+			Type:     msgType,
+			GameID:   gameID,
+			TableID:  tableID,
+			PlayerID: playerID,
+			Data:     data,
+		})
+	}
+	return g, aerr
+}
+
+// addSeat gives the player another seat at the table
+func (h *Handlers) addSeat(gameID, playerID string) (*game.BlackjackGame, *actionError) {
+	return h.runAction(gameID, func(g *game.BlackjackGame) *actionError {
+		if aerr := requireParticipant(g, playerID); aerr != nil {
+			return aerr
+		}
+
+		if !g.AddSeat(playerID) {
+			return &actionError{http.StatusConflict, "Table is full"}
+		}
+		metrics.Actions.WithLabelValues("seat").Inc()
+		return nil
+	})
+}
+
+// betFailureReason explains why a bet was refused
+func betFailureReason(g *game.BlackjackGame, err error) string {
+	switch {
+	case errors.Is(err, game.ErrNotBetting):
+		return "Betting is not open"
+	case errors.Is(err, game.ErrBetBelowMin):
+		return fmt.Sprintf("Bet is below the table minimum of %d", g.MinBet)
+	case errors.Is(err, game.ErrBetAboveMax):
+		return fmt.Sprintf("Bet is above the table maximum of %d", g.MaxBet)
+	case errors.Is(err, game.ErrInsufficientBalance):
+		return "Insufficient balance for this bet"
+	case errors.Is(err, game.ErrNotSeated):
+		return "No such seat"
+	}
+	return "Unable to place bet"
+}
+
+// placeSideBet places one of the player's side bets for the round
+func (h *Handlers) placeSideBet(gameID, playerID string, betType game.SideBetType, amount int) (*game.BlackjackGame, *actionError) {
+	return h.runAction(gameID, func(g *game.BlackjackGame) *actionError {
+		if aerr := requireParticipant(g, playerID); aerr != nil {
+			return aerr
+		}
+
+		if err := g.PlaceSideBet(playerID, betType, amount); err != nil {
+			return &actionError{http.StatusBadRequest, "Unable to place side bet: " + err.Error()}
+		}
+		metrics.Actions.WithLabelValues("sidebet").Inc()
+		return nil
+	})
+}
+
+// split splits the player's pair into two hands
+func (h *Handlers) split(gameID, playerID string) (*game.BlackjackGame, *actionError) {
+	return h.runAction(gameID, func(g *game.BlackjackGame) *actionError {
+		if aerr := requireParticipant(g, playerID); aerr != nil {
+			return aerr
+		}
+
+		if success := g.Split(playerID); !success {
+			return &actionError{http.StatusBadRequest, "Unable to split"}
+		}
+		metrics.Actions.WithLabelValues("split").Inc()
+		return nil
+	})
+}
+
+// surrender gives up the player's hand for half their bet back
+func (h *Handlers) surrender(gameID, playerID string) (*game.BlackjackGame, *actionError) {
+	return h.runAction(gameID, func(g *game.BlackjackGame) *actionError {
+		if aerr := requireParticipant(g, playerID); aerr != nil {
+			return aerr
+		}
+
+		if success := g.Surrender(playerID); !success {
+			return &actionError{http.StatusBadRequest, "Unable to surrender"}
+		}
+		metrics.Actions.WithLabelValues("surrender").Inc()
+		return nil
+	})
+}
+
+// undoLastAction takes back the player's last hit on a practice table
+func (h *Handlers) undoLastAction(gameID, playerID string) (*game.BlackjackGame, *actionError) {
+	return h.runAction(gameID, func(g *game.BlackjackGame) *actionError {
+		if aerr := requireParticipant(g, playerID); aerr != nil {
+			return aerr
+		}
+
+		// Undoing moves at a shared table would be unfair on the others
+		if !g.Practice || len(g.Players) != 1 {
+			return &actionError{http.StatusForbidden, "Undo is only available on single-player practice tables"}
+		}
+
+		if success := g.UndoLastAction(); !success {
+			return &actionError{http.StatusBadRequest, "Nothing to undo"}
+		}
+		metrics.Actions.WithLabelValues("undo").Inc()
+		return nil
+	})
+}
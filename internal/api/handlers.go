@@ -1,52 +1,147 @@
 package api
 
 import (
+	"crypto/subtle"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/calvinwijaya/card-games-be/internal/db"
 	"github.com/calvinwijaya/card-games-be/internal/game"
+	"github.com/calvinwijaya/card-games-be/internal/metrics"
 	"github.com/calvinwijaya/card-games-be/internal/store"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 )
 
+// DefaultStartingBalance is the balance new players start with unless
+// configured otherwise
+const DefaultStartingBalance = 1000
+
 // Handlers contains all the API handlers
 type Handlers struct {
-	store    store.Store
-	database *db.Database
-	hub      *Hub
+	store      store.Store
+	database   *db.Database
+	hub        *Hub
+	adminToken string // Token required by admin endpoints, empty disables them
+	locks      gameLocks
+	logger     *slog.Logger
+
+	startingBalance int  // Balance new players start with
+	debug           bool // Serve the raw game state, deck included, to admins
+
+	idempotency   *idempotencyStore // Responses kept for Idempotency-Key replays
+	actionLimiter *actionLimiter    // Per-player action rate limit, nil for none
+	startedAt     time.Time
 }
 
 // NewHandlers creates a new instance of Handlers
 func NewHandlers(store store.Store, database *db.Database, hub *Hub) *Handlers {
-	return &Handlers{
+	h := &Handlers{
 		store:    store,
 		database: database,
 		hub:      hub,
+		logger:   slog.Default(),
+
+		startingBalance: DefaultStartingBalance,
+
+		idempotency:   newIdempotencyStore(DefaultIdempotencyTTL),
+		actionLimiter: newActionLimiter(DefaultActionRate, DefaultActionBurst),
+		startedAt:     time.Now(),
+	}
+
+	// Route inbound WebSocket messages to the game
+	if hub != nil {
+		hub.SetMessageHandler(h.HandleSocketMessage)
+		hub.SetConnectHandler(h.HandleSocketConnect)
+	}
+
+	return h
+}
+
+// SetLogger sets the logger for the handlers' debug and error output
+func (h *Handlers) SetLogger(logger *slog.Logger) {
+	if logger != nil {
+		h.logger = logger
 	}
 }
 
+// SetAdminToken sets the bearer token required by admin endpoints
+func (h *Handlers) SetAdminToken(token string) {
+	h.adminToken = token
+}
+
+// SetStartingBalance sets the balance new players start with
+func (h *Handlers) SetStartingBalance(balance int) {
+	h.startingBalance = balance
+}
+
+// SetDebug enables the debug endpoint, which serves a game's raw state
+// including the deck order. It must never be enabled in production.
+func (h *Handlers) SetDebug(enabled bool) {
+	h.debug = enabled
+}
+
 // RegisterRoutes registers all API routes
 func (h *Handlers) RegisterRoutes(r *mux.Router) {
 	// Game endpoints
 	r.HandleFunc("/api/game/new", h.NewGame).Methods("POST")
-	r.HandleFunc("/api/game/{id}/hit", h.Hit).Methods("POST")
-	r.HandleFunc("/api/game/{id}/stand", h.Stand).Methods("POST")
-	r.HandleFunc("/api/game/{id}/bet", h.PlaceBet).Methods("POST")
+	r.HandleFunc("/api/game/{id}/hit", h.action(h.Hit)).Methods("POST")
+	r.HandleFunc("/api/game/{id}/stand", h.action(h.Stand)).Methods("POST")
+	r.HandleFunc("/api/game/{id}/double", h.action(h.DoubleDown)).Methods("POST")
+	r.HandleFunc("/api/game/{id}/split", h.action(h.Split)).Methods("POST")
+	r.HandleFunc("/api/game/{id}/surrender", h.action(h.Surrender)).Methods("POST")
+	r.HandleFunc("/api/game/{id}/bet", h.action(h.PlaceBet)).Methods("POST")
+	r.HandleFunc("/api/game/{id}/bet/undo", h.action(h.UndoBet)).Methods("POST")
+	r.HandleFunc("/api/game/{id}/sidebet", h.action(h.PlaceSideBet)).Methods("POST")
+	r.HandleFunc("/api/game/{id}/seat", h.action(h.AddSeat)).Methods("POST")
+	r.HandleFunc("/api/game/{id}/undo", h.action(h.UndoLastAction)).Methods("POST")
+	r.HandleFunc("/api/game/{id}/open-betting", h.OpenBetting).Methods("POST")
+	r.HandleFunc("/api/game/{id}/start", h.StartGame).Methods("POST")
+	r.HandleFunc("/api/game/{id}/next-round", h.NextRound).Methods("POST")
+	r.HandleFunc("/api/game/{id}/force-dealer", h.ForceDealer).Methods("POST")
+	r.HandleFunc("/api/game/{id}/kick", h.KickPlayer).Methods("POST")
+	r.HandleFunc("/api/game/{id}/snapshots", h.GetSnapshots).Methods("GET")
+	r.HandleFunc("/api/game/{id}/debug", h.GetGameDebug).Methods("GET")
+	r.HandleFunc("/api/game/{id}/composition", h.GetComposition).Methods("GET")
+	r.HandleFunc("/api/game/{id}/result", h.GetGameResult).Methods("GET")
+	r.HandleFunc("/api/game/{id}/results", h.GetGameResults).Methods("GET")
+	r.HandleFunc("/api/game/{id}/fairness", h.GetFairness).Methods("GET")
+	r.HandleFunc("/api/game/{id}/risk", h.GetBustRisk).Methods("GET")
+	r.HandleFunc("/api/game/{id}/advice", h.GetAdvice).Methods("GET")
 	r.HandleFunc("/api/game/{id}", h.GetGame).Methods("GET")
+	r.HandleFunc("/api/game/{id}", h.DeleteGame).Methods("DELETE")
 
 	// Player endpoints
 	r.HandleFunc("/api/player/register", h.RegisterPlayer).Methods("POST")
+	r.HandleFunc("/api/player/search", h.SearchPlayers).Methods("GET")
 	r.HandleFunc("/api/player/{id}", h.GetPlayer).Methods("GET")
 	r.HandleFunc("/api/player/{id}/stats", h.GetPlayerStats).Methods("GET")
+	r.HandleFunc("/api/player/{id}/history", h.GetPlayerHistory).Methods("GET")
+	r.HandleFunc("/api/player/{id}/topup", h.TopUpPlayer).Methods("POST")
 
 	// Table endpoints
 	r.HandleFunc("/api/table/list", h.ListTables).Methods("GET")
 	r.HandleFunc("/api/table/{id}/join", h.JoinTable).Methods("POST")
 	r.HandleFunc("/api/table/{id}/leave", h.LeaveTable).Methods("POST")
+	r.HandleFunc("/api/table/{id}/house", h.GetHouseBalance).Methods("GET")
+
+	// Health endpoints
+	r.Handle("/metrics", metrics.Handler()).Methods("GET")
+	r.HandleFunc("/healthz", h.Health).Methods("GET")
+	r.HandleFunc("/readyz", h.Ready).Methods("GET")
+
+	// Admin endpoints
+	r.HandleFunc("/api/announce", h.Announce).Methods("POST")
+
+	// Clock sync endpoint
+	r.HandleFunc("/api/time", h.GetServerTime).Methods("GET")
 
 	// WebSocket endpoint
 	r.HandleFunc("/ws", h.hub.WebSocketHandler)
@@ -64,14 +159,240 @@ func errorResponse(w http.ResponseWriter, status int, message string) {
 	response(w, status, map[string]string{"error": message})
 }
 
+// saveGame stores a game after an action. If the action changed the game's
+// status, the transition is claimed atomically first so two concurrent
+// requests can't both apply it. It writes an error response and returns
+// false if the game couldn't be saved.
+func (h *Handlers) saveGame(w http.ResponseWriter, g *game.BlackjackGame, before game.GameStatus) bool {
+	if err := h.storeGame(g, before); err != nil {
+		if errors.Is(err, errStatusConflict) {
+			errorResponse(w, http.StatusConflict, "Game was changed by another request, please refresh")
+		} else {
+			errorResponse(w, http.StatusInternalServerError, "Failed to update game")
+		}
+		return false
+	}
+	return true
+}
+
+// errStatusConflict is returned by storeGame when another request changed the
+// game first
+var errStatusConflict = errors.New("game changed concurrently")
+
+// storeGame saves a game, moving its status atomically from before if the
+// action changed it. The save itself fails if another request saved the game
+// since it was loaded, in which case a claimed transition is handed back.
+func (h *Handlers) storeGame(g *game.BlackjackGame, before game.GameStatus) error {
+	if g.Status != before {
+		applied, err := h.store.TransitionStatus(g.ID, before, g.Status)
+		if err != nil {
+			return err
+		}
+		if !applied {
+			return errStatusConflict
+		}
+	}
+
+	err := h.store.SaveGame(g)
+	if errors.Is(err, game.ErrStaleVersion) {
+		if g.Status != before {
+			if _, err := h.store.TransitionStatus(g.ID, g.Status, before); err != nil {
+				h.logger.Error("Failed to restore game status", "game", g.ID, "err", err)
+			}
+		}
+		return errStatusConflict
+	}
+	return err
+}
+
+// broadcastGame sends the updated game state to the table and publishes any
+// events the last action produced
+func (h *Handlers) broadcastGame(g *game.BlackjackGame) {
+	events := g.DrainEvents()
+	if h.hub == nil {
+		return
+	}
+
+	h.hub.BroadcastGameUpdate(g)
+
+	for _, event := range events {
+		switch event.Type {
+		case game.EventDealerBlackjack:
+			// The round ended on the deal
+			h.hub.BroadcastToTable(g.TableID, Message{
+				Type:    "dealerBlackjack",
+				GameID:  g.ID,
+				TableID: g.TableID,
+				Data:    g.Dealer,
+			})
+
+		case game.EventBettingClosed:
+			// Let clients lock their bet controls
+			h.hub.BroadcastToTable(g.TableID, Message{
+				Type:    "bettingClosed",
+				GameID:  g.ID,
+				TableID: g.TableID,
+				Data:    map[string]bool{"dealt": event.Dealt},
+			})
+
+		case game.EventDeckReshuffled:
+			// Clients keeping a running count start it over
+			h.hub.BroadcastToTable(g.TableID, Message{
+				Type:    "deckReshuffled",
+				GameID:  g.ID,
+				TableID: g.TableID,
+				Data:    map[string]int{"remainingCards": event.Remaining},
+			})
+
+		case game.EventTurnStarted:
+			// Tell the whole table whose turn it is, and the player
+			// themselves that they're up
+			h.hub.BroadcastToTable(g.TableID, Message{
+				Type:     "turnChanged",
+				GameID:   g.ID,
+				TableID:  g.TableID,
+				PlayerID: event.PlayerID,
+				Data:     turnChangedData(event),
+
+				ServerTime: serverTime(),
+			})
+			h.hub.SendToPlayer(event.PlayerID, Message{
+				Type:     "yourTurn",
+				GameID:   g.ID,
+				TableID:  g.TableID,
+				PlayerID: event.PlayerID,
+				Data:     turnChangedData(event),
+
+				ServerTime: serverTime(),
+			})
+		}
+	}
+}
+
+// lockTableGame finds a table's active game, locks it and reloads it so the
+// caller works on the latest state. The caller must call the returned unlock
+// function.
+func (h *Handlers) lockTableGame(tableID string) (*game.BlackjackGame, func(), error) {
+	g, err := h.store.GetActiveTableGame(tableID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	unlock := h.locks.lock(g.ID)
+	g, err = h.store.GetGame(g.ID)
+	if err != nil {
+		unlock()
+		return nil, nil, err
+	}
+	return g, unlock, nil
+}
+
+// turnChangedData describes whose turn it is and, if turns are timed, when
+// the turn runs out
+func turnChangedData(event game.Event) map[string]interface{} {
+	data := map[string]interface{}{
+		"currentPlayerIndex": event.PlayerIndex,
+		"playerId":           event.PlayerID,
+	}
+	if !event.Deadline.IsZero() {
+		data["deadline"] = event.Deadline.Format(time.RFC3339Nano)
+		data["remainingMs"] = time.Until(event.Deadline).Milliseconds()
+	}
+	return data
+}
+
+// requireAdmin checks the request carries the admin bearer token, writing an
+// error response and returning false if it doesn't
+func (h *Handlers) requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if h.adminToken == "" {
+		errorResponse(w, http.StatusForbidden, "Admin endpoints are disabled")
+		return false
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(h.adminToken)) != 1 {
+		errorResponse(w, http.StatusUnauthorized, "Invalid admin token")
+		return false
+	}
+
+	return true
+}
+
 // NewGame creates a new blackjack game
 func (h *Handlers) NewGame(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		TableID string `json:"tableId"`
 		MinBet  int    `json:"minBet"`
 		MaxBet  int    `json:"maxBet"`
+
+		// House rules: numDecks, dealerHitsSoft17, surrender,
+		// doubleAfterSplit, maxSplits and payouts. Unset rules keep their
+		// defaults.
+		game.RuleSet
+
+		// Seats at the table, game.DefaultMaxPlayers if not set
+		MaxPlayers int `json:"maxPlayers"`
+
+		// Players must confirm readiness before the round is dealt
+		RequireReady bool `json:"requireReady"`
+
+		// Allow the remaining deck composition to be queried
+		ShowComposition bool `json:"showComposition"`
+
+		// Variant card values by rank
+		ValueOverrides map[game.Rank]int `json:"valueOverrides"`
+
+		// Show every player's hand once the round completes
+		RevealHandsAtShowdown bool `json:"revealHandsAtShowdown"`
+
+		// Grant a free house-funded bet on each player's first round
+		BonusEnabled bool `json:"bonusEnabled"`
+		BonusAmount  int  `json:"bonusAmount"`
+
+		// Dealer checks for blackjack under a ten or ace before players act
+		DealerPeek bool `json:"dealerPeek"`
+
+		// Five cards without busting win at even money
+		FiveCardCharlie bool `json:"fiveCardCharlie"`
+
+		// Refuse to deal rounds that could pay out more than this (0 = no cap)
+		MaxRoundPayout int `json:"maxRoundPayout"`
+
+		// Let the table open betting and deal by itself once enough players
+		// are seated, closing betting after the window or once everyone bet
+		AutoStartMinPlayers int  `json:"autoStartMinPlayers"`
+		BettingWindowMs     int  `json:"bettingWindowMs"`
+		StartWhenReady      bool `json:"startWhenReady"`
+
+		// Deal to whoever has bet once betting has been open this long (0 = no limit)
+		BettingTimeoutMs int `json:"bettingTimeoutMs"`
+
+		// Stand players automatically if they don't act in time (0 = no limit)
+		TurnTimeoutMs int `json:"turnTimeoutMs"`
+
+		// Ranked tables don't allow deterministic shuffles
+		Ranked bool `json:"ranked"`
+
+		// Practice tables don't record results and let a lone player undo hits
+		Practice bool `json:"practice"`
+
+		// Seed disclosure policy, with the agreed seed for deterministic mode
+		FairnessMode game.FairnessMode `json:"fairnessMode"`
+		Seed         int64             `json:"seed"`
+
+		// Side bet payouts to override, as x to 1. Payouts set in
+		// payouts.sideBets take precedence.
+		SideBetPayouts game.SideBetPayouts `json:"sideBetPayouts"`
+
+		// Payout preset name, with any individual ratios to override in payouts
+		PayoutPreset string `json:"payoutPreset"`
 	}
 
+	// Rules the request leaves out keep their defaults. Payouts are resolved
+	// from the preset below, so only the ratios the request sets are kept.
+	req.RuleSet = game.DefaultRuleSet
+	req.Payouts = game.PayoutTable{}
+
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		errorResponse(w, http.StatusBadRequest, "Invalid request body")
 		return
@@ -88,16 +409,68 @@ func (h *Handlers) NewGame(w http.ResponseWriter, r *http.Request) {
 	if req.MaxBet <= 0 || req.MaxBet < req.MinBet {
 		req.MaxBet = req.MinBet * 100
 	}
+	if req.MaxPlayers < 0 {
+		errorResponse(w, http.StatusBadRequest, "maxPlayers must not be negative")
+		return
+	}
+	if req.Practice && req.Ranked {
+		errorResponse(w, http.StatusBadRequest, "Practice tables can't be ranked")
+		return
+	}
+	if err := req.RuleSet.Validate(); err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Resolve the payout table from the preset and overrides
+	payouts := game.DefaultPayouts
+	if req.PayoutPreset != "" {
+		preset, ok := game.PayoutPreset(req.PayoutPreset)
+		if !ok {
+			errorResponse(w, http.StatusBadRequest, "Unknown payout preset")
+			return
+		}
+		payouts = preset
+	}
 
 	// Create a new game
-	g := game.NewBlackjackGame(req.TableID, req.MinBet, req.MaxBet)
+	g := game.NewBlackjackGame(req.TableID, req.MinBet, req.MaxBet, req.NumDecks)
+	rules := req.RuleSet
+	rules.NumDecks = g.NumDecks
+	req.Payouts.SideBets = req.Payouts.SideBets.WithDefaults(req.SideBetPayouts)
+	rules.Payouts = req.Payouts.WithDefaults(payouts)
+	g.RuleSet = rules
+	if req.MaxPlayers > 0 {
+		g.MaxPlayers = req.MaxPlayers
+	}
+	g.RequireReady = req.RequireReady
+	g.ShowComposition = req.ShowComposition
+	g.ValueOverrides = req.ValueOverrides
+	g.RevealHandsAtShowdown = req.RevealHandsAtShowdown
+	g.Ranked = req.Ranked
+	g.Practice = req.Practice
+	g.BonusEnabled = req.BonusEnabled
+	g.BonusAmount = req.BonusAmount
+	g.DealerPeek = req.DealerPeek
+	g.FiveCardCharlie = req.FiveCardCharlie
+	g.MaxRoundPayout = req.MaxRoundPayout
+	g.AutoStartMinPlayers = req.AutoStartMinPlayers
+	g.BettingWindowMs = req.BettingWindowMs
+	g.StartWhenReady = req.StartWhenReady
+	g.TurnTimeout = time.Duration(req.TurnTimeoutMs) * time.Millisecond
+	g.BettingTimeout = time.Duration(req.BettingTimeoutMs) * time.Millisecond
+
+	if err := g.SetFairness(req.FairnessMode, req.Seed); err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	// Change status to betting phase
 	// g.Status = game.Betting
 
 	// Save to store
 	if err := h.store.SaveGame(g); err != nil {
-		fmt.Println("err: ", err)
+		h.logger.Error("Failed to save game", "game", g.ID, "err", err)
 		errorResponse(w, http.StatusInternalServerError, "Failed to save game")
 		return
 	}
@@ -110,33 +483,841 @@ func (h *Handlers) NewGame(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	metrics.GamesCreated.Inc()
+
+	// Clients only ever get the sanitized state. The raw game holds the
+	// shuffled deck, and with it every card still to come.
+	gameState := g.GetPublicState()
+
 	// Broadcast game creation to the table
 	if h.hub != nil {
 		h.hub.BroadcastToTable(g.TableID, Message{
 			Type:    "gameCreated",
 			GameID:  g.ID,
 			TableID: g.TableID,
-			Data:    g,
+			Data:    gameState,
 		})
 	}
 
-	response(w, http.StatusCreated, g)
+	response(w, http.StatusCreated, gameState)
+}
+
+// Hit allows a player to take another card
+func (h *Handlers) Hit(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	gameID := vars["id"]
+
+	var req struct {
+		PlayerID  string `json:"playerId"`
+		HandIndex int    `json:"handIndex"` // Hand to act on, 0 unless the player has split
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	// Perform hit action
+	g, card, aerr := h.hit(gameID, req.PlayerID, req.HandIndex)
+	if aerr != nil {
+		errorResponse(w, aerr.status, aerr.message)
+		return
+	}
+
+	response(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"card":    card,
+		"game":    g.GetGameState(req.PlayerID),
+	})
+}
+
+// DoubleDown allows a player to double their bet and take exactly one more card
+func (h *Handlers) DoubleDown(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	gameID := vars["id"]
+
+	// Hold the game's lock across load, mutate and save
+	unlock := h.locks.lock(gameID)
+	defer unlock()
+
+	var req struct {
+		PlayerID  string `json:"playerId"`
+		HandIndex int    `json:"handIndex"` // Hand to act on, 0 unless the player has split
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	// Get the game from store
+	g, err := h.store.GetGame(gameID)
+	if err != nil {
+		errorResponse(w, http.StatusNotFound, "Game not found")
+		return
+	}
+	before := g.Status
+
+	if !g.HasPlayer(req.PlayerID) {
+		errorResponse(w, http.StatusForbidden, notParticipantMessage)
+		return
+	}
+
+	// Make sure the action targets the hand being played
+	if err := g.ValidateHandIndex(req.PlayerID, req.HandIndex); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid hand index: "+err.Error())
+		return
+	}
+
+	// Perform double down action
+	card, success := g.DoubleDown(req.PlayerID)
+	if !success {
+		errorResponse(w, http.StatusBadRequest, "Unable to double down")
+		return
+	}
+	metrics.Actions.WithLabelValues("double").Inc()
+
+	// Update game in store
+	if !h.saveGame(w, g, before) {
+		return
+	}
+
+	// Broadcast game update to all players
+	h.broadcastGame(g)
+
+	// Doubling ends the player's turn, which may have completed the round
+	if g.Status == game.Completed {
+		h.saveRoundResults(g)
+	}
+
+	response(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"card":    card,
+		"game":    g.GetGameState(req.PlayerID),
+	})
+}
+
+// Split allows a player to split a matching pair into two hands
+func (h *Handlers) Split(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	gameID := vars["id"]
+
+	var req struct {
+		PlayerID string `json:"playerId"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	g, aerr := h.split(gameID, req.PlayerID)
+	if aerr != nil {
+		errorResponse(w, aerr.status, aerr.message)
+		return
+	}
+
+	response(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"game":    g.GetGameState(req.PlayerID),
+	})
+}
+
+// Stand allows a player to end their turn
+func (h *Handlers) Stand(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	gameID := vars["id"]
+
+	var req struct {
+		PlayerID  string `json:"playerId"`
+		HandIndex int    `json:"handIndex"` // Hand to act on, 0 unless the player has split
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	// Perform stand action
+	g, aerr := h.stand(gameID, req.PlayerID, req.HandIndex)
+	if aerr != nil {
+		errorResponse(w, aerr.status, aerr.message)
+		return
+	}
+
+	response(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"game":    g.GetGameState(req.PlayerID),
+	})
+}
+
+// refreshBalances picks up balance changes made outside the game, such as
+// top-ups between rounds, before the next round is played with the balances
+// the game holds
+func (h *Handlers) refreshBalances(g *game.BlackjackGame) {
+	if h.database == nil || g.Practice {
+		return
+	}
+
+	for _, player := range g.Players {
+		current, _ := g.PlayerBalance(player.ID)
+		stored, err := h.database.GetPlayerByID(player.ID)
+		if err != nil || stored == nil || stored.Balance == current {
+			continue
+		}
+		g.SetBalance(player.ID, stored.Balance)
+	}
+}
+
+// saveRoundResults persists the results of a completed round to the database
+func (h *Handlers) saveRoundResults(g *game.BlackjackGame) {
+	if h.database == nil {
+		return
+	}
+
+	// Update game status in database
+	h.database.UpdateGameStatus(g.ID, g.Status)
+
+	// Practice rounds can be undone, so they never count
+	if g.Practice {
+		return
+	}
+
+	// Save every result, balance and the house's take together, so a
+	// failure part way can't leave them disagreeing
+	if err := h.database.SaveRoundResults(g); err != nil {
+		h.logger.Error("Failed to save round results", "game", g.ID, "table", g.TableID, "err", err)
+	}
+}
+
+// ForceDealer plays the dealer's turn for a game that is stuck in progress
+// with no player left to act
+func (h *Handlers) ForceDealer(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	vars := mux.Vars(r)
+	gameID := vars["id"]
+
+	// Hold the game's lock across load, mutate and save
+	unlock := h.locks.lock(gameID)
+	defer unlock()
+
+	// Get the game from store
+	g, err := h.store.GetGame(gameID)
+	if err != nil {
+		errorResponse(w, http.StatusNotFound, "Game not found")
+		return
+	}
+	before := g.Status
+
+	if !g.ForceDealerTurn() {
+		errorResponse(w, http.StatusConflict, "Game is not in progress or still has a player to act")
+		return
+	}
+
+	// Update game in store
+	if !h.saveGame(w, g, before) {
+		return
+	}
+
+	// Broadcast game update to all players
+	h.broadcastGame(g)
+
+	h.saveRoundResults(g)
+
+	response(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"game":    g.GetPublicState(),
+	})
+}
+
+// DeleteGame removes a game. A game in progress is only deleted with
+// force=true, since its bets would be lost.
+func (h *Handlers) DeleteGame(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	vars := mux.Vars(r)
+	gameID := vars["id"]
+	force := r.URL.Query().Get("force") == "true"
+
+	// Hold the game's lock so no action lands on it mid-delete
+	unlock := h.locks.lock(gameID)
+	defer unlock()
+
+	// Get the game from store
+	g, err := h.store.GetGame(gameID)
+	if err != nil {
+		errorResponse(w, http.StatusNotFound, "Game not found")
+		return
+	}
+
+	if g.Status == game.InProgress && !force {
+		errorResponse(w, http.StatusConflict, "Game is in progress; use force=true to delete it anyway")
+		return
+	}
+
+	if err := h.store.DeleteGame(gameID); err != nil {
+		h.logger.Error("Failed to delete game", "game", gameID, "err", err)
+		errorResponse(w, http.StatusInternalServerError, "Failed to delete game")
+		return
+	}
+
+	// Games are also kept in the database when it isn't the store
+	if h.database != nil {
+		if err := h.database.DeleteGame(gameID); err != nil {
+			h.logger.Error("Failed to delete game from database", "game", gameID, "err", err)
+		}
+	}
+
+	if h.hub != nil {
+		h.hub.BroadcastToTable(g.TableID, Message{
+			Type:    "gameDeleted",
+			GameID:  g.ID,
+			TableID: g.TableID,
+		})
+	}
+
+	response(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+	})
+}
+
+// Announce sends a server-wide message, such as a maintenance notice, to
+// every connected client
+func (h *Handlers) Announce(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	var req struct {
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	req.Message = strings.TrimSpace(req.Message)
+	if req.Message == "" {
+		errorResponse(w, http.StatusBadRequest, "Message is required")
+		return
+	}
+
+	if h.hub != nil {
+		h.hub.BroadcastAll(Message{
+			Type:       "announcement",
+			ServerTime: serverTime(),
+			Data: map[string]string{
+				"message": req.Message,
+			},
+		})
+	}
+
+	response(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+	})
+}
+
+// KickPlayer removes an idle player from a game
+func (h *Handlers) KickPlayer(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	vars := mux.Vars(r)
+	gameID := vars["id"]
+
+	var req struct {
+		PlayerID string `json:"playerId"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	// Hold the game's lock across load, mutate and save
+	unlock := h.locks.lock(gameID)
+	defer unlock()
+
+	// Get the game from store
+	g, err := h.store.GetGame(gameID)
+	if err != nil {
+		errorResponse(w, http.StatusNotFound, "Game not found")
+		return
+	}
+	before := g.Status
+
+	kicked, ok := g.KickPlayer(req.PlayerID)
+	if !ok {
+		errorResponse(w, http.StatusBadRequest, "Player not found in game")
+		return
+	}
+
+	// Update game in store
+	if !h.saveGame(w, g, before) {
+		return
+	}
+
+	// Keep any refunded bet
+	if h.database != nil && !g.Practice {
+		h.database.UpdatePlayerBalance(kicked.ID, kicked.Balance)
+	}
+
+	if h.hub != nil {
+		h.hub.BroadcastToTable(g.TableID, Message{
+			Type:     "playerLeft",
+			GameID:   g.ID,
+			TableID:  g.TableID,
+			PlayerID: kicked.ID,
+			Data:     map[string]bool{"kicked": true},
+		})
+	}
+	h.broadcastGame(g)
+
+	// Passing the turn on may have completed the round
+	if before == game.InProgress && g.Status == game.Completed {
+		h.saveRoundResults(g)
+	}
+
+	response(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"game":    g.GetPublicState(),
+	})
+}
+
+// GetSnapshots lists every recorded state of a game
+func (h *Handlers) GetSnapshots(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	vars := mux.Vars(r)
+	gameID := vars["id"]
+
+	if h.database == nil {
+		errorResponse(w, http.StatusInternalServerError, "Database not available")
+		return
+	}
+
+	snapshots, err := h.database.GetSnapshots(gameID)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Error retrieving snapshots")
+		return
+	}
+
+	response(w, http.StatusOK, snapshots)
+}
+
+// PlaceBet allows a player to place a bet
+func (h *Handlers) PlaceBet(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	gameID := vars["id"]
+
+	var req struct {
+		PlayerID string `json:"playerId"`
+		Amount   int    `json:"amount"`
+		Seat     int    `json:"seat"` // Seat to bet on, 0 unless the player took extra seats
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	// Place the bet
+	g, aerr := h.placeBet(gameID, req.PlayerID, req.Seat, req.Amount)
+	if aerr != nil {
+		errorResponse(w, aerr.status, aerr.message)
+		return
+	}
+
+	response(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"game":    g.GetGameState(req.PlayerID),
+	})
+}
+
+// AddSeat gives a seated player another seat at the table
+func (h *Handlers) AddSeat(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	gameID := vars["id"]
+
+	var req struct {
+		PlayerID string `json:"playerId"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	g, aerr := h.addSeat(gameID, req.PlayerID)
+	if aerr != nil {
+		errorResponse(w, aerr.status, aerr.message)
+		return
+	}
+
+	response(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"game":    g.GetGameState(req.PlayerID),
+	})
+}
+
+// PlaceSideBet allows a player to place a side bet alongside their main bet
+func (h *Handlers) PlaceSideBet(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	gameID := vars["id"]
+
+	var req struct {
+		PlayerID string           `json:"playerId"`
+		Type     game.SideBetType `json:"type"`
+		Amount   int              `json:"amount"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	// Place the side bet
+	g, aerr := h.placeSideBet(gameID, req.PlayerID, req.Type, req.Amount)
+	if aerr != nil {
+		errorResponse(w, aerr.status, aerr.message)
+		return
+	}
+
+	response(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"game":    g.GetGameState(req.PlayerID),
+	})
+}
+
+// UndoBet allows a player to retract their bet before the cards are dealt
+func (h *Handlers) UndoBet(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	gameID := vars["id"]
+
+	// Hold the game's lock across load, mutate and save
+	unlock := h.locks.lock(gameID)
+	defer unlock()
+
+	var req struct {
+		PlayerID string `json:"playerId"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	// Get the game from store
+	g, err := h.store.GetGame(gameID)
+	if err != nil {
+		errorResponse(w, http.StatusNotFound, "Game not found")
+		return
+	}
+	before := g.Status
+
+	if !g.HasPlayer(req.PlayerID) {
+		errorResponse(w, http.StatusForbidden, notParticipantMessage)
+		return
+	}
+
+	// Undo the bet
+	if success := g.UndoBet(req.PlayerID); !success {
+		errorResponse(w, http.StatusBadRequest, "Unable to undo bet")
+		return
+	}
+
+	// Update game in store
+	if !h.saveGame(w, g, before) {
+		return
+	}
+
+	// Broadcast game update to all players
+	h.broadcastGame(g)
+
+	response(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"game":    g.GetGameState(req.PlayerID),
+	})
+}
+
+// Surrender gives up the player's hand for half their bet back
+func (h *Handlers) Surrender(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	gameID := vars["id"]
+
+	var req struct {
+		PlayerID string `json:"playerId"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	g, aerr := h.surrender(gameID, req.PlayerID)
+	if aerr != nil {
+		errorResponse(w, aerr.status, aerr.message)
+		return
+	}
+
+	response(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"game":    g.GetGameState(req.PlayerID),
+	})
+}
+
+// UndoLastAction takes back the player's last hit on a single-player
+// practice table
+func (h *Handlers) UndoLastAction(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	gameID := vars["id"]
+
+	var req struct {
+		PlayerID string `json:"playerId"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	g, aerr := h.undoLastAction(gameID, req.PlayerID)
+	if aerr != nil {
+		errorResponse(w, aerr.status, aerr.message)
+		return
+	}
+
+	response(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"game":    g.GetGameState(req.PlayerID),
+	})
+}
+
+// OpenBetting moves a waiting game into the betting phase
+func (h *Handlers) OpenBetting(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	gameID := vars["id"]
+
+	// Hold the game's lock across load, mutate and save
+	unlock := h.locks.lock(gameID)
+	defer unlock()
+
+	// Get the game from store
+	g, err := h.store.GetGame(gameID)
+	if err != nil {
+		errorResponse(w, http.StatusNotFound, "Game not found")
+		return
+	}
+	before := g.Status
+
+	if success := g.OpenBetting(); !success {
+		if g.Status != game.Waiting {
+			errorResponse(w, http.StatusBadRequest, "Game is not waiting for players")
+		} else {
+			errorResponse(w, http.StatusBadRequest, "No players at the table")
+		}
+		return
+	}
+
+	// Update game in store
+	if !h.saveGame(w, g, before) {
+		return
+	}
+
+	// Let clients show their betting controls
+	if h.hub != nil {
+		h.hub.BroadcastToTable(g.TableID, Message{
+			Type:    "bettingOpened",
+			GameID:  g.ID,
+			TableID: g.TableID,
+		})
+	}
+	h.broadcastGame(g)
+
+	response(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"game":    g.GetGameState(r.URL.Query().Get("playerId")),
+	})
+}
+
+// NextRound clears a completed round off the table and opens betting for
+// the next one
+func (h *Handlers) NextRound(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	gameID := vars["id"]
+
+	// Hold the game's lock across load, mutate and save
+	unlock := h.locks.lock(gameID)
+	defer unlock()
+
+	// Get the game from store
+	g, err := h.store.GetGame(gameID)
+	if err != nil {
+		errorResponse(w, http.StatusNotFound, "Game not found")
+		return
+	}
+	before := g.Status
+
+	if g.Status != game.Completed {
+		errorResponse(w, http.StatusConflict, "Round is not completed")
+		return
+	}
+	g.PrepareForNextRound()
+	h.refreshBalances(g)
+
+	// Update game in store
+	if !h.saveGame(w, g, before) {
+		return
+	}
+
+	// The new round may have started on a reshuffled shoe
+	h.broadcastGame(g)
+
+	response(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"game":    g.GetGameState(r.URL.Query().Get("playerId")),
+	})
+}
+
+// StartGame deals the round once every player has placed a bet
+func (h *Handlers) StartGame(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	gameID := vars["id"]
+
+	// Hold the game's lock across load, mutate and save
+	unlock := h.locks.lock(gameID)
+	defer unlock()
+
+	// Get the game from store
+	g, err := h.store.GetGame(gameID)
+	if err != nil {
+		errorResponse(w, http.StatusNotFound, "Game not found")
+		return
+	}
+	before := g.Status
+
+	// Deal the round
+	if err := g.Start(); err != nil {
+		errorResponse(w, http.StatusBadRequest, startFailureReason(err))
+		return
+	}
+
+	// Update game in store
+	if !h.saveGame(w, g, before) {
+		return
+	}
+
+	// Broadcast the dealt hands to all players
+	h.broadcastGame(g)
+
+	// Everyone may have been dealt blackjack, completing the round
+	if g.Status == game.Completed {
+		h.saveRoundResults(g)
+	}
+
+	response(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"game":    g.GetGameState(r.URL.Query().Get("playerId")),
+	})
+}
+
+// startFailureReason explains why a game couldn't be started
+func startFailureReason(err error) string {
+	switch {
+	case errors.Is(err, game.ErrNotBetting):
+		return "Game is not in the betting phase"
+	case errors.Is(err, game.ErrNoPlayers):
+		return "No players at the table"
+	case errors.Is(err, game.ErrMissingBet):
+		return "Not all players have placed a bet"
+	case errors.Is(err, game.ErrNotReady):
+		return "Not all players are ready"
+	case errors.Is(err, game.ErrPayoutCap):
+		return "Total bets exceed the table's payout cap"
+	}
+	return "Unable to start game"
+}
+
+// Health reports that the server is up, for liveness checks
+func (h *Handlers) Health(w http.ResponseWriter, r *http.Request) {
+	response(w, http.StatusOK, map[string]interface{}{
+		"status":        "ok",
+		"store":         fmt.Sprintf("%T", h.store),
+		"uptimeSeconds": int(time.Since(h.startedAt).Seconds()),
+	})
+}
+
+// Ready reports whether the server can handle requests, for readiness
+// checks. It fails while the database can't be reached.
+func (h *Handlers) Ready(w http.ResponseWriter, r *http.Request) {
+	if h.database == nil {
+		errorResponse(w, http.StatusServiceUnavailable, "Database not available")
+		return
+	}
+
+	if err := h.database.Ping(); err != nil {
+		h.logger.Warn("Readiness check failed", "error", err)
+		errorResponse(w, http.StatusServiceUnavailable, "Database unreachable")
+		return
+	}
+
+	response(w, http.StatusOK, map[string]string{"status": "ready"})
 }
 
-// Hit allows a player to take another card
-func (h *Handlers) Hit(w http.ResponseWriter, r *http.Request) {
+// GetServerTime returns the server clock so clients can work out their offset
+// when rendering countdowns
+func (h *Handlers) GetServerTime(w http.ResponseWriter, r *http.Request) {
+	response(w, http.StatusOK, map[string]interface{}{
+		"serverTime": serverTime(),
+	})
+}
+
+// GetGame returns the current state of a game
+func (h *Handlers) GetGame(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	gameID := vars["id"]
+	playerID := r.URL.Query().Get("playerId")
 
-	var req struct {
-		PlayerID string `json:"playerId"`
+	// Get the game from store
+	g, err := h.store.GetGame(gameID)
+	if err != nil {
+		errorResponse(w, http.StatusNotFound, "Game not found")
+		return
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+	// Only players seated in the game see their own cards and balance
+	if !g.HasPlayer(playerID) {
+		response(w, http.StatusOK, g.GetPublicState())
+		return
+	}
+
+	// Return the game state
+	response(w, http.StatusOK, g.GetGameState(playerID))
+}
+
+// GetGameDebug returns a game's raw state, including the deck order and the
+// action log, for local development and support. It only exists when the
+// server runs in debug mode.
+func (h *Handlers) GetGameDebug(w http.ResponseWriter, r *http.Request) {
+	if !h.debug {
+		http.NotFound(w, r)
+		return
+	}
+	if !h.requireAdmin(w, r) {
 		return
 	}
 
+	vars := mux.Vars(r)
+	gameID := vars["id"]
+
 	// Get the game from store
 	g, err := h.store.GetGame(gameID)
 	if err != nil {
@@ -144,45 +1325,65 @@ func (h *Handlers) Hit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Perform hit action
-	card, success := g.Hit(req.PlayerID)
-	if !success {
-		errorResponse(w, http.StatusBadRequest, "Unable to hit")
+	response(w, http.StatusOK, g)
+}
+
+// GetComposition returns how many cards of each rank remain in the deck
+func (h *Handlers) GetComposition(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	gameID := vars["id"]
+
+	// Get the game from store
+	g, err := h.store.GetGame(gameID)
+	if err != nil {
+		errorResponse(w, http.StatusNotFound, "Game not found")
 		return
 	}
 
-	// Update game in store
-	if err := h.store.SaveGame(g); err != nil {
-		errorResponse(w, http.StatusInternalServerError, "Failed to update game")
+	// Composition aids card counting, so tables have to opt in
+	if !g.ShowComposition {
+		errorResponse(w, http.StatusForbidden, "Deck composition is not available for this table")
 		return
 	}
 
-	// Broadcast game update to all players
-	if h.hub != nil {
-		h.hub.BroadcastGameUpdate(g)
+	counts := map[game.Rank]int{}
+	remaining, dealt, discarded := 0, 0, 0
+	if g.Deck != nil {
+		counts = g.Deck.Composition()
+		remaining = g.Deck.RemainingCards()
+		dealt = g.Deck.DealtCount()
+		discarded = len(g.Deck.Discard)
 	}
 
 	response(w, http.StatusOK, map[string]interface{}{
-		"success": true,
-		"card":    card,
-		"game":    g.GetGameState(req.PlayerID),
+		"remaining": remaining,
+		"dealt":     dealt,
+		"discarded": discarded,
+		"ranks":     counts,
 	})
 }
 
-// Stand allows a player to end their turn
-func (h *Handlers) Stand(w http.ResponseWriter, r *http.Request) {
+// GetFairness returns the game's fairness mode and any disclosed seed details
+func (h *Handlers) GetFairness(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	gameID := vars["id"]
 
-	var req struct {
-		PlayerID string `json:"playerId"`
-	}
-
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+	// Get the game from store
+	g, err := h.store.GetGame(gameID)
+	if err != nil {
+		errorResponse(w, http.StatusNotFound, "Game not found")
 		return
 	}
 
+	response(w, http.StatusOK, g.FairnessInfo())
+}
+
+// GetBustRisk returns the probability that a player's next hit busts them
+func (h *Handlers) GetBustRisk(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	gameID := vars["id"]
+	playerID := r.URL.Query().Get("playerId")
+
 	// Get the game from store
 	g, err := h.store.GetGame(gameID)
 	if err != nil {
@@ -190,129 +1391,126 @@ func (h *Handlers) Stand(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Perform stand action
-	if success := g.Stand(req.PlayerID); !success {
-		errorResponse(w, http.StatusBadRequest, "Unable to stand")
+	score, probability, ok := g.BustRisk(playerID)
+	if !ok {
+		errorResponse(w, http.StatusNotFound, "Player not found in game")
 		return
 	}
 
-	// Update game in store
-	if err := h.store.SaveGame(g); err != nil {
-		errorResponse(w, http.StatusInternalServerError, "Failed to update game")
+	response(w, http.StatusOK, map[string]interface{}{
+		"playerId":        playerID,
+		"score":           score,
+		"bustProbability": probability,
+	})
+}
+
+// GetAdvice returns the basic strategy play for the player's active hand
+func (h *Handlers) GetAdvice(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	gameID := vars["id"]
+	playerID := r.URL.Query().Get("playerId")
+
+	// Get the game from store
+	g, err := h.store.GetGame(gameID)
+	if err != nil {
+		errorResponse(w, http.StatusNotFound, "Game not found")
 		return
 	}
 
-	// Broadcast game update to all players
-	if h.hub != nil {
-		h.hub.BroadcastGameUpdate(g)
-	}
-
-	// If game is completed, save results to database
-	if g.Status == game.Completed && h.database != nil {
-		// Update game status in database
-		h.database.UpdateGameStatus(g.ID, g.Status)
-
-		// Save game results for each player
-		for _, player := range g.Players {
-			var result string
-			var winnings int
-
-			if player.Status == game.PlayerBusted {
-				result = "lose"
-				winnings = 0
-			} else if player.Status == game.PlayerBlackjack {
-				result = "blackjack"
-				// Blackjack pays 3:2
-				winnings = player.Bet + int(float64(player.Bet)*1.5)
-			} else {
-				dealerScore := g.Dealer.Score
-				playerScore := player.Score
-
-				if dealerScore > 21 || playerScore > dealerScore {
-					result = "win"
-					winnings = player.Bet * 2
-				} else if playerScore == dealerScore {
-					result = "push"
-					winnings = player.Bet
-				} else {
-					result = "lose"
-					winnings = 0
-				}
-			}
-
-			h.database.SaveGameResult(g.ID, player.ID, player.Bet, result, winnings)
+	if !g.HasPlayer(playerID) {
+		errorResponse(w, http.StatusNotFound, "Player not found in game")
+		return
+	}
 
-			// Update player balance in database
-			h.database.UpdatePlayerBalance(player.ID, player.Balance)
-		}
+	play, ok := g.Advice(playerID)
+	if !ok {
+		errorResponse(w, http.StatusConflict, "It isn't the player's turn")
+		return
 	}
 
 	response(w, http.StatusOK, map[string]interface{}{
-		"success": true,
-		"game":    g.GetGameState(req.PlayerID),
+		"playerId": playerID,
+		"advice":   play,
 	})
 }
 
-// PlaceBet allows a player to place a bet
-func (h *Handlers) PlaceBet(w http.ResponseWriter, r *http.Request) {
+// GetGameResult returns a single player's settled result for a game
+func (h *Handlers) GetGameResult(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	gameID := vars["id"]
+	playerID := r.URL.Query().Get("playerId")
 
-	var req struct {
-		PlayerID string `json:"playerId"`
-		Amount   int    `json:"amount"`
-	}
-
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+	if playerID == "" {
+		errorResponse(w, http.StatusBadRequest, "playerId is required")
 		return
 	}
 
-	// Get the game from store
-	g, err := h.store.GetGame(gameID)
-	if err != nil {
-		errorResponse(w, http.StatusNotFound, "Game not found")
+	if h.database == nil {
+		errorResponse(w, http.StatusInternalServerError, "Database not available")
 		return
 	}
 
-	// Place the bet
-	if success := g.PlaceBet(req.PlayerID, req.Amount); !success {
-		errorResponse(w, http.StatusBadRequest, "Unable to place bet")
+	result, err := h.database.GetGameResult(gameID, playerID)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Error retrieving game result")
 		return
 	}
 
-	// Update game in store
-	if err := h.store.SaveGame(g); err != nil {
-		errorResponse(w, http.StatusInternalServerError, "Failed to update game")
+	if result == nil {
+		errorResponse(w, http.StatusNotFound, "Game result not found")
 		return
 	}
 
-	// Broadcast game update to all players
-	if h.hub != nil {
-		h.hub.BroadcastGameUpdate(g)
-	}
-
-	response(w, http.StatusOK, map[string]interface{}{
-		"success": true,
-		"game":    g.GetGameState(req.PlayerID),
-	})
+	response(w, http.StatusOK, result)
 }
 
-// GetGame returns the current state of a game
-func (h *Handlers) GetGame(w http.ResponseWriter, r *http.Request) {
+// GetGameResults returns every settled result for a game, broken down by
+// player and hand
+func (h *Handlers) GetGameResults(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	gameID := vars["id"]
-	playerID := r.URL.Query().Get("playerId")
 
-	// Get the game from store
-	g, err := h.store.GetGame(gameID)
+	if h.database == nil {
+		errorResponse(w, http.StatusInternalServerError, "Database not available")
+		return
+	}
+
+	results, err := h.database.GetGameResults(gameID)
 	if err != nil {
-		errorResponse(w, http.StatusNotFound, "Game not found")
+		errorResponse(w, http.StatusInternalServerError, "Error retrieving game results")
 		return
 	}
 
-	// Return the game state
-	response(w, http.StatusOK, g.GetGameState(playerID))
+	// Group each player's hands and total them up
+	type playerResults struct {
+		PlayerID      string          `json:"playerId"`
+		TotalBet      int             `json:"totalBet"`
+		TotalWinnings int             `json:"totalWinnings"`
+		Hands         []db.GameResult `json:"hands"`
+	}
+
+	players := []*playerResults{}
+	byPlayer := make(map[string]*playerResults)
+	for _, result := range results {
+		pr, ok := byPlayer[result.PlayerID]
+		if !ok {
+			pr = &playerResults{PlayerID: result.PlayerID}
+			byPlayer[result.PlayerID] = pr
+			players = append(players, pr)
+		}
+
+		// Bonus bets are staked by the house, not the player
+		if result.Result != "bonus" {
+			pr.TotalBet += result.Bet
+		}
+		pr.TotalWinnings += result.Winnings
+		pr.Hands = append(pr.Hands, result)
+	}
+
+	response(w, http.StatusOK, map[string]interface{}{
+		"gameId":  gameID,
+		"players": players,
+	})
 }
 
 // RegisterPlayer registers a new player
@@ -334,7 +1532,7 @@ func (h *Handlers) RegisterPlayer(w http.ResponseWriter, r *http.Request) {
 
 	// Generate a player ID
 	playerID := uuid.New().String()
-	initialBalance := 1000 // Default starting balance
+	initialBalance := h.startingBalance
 
 	// Create player in database if available
 	if h.database != nil {
@@ -379,6 +1577,94 @@ func (h *Handlers) GetPlayer(w http.ResponseWriter, r *http.Request) {
 	response(w, http.StatusOK, player)
 }
 
+// TopUpPlayer adds chips to a player's stored balance, for buying more chips.
+// Players seated at a table are turned away, since the table writes their
+// balance back when the round settles and would undo the top-up.
+func (h *Handlers) TopUpPlayer(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	vars := mux.Vars(r)
+	playerID := vars["id"]
+
+	var req struct {
+		Amount int `json:"amount"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.Amount <= 0 {
+		errorResponse(w, http.StatusBadRequest, "Amount must be positive")
+		return
+	}
+
+	if h.database == nil {
+		errorResponse(w, http.StatusInternalServerError, "Database not available")
+		return
+	}
+
+	// Completed games keep their players until the next round, but their
+	// balances have already been saved, so only games still being played
+	// hold a balance that would overwrite the top-up
+	games, err := h.store.GetActiveGames()
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Error retrieving games")
+		return
+	}
+	for _, g := range games {
+		if g.HasPlayer(playerID) {
+			errorResponse(w, http.StatusConflict, "Player is seated at a table; top up after leaving")
+			return
+		}
+	}
+
+	balance, err := h.database.AddToBalance(playerID, req.Amount)
+	if errors.Is(err, sql.ErrNoRows) {
+		errorResponse(w, http.StatusNotFound, "Player not found")
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to top up player", "player", playerID, "err", err)
+		errorResponse(w, http.StatusInternalServerError, "Failed to top up player")
+		return
+	}
+
+	response(w, http.StatusOK, map[string]interface{}{
+		"playerId": playerID,
+		"balance":  balance,
+	})
+}
+
+// SearchPlayers finds players by name prefix
+func (h *Handlers) SearchPlayers(w http.ResponseWriter, r *http.Request) {
+	const (
+		minQueryLength = 2
+		maxResults     = 20
+	)
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if len(query) < minQueryLength {
+		errorResponse(w, http.StatusBadRequest, fmt.Sprintf("Query must be at least %d characters", minQueryLength))
+		return
+	}
+
+	if h.database == nil {
+		errorResponse(w, http.StatusInternalServerError, "Database not available")
+		return
+	}
+
+	players, err := h.database.SearchPlayers(query, maxResults)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Error searching players")
+		return
+	}
+
+	response(w, http.StatusOK, players)
+}
+
 // GetPlayerStats returns player statistics
 func (h *Handlers) GetPlayerStats(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -399,6 +1685,53 @@ func (h *Handlers) GetPlayerStats(w http.ResponseWriter, r *http.Request) {
 	response(w, http.StatusOK, stats)
 }
 
+// GetPlayerHistory returns a page of the player's recent hand results
+func (h *Handlers) GetPlayerHistory(w http.ResponseWriter, r *http.Request) {
+	const (
+		defaultLimit = 20
+		maxLimit     = 100
+	)
+
+	vars := mux.Vars(r)
+	playerID := vars["id"]
+
+	limit, offset := defaultLimit, 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxLimit {
+			errorResponse(w, http.StatusBadRequest, fmt.Sprintf("Limit must be between 1 and %d", maxLimit))
+			return
+		}
+		limit = n
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			errorResponse(w, http.StatusBadRequest, "Offset must be a non-negative number")
+			return
+		}
+		offset = n
+	}
+
+	if h.database == nil {
+		errorResponse(w, http.StatusInternalServerError, "Database not available")
+		return
+	}
+
+	history, err := h.database.GetPlayerHistory(playerID, limit, offset)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Error retrieving player history")
+		return
+	}
+
+	response(w, http.StatusOK, map[string]interface{}{
+		"playerId": playerID,
+		"limit":    limit,
+		"offset":   offset,
+		"results":  history,
+	})
+}
+
 // JoinTable allows a player to join a table
 func (h *Handlers) JoinTable(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -415,22 +1748,29 @@ func (h *Handlers) JoinTable(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get active game for this table
-	g, err := h.store.GetActiveTableGame(tableID)
+	g, unlock, err := h.lockTableGame(tableID)
 	if err != nil {
 		// No active game for this table, create a new one
-		g = game.NewBlackjackGame(tableID, 10, 1000) // Default min/max bets
+		g = game.NewBlackjackGame(tableID, 10, 1000, game.DefaultNumDecks) // Default min/max bets
 		g.Status = game.Waiting
+		unlock = h.locks.lock(g.ID)
 		h.store.SaveGame(g)
+		metrics.GamesCreated.Inc()
 	}
+	defer unlock()
 
 	// If the game is in the Completed state, start a new round
 	if g.Status == game.Completed {
 		g.PrepareForNextRound()
+		h.refreshBalances(g)
 		h.store.SaveGame(g)
+
+		// The new round may have started on a reshuffled shoe
+		h.broadcastGame(g)
 	}
 
 	// Get player from database if available
-	var initialBalance int = 1000
+	initialBalance := h.startingBalance
 
 	if h.database != nil {
 		dbPlayer, err := h.database.GetPlayerByID(req.PlayerID)
@@ -439,17 +1779,31 @@ func (h *Handlers) JoinTable(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Add player to the game
-	player := g.AddPlayer(req.PlayerID, req.PlayerName, initialBalance)
-	if player == nil {
-		errorResponse(w, http.StatusBadRequest, "Unable to join table")
+	// Seat the player atomically so two players can't take the last seat
+	g, err = h.store.JoinGame(g.ID, game.Player{
+		ID:      req.PlayerID,
+		Name:    req.PlayerName,
+		Balance: initialBalance,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, game.ErrTableFull):
+			errorResponse(w, http.StatusConflict, "Table is full")
+		case errors.Is(err, db.ErrGameNotFound):
+			errorResponse(w, http.StatusNotFound, "Game not found")
+		default:
+			h.logger.Error("Failed to join table", "table", tableID, "player", req.PlayerID, "err", err)
+			errorResponse(w, http.StatusInternalServerError, "Unable to join table")
+		}
 		return
 	}
 
-	// Save game to store
-	if err := h.store.SaveGame(g); err != nil {
-		errorResponse(w, http.StatusInternalServerError, "Failed to update game")
-		return
+	var player *game.Player
+	for i := range g.Players {
+		if g.Players[i].ID == req.PlayerID {
+			player = &g.Players[i]
+			break
+		}
 	}
 
 	// Broadcast player joined to all players in the table
@@ -484,11 +1838,12 @@ func (h *Handlers) LeaveTable(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get active game for this table
-	g, err := h.store.GetActiveTableGame(tableID)
+	g, unlock, err := h.lockTableGame(tableID)
 	if err != nil {
 		errorResponse(w, http.StatusNotFound, "No active game found for table")
 		return
 	}
+	defer unlock()
 
 	// Remove player from game
 	if !g.RemovePlayer(req.PlayerID) {
@@ -496,14 +1851,13 @@ func (h *Handlers) LeaveTable(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// If this was the last player, mark the game as completed
-	if len(g.Players) == 0 {
-		g.Status = game.Completed
-	}
-
 	// Save game to store
 	if err := h.store.SaveGame(g); err != nil {
-		errorResponse(w, http.StatusInternalServerError, "Failed to update game")
+		if errors.Is(err, game.ErrStaleVersion) {
+			errorResponse(w, http.StatusConflict, "Game was changed by another request, please refresh")
+		} else {
+			errorResponse(w, http.StatusInternalServerError, "Failed to update game")
+		}
 		return
 	}
 
@@ -522,42 +1876,59 @@ func (h *Handlers) LeaveTable(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// TableInfo is a table's entry in the lobby list
+type TableInfo struct {
+	ID            string          `json:"id"`
+	PlayerCount   int             `json:"playerCount"`
+	Status        game.GameStatus `json:"status"`
+	MinBet        int             `json:"minBet"`
+	MaxBet        int             `json:"maxBet"`
+	CurrentGameID string          `json:"currentGame"`
+	LastUpdated   string          `json:"lastUpdated"` // RFC 3339
+}
+
 // ListTables returns a list of available tables
 func (h *Handlers) ListTables(w http.ResponseWriter, r *http.Request) {
-	// Get all games
-	allGames, err := h.store.GetAllGames()
+	// Get each table's active game
+	activeGames, err := h.store.GetActiveGames()
 	if err != nil {
 		errorResponse(w, http.StatusInternalServerError, "Error retrieving tables")
 		return
 	}
 
-	// Extract unique table IDs and their info
-	tables := make(map[string]map[string]interface{})
+	tables := make([]TableInfo, 0, len(activeGames))
+	for _, g := range activeGames {
+		tables = append(tables, TableInfo{
+			ID:            g.TableID,
+			PlayerCount:   len(g.Players),
+			Status:        g.Status,
+			MinBet:        g.MinBet,
+			MaxBet:        g.MaxBet,
+			CurrentGameID: g.ID,
+			LastUpdated:   g.UpdatedAt.Format(time.RFC3339),
+		})
+	}
 
-	for _, g := range allGames {
-		// Skip completed games if there's a newer active game for the table
-		if activeGame, _ := h.store.GetActiveTableGame(g.TableID); activeGame != nil && activeGame.ID != g.ID {
-			continue
-		}
+	h.logger.Debug("Listing tables", "count", len(tables))
 
-		tables[g.TableID] = map[string]interface{}{
-			"id":          g.TableID,
-			"playerCount": len(g.Players),
-			"status":      g.Status,
-			"minBet":      g.MinBet,
-			"maxBet":      g.MaxBet,
-			"currentGame": g.ID,
-			"lastUpdated": g.UpdatedAt.Format(time.RFC3339),
-		}
-	}
+	response(w, http.StatusOK, tables)
+}
+
+// GetHouseBalance returns the house's running result at a table
+func (h *Handlers) GetHouseBalance(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	tableID := vars["id"]
 
-	fmt.Println(tables)
+	if h.database == nil {
+		errorResponse(w, http.StatusInternalServerError, "Database not available")
+		return
+	}
 
-	// Convert map to slice for response
-	tablesList := make([]map[string]interface{}, 0, len(tables))
-	for _, tableInfo := range tables {
-		tablesList = append(tablesList, tableInfo)
+	house, err := h.database.GetHouseBalance(tableID)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Error retrieving house balance")
+		return
 	}
 
-	response(w, http.StatusOK, tablesList)
+	response(w, http.StatusOK, house)
 }
@@ -0,0 +1,1211 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/calvinwijaya/card-games-be/internal/db"
+	"github.com/calvinwijaya/card-games-be/internal/game"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+)
+
+// memoryStore keeps games in memory as JSON, so handlers under test load
+// and save copies the way they would with a real store
+type memoryStore struct {
+	mu    sync.Mutex
+	games map[string][]byte
+
+	// onLoad, if set, is called after GetGame loads a game, so tests can
+	// slip in a write from another server
+	onLoad func(id string)
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{games: make(map[string][]byte)}
+}
+
+func (s *memoryStore) load(id string) (*game.BlackjackGame, error) {
+	data, ok := s.games[id]
+	if !ok {
+		return nil, db.ErrGameNotFound
+	}
+
+	var g game.BlackjackGame
+	if err := json.Unmarshal(data, &g); err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+func (s *memoryStore) put(g *game.BlackjackGame) error {
+	data, err := json.Marshal(g)
+	if err != nil {
+		return err
+	}
+	s.games[g.ID] = data
+	return nil
+}
+
+func (s *memoryStore) all() []*game.BlackjackGame {
+	var games []*game.BlackjackGame
+	for id := range s.games {
+		if g, err := s.load(id); err == nil {
+			games = append(games, g)
+		}
+	}
+	return games
+}
+
+func (s *memoryStore) SaveGame(g *game.BlackjackGame) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if stored, err := s.load(g.ID); err == nil && stored.Version != g.Version {
+		return game.ErrStaleVersion
+	}
+	g.Version++
+	return s.put(g)
+}
+
+func (s *memoryStore) GetGame(id string) (*game.BlackjackGame, error) {
+	s.mu.Lock()
+	g, err := s.load(id)
+	onLoad := s.onLoad
+	s.mu.Unlock()
+
+	if onLoad != nil && err == nil {
+		onLoad(id)
+	}
+	return g, err
+}
+
+func (s *memoryStore) GetTableGames(tableID string) ([]*game.BlackjackGame, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var games []*game.BlackjackGame
+	for _, g := range s.all() {
+		if g.TableID == tableID {
+			games = append(games, g)
+		}
+	}
+	return games, nil
+}
+
+func (s *memoryStore) GetActiveTableGame(tableID string) (*game.BlackjackGame, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var active *game.BlackjackGame
+	for _, g := range s.all() {
+		if g.TableID == tableID && g.Status != game.Completed && (active == nil || g.UpdatedAt.After(active.UpdatedAt)) {
+			active = g
+		}
+	}
+	if active == nil {
+		return nil, errors.New("no active game found for table")
+	}
+	return active, nil
+}
+
+func (s *memoryStore) GetActiveGames() ([]*game.BlackjackGame, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	latest := make(map[string]*game.BlackjackGame)
+	for _, g := range s.all() {
+		if g.Status == game.Completed {
+			continue
+		}
+		if current, ok := latest[g.TableID]; !ok || g.UpdatedAt.After(current.UpdatedAt) {
+			latest[g.TableID] = g
+		}
+	}
+
+	var games []*game.BlackjackGame
+	for _, g := range latest {
+		games = append(games, g)
+	}
+	return games, nil
+}
+
+func (s *memoryStore) JoinGame(gameID string, player game.Player) (*game.BlackjackGame, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g, err := s.load(gameID)
+	if err != nil {
+		return nil, err
+	}
+	if !g.HasPlayer(player.ID) && g.IsFull() {
+		return nil, game.ErrTableFull
+	}
+	if g.AddPlayer(player.ID, player.Name, player.Balance) == nil {
+		return nil, errors.New("unable to join game")
+	}
+	g.Version++
+	return g, s.put(g)
+}
+
+func (s *memoryStore) TransitionStatus(gameID string, from, to game.GameStatus) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g, err := s.load(gameID)
+	if err != nil {
+		return false, err
+	}
+	if g.Status != from {
+		return false, nil
+	}
+	g.Status = to
+	return true, s.put(g)
+}
+
+func (s *memoryStore) DeleteGame(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.games, id)
+	return nil
+}
+
+func (s *memoryStore) GetAllGames() ([]*game.BlackjackGame, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.all(), nil
+}
+
+func (s *memoryStore) DeleteStaleGames(olderThan time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := 0
+	for _, g := range s.all() {
+		if (g.Status == game.Waiting || g.Status == game.Completed) && time.Since(g.UpdatedAt) > olderThan {
+			delete(s.games, g.ID)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// testServer is a set of handlers backed by a memory store, with a hub that
+// isn't running so tests can attach clients directly
+type testServer struct {
+	t        *testing.T
+	store    *memoryStore
+	hub      *Hub
+	handlers *Handlers
+	router   *mux.Router
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	s := &testServer{t: t, store: newMemoryStore(), hub: NewHub()}
+	s.handlers = NewHandlers(s.store, nil, s.hub)
+	s.handlers.actionLimiter = nil
+	s.handlers.SetAdminToken(testAdminToken)
+	s.router = mux.NewRouter()
+	s.handlers.RegisterRoutes(s.router)
+	return s
+}
+
+// saveGame stores the game as it stands, failing the test if it can't
+func (s *testServer) saveGame(g *game.BlackjackGame) {
+	s.t.Helper()
+	if err := s.store.SaveGame(g); err != nil {
+		s.t.Fatalf("SaveGame: %v", err)
+	}
+}
+
+// game loads the stored copy of a game
+func (s *testServer) game(id string) *game.BlackjackGame {
+	s.t.Helper()
+	g, err := s.store.GetGame(id)
+	if err != nil {
+		s.t.Fatalf("GetGame(%s): %v", id, err)
+	}
+	return g
+}
+
+// testAdminToken is the admin token test servers accept
+const testAdminToken = "test-admin-token"
+
+// do sends a request with an optional JSON body and decodes the JSON reply
+func (s *testServer) do(method, path string, body interface{}) (int, map[string]interface{}) {
+	s.t.Helper()
+	return s.doWithHeader(nil, method, path, body)
+}
+
+// admin sends a request carrying the admin token
+func (s *testServer) admin(method, path string, body interface{}) (int, map[string]interface{}) {
+	s.t.Helper()
+	return s.doWithHeader(http.Header{"Authorization": {"Bearer " + testAdminToken}}, method, path, body)
+}
+
+// doWithHeader sends a request with the headers set
+func (s *testServer) doWithHeader(header http.Header, method, path string, body interface{}) (int, map[string]interface{}) {
+	s.t.Helper()
+
+	rec := s.record(header, method, path, body)
+	var reply map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &reply)
+	return rec.Code, reply
+}
+
+// record sends a request and returns the recorded response, for tests that
+// look at more than the status and JSON reply
+func (s *testServer) record(header http.Header, method, path string, body interface{}) *httptest.ResponseRecorder {
+	s.t.Helper()
+
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			s.t.Fatalf("encode body: %v", err)
+		}
+	}
+
+	req := httptest.NewRequest(method, path, &buf)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// connect attaches a fake client to the hub, as if the player had opened a
+// WebSocket at the table
+func (s *testServer) connect(tableID, playerID string) *Client {
+	return attachClient(s.hub, tableID, playerID)
+}
+
+// received drains the messages queued for a client
+func received(t *testing.T, c *Client) []Message {
+	t.Helper()
+
+	var messages []Message
+	for {
+		select {
+		case data := <-c.send:
+			var msg Message
+			if err := json.Unmarshal(data, &msg); err != nil {
+				t.Fatalf("decode message: %v", err)
+			}
+			messages = append(messages, msg)
+		default:
+			return messages
+		}
+	}
+}
+
+// findMessage returns the first message of the type, if any
+func findMessage(messages []Message, msgType string) (Message, bool) {
+	for _, msg := range messages {
+		if msg.Type == msgType {
+			return msg, true
+		}
+	}
+	return Message{}, false
+}
+
+// newTestGame creates a game at the table with the players seated, each with
+// a balance of 1000
+func newTestGame(tableID string, playerIDs ...string) *game.BlackjackGame {
+	g := game.NewBlackjackGame(tableID, 10, 500, 1)
+	for _, id := range playerIDs {
+		g.AddPlayer(id, "Player "+id, 1000)
+	}
+	return g
+}
+
+func TestNextRound(t *testing.T) {
+	s := newTestServer(t)
+	g := newTestGame("table-1", "p1")
+	g.Status = game.Completed
+	s.saveGame(g)
+	watcher := s.connect("table-1", "p1")
+
+	status, reply := s.do("POST", "/api/game/"+g.ID+"/next-round", nil)
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (%v)", status, reply)
+	}
+
+	state, _ := reply["game"].(map[string]interface{})
+	if state["status"] != string(game.Betting) {
+		t.Errorf("returned status = %v, want %s", state["status"], game.Betting)
+	}
+	if stored := s.game(g.ID); stored.Status != game.Betting {
+		t.Errorf("stored status = %s, want %s", stored.Status, game.Betting)
+	}
+	if _, ok := findMessage(received(t, watcher), "gameUpdate"); !ok {
+		t.Error("the table wasn't sent the new round")
+	}
+}
+
+func TestNextRoundRefusesUnfinishedRounds(t *testing.T) {
+	for _, status := range []game.GameStatus{game.Waiting, game.Betting, game.InProgress} {
+		t.Run(string(status), func(t *testing.T) {
+			s := newTestServer(t)
+			g := newTestGame("table-1", "p1")
+			g.Status = status
+			s.saveGame(g)
+
+			code, reply := s.do("POST", "/api/game/"+g.ID+"/next-round", nil)
+			if code != http.StatusConflict {
+				t.Errorf("status = %d, want 409 (%v)", code, reply)
+			}
+			if stored := s.game(g.ID); stored.Status != status {
+				t.Errorf("stored status = %s, want it left at %s", stored.Status, status)
+			}
+		})
+	}
+
+	s := newTestServer(t)
+	if code, _ := s.do("POST", "/api/game/missing/next-round", nil); code != http.StatusNotFound {
+		t.Errorf("missing game status = %d, want 404", code)
+	}
+}
+
+func TestGetGameResultRequiresPlayer(t *testing.T) {
+	s := newTestServer(t)
+	g := newTestGame("table-1", "p1")
+	s.saveGame(g)
+
+	if code, _ := s.do("GET", "/api/game/"+g.ID+"/result", nil); code != http.StatusBadRequest {
+		t.Errorf("without playerId: status = %d, want 400", code)
+	}
+}
+
+func TestForceDealer(t *testing.T) {
+	s := newTestServer(t)
+	g := newTestGame("table-1", "p1")
+	g.OpenBetting()
+	g.PlaceBet("p1", 10)
+	g.Start()
+
+	// Wedge the game: nobody is left to act but the dealer never played
+	g.Players[0].Status = game.PlayerStood
+	g.Players[0].IsActive = false
+	g.Status = game.InProgress
+	s.saveGame(g)
+
+	if code, _ := s.do("POST", "/api/game/"+g.ID+"/force-dealer", nil); code != http.StatusUnauthorized {
+		t.Errorf("without the admin token: status = %d, want 401", code)
+	}
+
+	code, reply := s.admin("POST", "/api/game/"+g.ID+"/force-dealer", nil)
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (%v)", code, reply)
+	}
+	if stored := s.game(g.ID); stored.Status != game.Completed {
+		t.Errorf("stored status = %s, want %s", stored.Status, game.Completed)
+	}
+
+	// A completed game has nothing to force
+	if code, _ := s.admin("POST", "/api/game/"+g.ID+"/force-dealer", nil); code != http.StatusConflict {
+		t.Errorf("completed game: status = %d, want 409", code)
+	}
+}
+
+func TestSearchPlayersRequiresQuery(t *testing.T) {
+	s := newTestServer(t)
+
+	for _, query := range []string{"", "a", "%20a%20"} {
+		if code, _ := s.do("GET", "/api/player/search?q="+query, nil); code != http.StatusBadRequest {
+			t.Errorf("q=%q: status = %d, want 400", query, code)
+		}
+	}
+}
+
+// stackDeck puts the cards on top of the game's shoe, to be drawn in order
+func stackDeck(t *testing.T, g *game.BlackjackGame, codes ...string) {
+	t.Helper()
+
+	var stacked []game.Card
+	for _, code := range codes {
+		card, err := game.ParseCard(code)
+		if err != nil {
+			t.Fatalf("ParseCard(%q): %v", code, err)
+		}
+		stacked = append(stacked, card)
+	}
+	g.Deck.Cards = append(stacked, g.Deck.Cards...)
+}
+
+// dealTestGame seats the players, has each bet 10 and deals them the stacked
+// cards: two for each player in turn, then the dealer's two, then whatever
+// the round draws next
+func dealTestGame(t *testing.T, tableID string, playerIDs []string, codes ...string) *game.BlackjackGame {
+	t.Helper()
+
+	g := newTestGame(tableID, playerIDs...)
+	g.OpenBetting()
+	for _, id := range playerIDs {
+		if err := g.PlaceBet(id, 10); err != nil {
+			t.Fatalf("PlaceBet(%s): %v", id, err)
+		}
+	}
+	stackDeck(t, g, codes...)
+	if err := g.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	return g
+}
+
+func TestActionsTargetSplitHand(t *testing.T) {
+	s := newTestServer(t)
+	g := dealTestGame(t, "table-1", []string{"p1"}, "8H", "8S", "10D", "7C", "3H", "2S", "10C", "9C")
+	if !g.Split("p1") {
+		t.Fatal("Split failed")
+	}
+	s.saveGame(g)
+	path := "/api/game/" + g.ID
+
+	steps := []struct {
+		action    string
+		handIndex int
+		want      int
+	}{
+		{"hit", 1, http.StatusBadRequest}, // Hand 0 is still being played
+		{"hit", 2, http.StatusBadRequest}, // No such hand
+		{"hit", 0, http.StatusOK},         // 8 3 10
+		{"stand", 0, http.StatusOK},
+		{"stand", 0, http.StatusBadRequest}, // Play has moved on to hand 1
+		{"hit", 1, http.StatusOK},           // 8 2 9
+		{"stand", 1, http.StatusOK},
+	}
+	for _, step := range steps {
+		code, reply := s.do("POST", path+"/"+step.action, map[string]interface{}{"playerId": "p1", "handIndex": step.handIndex})
+		if code != step.want {
+			t.Fatalf("%s hand %d: status = %d, want %d (%v)", step.action, step.handIndex, code, step.want, reply)
+		}
+	}
+
+	stored := s.game(g.ID)
+	hands := stored.Players[0].Hands
+	if hands[0].Score != 21 || hands[1].Score != 19 {
+		t.Errorf("hand scores = %d and %d, want 21 and 19", hands[0].Score, hands[1].Score)
+	}
+	if stored.Status != game.Completed || stored.Players[0].Balance != 1020 {
+		t.Errorf("status %s with balance %d, want completed with 1020", stored.Status, stored.Players[0].Balance)
+	}
+}
+
+func TestTurnNotificationTargetsNextPlayer(t *testing.T) {
+	s := newTestServer(t)
+	g := dealTestGame(t, "table-1", []string{"p1", "p2"}, "10H", "7S", "10D", "8C", "10S", "7D")
+	g.DrainEvents()
+	s.saveGame(g)
+	p1, p2 := s.connect("table-1", "p1"), s.connect("table-1", "p2")
+
+	if code, reply := s.do("POST", "/api/game/"+g.ID+"/stand", map[string]string{"playerId": "p1"}); code != http.StatusOK {
+		t.Fatalf("stand: status = %d (%v)", code, reply)
+	}
+
+	p1Messages, p2Messages := received(t, p1), received(t, p2)
+	if _, ok := findMessage(p1Messages, "yourTurn"); ok {
+		t.Error("p1 was told it's their turn after standing")
+	}
+	turn, ok := findMessage(p2Messages, "yourTurn")
+	if !ok || turn.PlayerID != "p2" {
+		t.Fatalf("p2 got yourTurn = %v (%+v), want it addressed to p2", ok, turn)
+	}
+
+	// The whole table hears whose turn it is
+	for name, msgs := range map[string][]Message{"p1": p1Messages, "p2": p2Messages} {
+		if changed, ok := findMessage(msgs, "turnChanged"); !ok || changed.PlayerID != "p2" {
+			t.Errorf("%s got turnChanged = %v (%+v), want it naming p2", name, ok, changed)
+		}
+	}
+}
+
+func TestStartGame(t *testing.T) {
+	s := newTestServer(t)
+	g := newTestGame("table-1", "p1")
+	g.OpenBetting()
+	g.PlaceBet("p1", 10)
+	stackDeck(t, g, "10H", "7S", "10D", "8C")
+	s.saveGame(g)
+	watcher := s.connect("table-1", "p1")
+
+	code, reply := s.do("POST", "/api/game/"+g.ID+"/start?playerId=p1", nil)
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (%v)", code, reply)
+	}
+	stored := s.game(g.ID)
+	if stored.Status != game.InProgress || len(stored.Players[0].Hand) != 2 {
+		t.Errorf("stored game is %s with %d cards, want in progress with 2", stored.Status, len(stored.Players[0].Hand))
+	}
+	if _, ok := findMessage(received(t, watcher), "gameUpdate"); !ok {
+		t.Error("the table wasn't sent the deal")
+	}
+
+	// The round can't be dealt twice
+	if code, _ := s.do("POST", "/api/game/"+g.ID+"/start", nil); code != http.StatusBadRequest {
+		t.Errorf("second start: status = %d, want 400", code)
+	}
+}
+
+func TestOpenBetting(t *testing.T) {
+	tests := []struct {
+		name    string
+		players []string
+		status  game.GameStatus
+		want    int
+	}{
+		{"waiting table", []string{"p1"}, game.Waiting, http.StatusOK},
+		{"empty table", nil, game.Waiting, http.StatusBadRequest},
+		{"already betting", []string{"p1"}, game.Betting, http.StatusBadRequest},
+		{"in progress", []string{"p1"}, game.InProgress, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			g := newTestGame("table-1", tt.players...)
+			g.Status = tt.status
+			s.saveGame(g)
+			watcher := s.connect("table-1", "")
+
+			code, reply := s.do("POST", "/api/game/"+g.ID+"/open-betting", nil)
+			if code != tt.want {
+				t.Fatalf("status = %d, want %d (%v)", code, tt.want, reply)
+			}
+
+			_, announced := findMessage(received(t, watcher), "bettingOpened")
+			if opened := code == http.StatusOK; announced != opened {
+				t.Errorf("bettingOpened sent = %v, want %v", announced, opened)
+			}
+			if code == http.StatusOK && s.game(g.ID).Status != game.Betting {
+				t.Errorf("stored status = %s, want %s", s.game(g.ID).Status, game.Betting)
+			}
+		})
+	}
+}
+
+func TestGetServerTime(t *testing.T) {
+	s := newTestServer(t)
+
+	var last float64
+	for i := 0; i < 3; i++ {
+		before := time.Now().UnixMilli()
+		code, reply := s.do("GET", "/api/time", nil)
+		now, ok := reply["serverTime"].(float64)
+		if code != http.StatusOK || !ok {
+			t.Fatalf("status = %d with %v, want 200 with a serverTime", code, reply)
+		}
+		if int64(now) < before || now < last {
+			t.Errorf("serverTime went from %v to %v, want it to keep up with the clock at %d", last, now, before)
+		}
+		last = now
+	}
+}
+
+func TestListTablesLogsOnlyAtDebug(t *testing.T) {
+	tests := []struct {
+		level    slog.Level
+		wantLogs bool
+	}{
+		{slog.LevelInfo, false},
+		{slog.LevelDebug, true},
+	}
+
+	for _, tt := range tests {
+		s := newTestServer(t)
+		s.saveGame(newTestGame("table-1", "p1"))
+		var buf bytes.Buffer
+		s.handlers.SetLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: tt.level})))
+
+		if code, _ := s.do("GET", "/api/table/list", nil); code != http.StatusOK {
+			t.Fatalf("status = %d, want 200", code)
+		}
+		if logged := buf.Len() > 0; logged != tt.wantLogs {
+			t.Errorf("at %s: logged %q, want output = %v", tt.level, buf.String(), tt.wantLogs)
+		}
+	}
+}
+
+// countingStore counts the store reads a table listing could make
+type countingStore struct {
+	*memoryStore
+	queries int64
+}
+
+func (s *countingStore) GetAllGames() ([]*game.BlackjackGame, error) {
+	atomic.AddInt64(&s.queries, 1)
+	return s.memoryStore.GetAllGames()
+}
+
+func (s *countingStore) GetTableGames(tableID string) ([]*game.BlackjackGame, error) {
+	atomic.AddInt64(&s.queries, 1)
+	return s.memoryStore.GetTableGames(tableID)
+}
+
+func (s *countingStore) GetActiveTableGame(tableID string) (*game.BlackjackGame, error) {
+	atomic.AddInt64(&s.queries, 1)
+	return s.memoryStore.GetActiveTableGame(tableID)
+}
+
+func (s *countingStore) GetActiveGames() ([]*game.BlackjackGame, error) {
+	atomic.AddInt64(&s.queries, 1)
+	return s.memoryStore.GetActiveGames()
+}
+
+// newCountingStore holds an active game at each of the tables, along with a
+// finished one that shouldn't be listed
+func newCountingStore(tables int) *countingStore {
+	s := &countingStore{memoryStore: newMemoryStore()}
+	for i := 0; i < tables; i++ {
+		tableID := fmt.Sprintf("table-%d", i)
+		done := newTestGame(tableID, "p1")
+		done.Status = game.Completed
+		s.put(done)
+		s.put(newTestGame(tableID, "p1"))
+	}
+	return s
+}
+
+func TestListTablesQueriesOnce(t *testing.T) {
+	for _, tables := range []int{0, 1, 5, 20} {
+		t.Run(fmt.Sprintf("%d tables", tables), func(t *testing.T) {
+			store := newCountingStore(tables)
+			router := mux.NewRouter()
+			NewHandlers(store, nil, NewHub()).RegisterRoutes(router)
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/table/list", nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", rec.Code)
+			}
+
+			var listed []TableInfo
+			if err := json.Unmarshal(rec.Body.Bytes(), &listed); err != nil {
+				t.Fatalf("decode tables: %v", err)
+			}
+			if len(listed) != tables {
+				t.Errorf("listed %d tables, want %d", len(listed), tables)
+			}
+			if store.queries != 1 {
+				t.Errorf("made %d store queries, want 1 whatever the number of tables", store.queries)
+			}
+		})
+	}
+}
+
+// BenchmarkListTables reports the store queries a table listing makes, with
+// GetActiveGames against the query per table it replaced
+func BenchmarkListTables(b *testing.B) {
+	for _, tables := range []int{10, 50} {
+		b.Run(fmt.Sprintf("active games/%d tables", tables), func(b *testing.B) {
+			store := newCountingStore(tables)
+			router := mux.NewRouter()
+			NewHandlers(store, nil, NewHub()).RegisterRoutes(router)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/table/list", nil))
+			}
+			b.ReportMetric(float64(store.queries)/float64(b.N), "queries/op")
+		})
+
+		b.Run(fmt.Sprintf("per table/%d tables", tables), func(b *testing.B) {
+			store := newCountingStore(tables)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				games, err := store.GetAllGames()
+				if err != nil {
+					b.Fatal(err)
+				}
+				for _, g := range games {
+					store.GetActiveTableGame(g.TableID)
+				}
+			}
+			b.ReportMetric(float64(store.queries)/float64(b.N), "queries/op")
+		})
+	}
+}
+
+func TestStrangerCannotAct(t *testing.T) {
+	tests := []struct {
+		action  string
+		dealt   bool
+		request map[string]interface{}
+	}{
+		{"hit", true, nil},
+		{"stand", true, nil},
+		{"double", true, nil},
+		{"split", true, nil},
+		{"surrender", true, nil},
+		{"bet", false, map[string]interface{}{"amount": 10}},
+		{"bet/undo", false, nil},
+		{"sidebet", false, map[string]interface{}{"type": game.SideBetPerfectPairs, "amount": 5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			s := newTestServer(t)
+			var g *game.BlackjackGame
+			if tt.dealt {
+				g = dealTestGame(t, "table-1", []string{"p1"}, "8H", "8S", "10D", "7C")
+			} else {
+				g = newTestGame("table-1", "p1")
+				g.OpenBetting()
+			}
+			s.saveGame(g)
+			version := s.game(g.ID).Version
+
+			body := map[string]interface{}{"playerId": "stranger"}
+			for key, value := range tt.request {
+				body[key] = value
+			}
+			code, reply := s.do("POST", "/api/game/"+g.ID+"/"+tt.action, body)
+			if code != http.StatusForbidden || reply["error"] != notParticipantMessage {
+				t.Errorf("status = %d (%v), want 403 %q", code, reply, notParticipantMessage)
+			}
+			if s.game(g.ID).Version != version {
+				t.Error("the stranger's request changed the game")
+			}
+		})
+	}
+}
+
+func TestHealthAndReadiness(t *testing.T) {
+	s := newTestServer(t)
+
+	code, reply := s.do("GET", "/healthz", nil)
+	if code != http.StatusOK || reply["status"] != "ok" || reply["store"] != "*api.memoryStore" {
+		t.Errorf("healthz = %d %v, want ok with the store type", code, reply)
+	}
+	if _, ok := reply["uptimeSeconds"].(float64); !ok {
+		t.Errorf("healthz = %v, want the uptime", reply)
+	}
+
+	// Without a database the server is alive but not ready
+	if code, reply := s.do("GET", "/readyz", nil); code != http.StatusServiceUnavailable {
+		t.Errorf("readyz = %d %v, want 503", code, reply)
+	}
+}
+
+func TestNewGameDeterministicNotRanked(t *testing.T) {
+	tests := []struct {
+		name     string
+		practice bool
+		ranked   bool
+		want     int
+	}{
+		{"practice table", true, false, http.StatusCreated},
+		{"casual table", false, false, http.StatusCreated},
+		{"ranked table", false, true, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			code, reply := s.do("POST", "/api/game/new", map[string]interface{}{
+				"tableId":      "table-1",
+				"minBet":       10,
+				"maxBet":       500,
+				"practice":     tt.practice,
+				"ranked":       tt.ranked,
+				"fairnessMode": game.FairnessDeterministic,
+				"seed":         7,
+			})
+			if code != tt.want {
+				t.Errorf("status = %d (%v), want %d", code, reply, tt.want)
+			}
+		})
+	}
+}
+
+func TestJoinTableFull(t *testing.T) {
+	s := newTestServer(t)
+	g := newTestGame("table-1", "p1", "p2")
+	g.MaxPlayers = 2
+	s.saveGame(g)
+
+	tests := []struct {
+		playerID string
+		want     int
+	}{
+		{"p3", http.StatusConflict},
+		{"p1", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		code, reply := s.do("POST", "/api/table/table-1/join", map[string]interface{}{"playerId": tt.playerID, "playerName": "Player"})
+		if code != tt.want {
+			t.Errorf("%s joining: status = %d (%v), want %d", tt.playerID, code, reply, tt.want)
+		}
+		if code == http.StatusConflict && reply["error"] != "Table is full" {
+			t.Errorf("%s joining: error = %v, want table full", tt.playerID, reply["error"])
+		}
+	}
+	if players := s.game(g.ID).Players; len(players) != 2 {
+		t.Errorf("%d players seated, want 2", len(players))
+	}
+}
+
+func TestKickActivePlayer(t *testing.T) {
+	s := newTestServer(t)
+	g := dealTestGame(t, "table-1", []string{"p1", "p2"}, "10H", "6D", "9S", "7C", "10D", "7H", "2D")
+	s.saveGame(g)
+	path := "/api/game/" + g.ID
+
+	if code, _ := s.do("POST", path+"/kick", map[string]interface{}{"playerId": "p1"}); code != http.StatusUnauthorized {
+		t.Errorf("kick without the admin token = %d, want 401", code)
+	}
+	if code, reply := s.admin("POST", path+"/kick", map[string]interface{}{"playerId": "p1"}); code != http.StatusOK {
+		t.Fatalf("kick = %d (%v), want 200", code, reply)
+	}
+
+	stored := s.game(g.ID)
+	if stored.HasPlayer("p1") || stored.Players[stored.CurrentPlayerIndex].ID != "p2" {
+		t.Fatal("the turn didn't pass to p2")
+	}
+
+	// p2 can play on to the end of the round
+	if code, reply := s.do("POST", path+"/hit", map[string]interface{}{"playerId": "p2"}); code != http.StatusOK {
+		t.Fatalf("p2 hit = %d (%v), want 200", code, reply)
+	}
+	if code, reply := s.do("POST", path+"/stand", map[string]interface{}{"playerId": "p2"}); code != http.StatusOK {
+		t.Fatalf("p2 stand = %d (%v), want 200", code, reply)
+	}
+	if status := s.game(g.ID).Status; status != game.Completed {
+		t.Errorf("status = %s, want the round completed", status)
+	}
+}
+
+func TestGetAdvice(t *testing.T) {
+	s := newTestServer(t)
+	g := dealTestGame(t, "table-1", []string{"p1", "p2"}, "10H", "6D", "AS", "7C", "KC", "5S")
+	s.saveGame(g)
+	path := "/api/game/" + g.ID + "/advice?playerId="
+
+	tests := []struct {
+		playerID string
+		want     int
+		advice   string
+	}{
+		{"p1", http.StatusOK, "hit"},
+		{"p2", http.StatusConflict, ""},
+		{"stranger", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		code, reply := s.do("GET", path+tt.playerID, nil)
+		if code != tt.want || (tt.advice != "" && reply["advice"] != tt.advice) {
+			t.Errorf("advice for %s = %d %v, want %d %q", tt.playerID, code, reply, tt.want, tt.advice)
+		}
+	}
+}
+
+func TestNewGameRules(t *testing.T) {
+	s := newTestServer(t)
+	code, reply := s.do("POST", "/api/game/new", map[string]interface{}{
+		"tableId":          "table-1",
+		"numDecks":         2,
+		"dealerHitsSoft17": true,
+		"surrender":        true,
+		"doubleAfterSplit": false,
+		"maxSplits":        3,
+	})
+	if code != http.StatusCreated {
+		t.Fatalf("status = %d (%v), want 201", code, reply)
+	}
+
+	g, err := s.store.GetActiveTableGame("table-1")
+	if err != nil {
+		t.Fatalf("GetActiveTableGame: %v", err)
+	}
+	want := game.RuleSet{NumDecks: 2, DealerHitsSoft17: true, SurrenderAllowed: true, MaxSplits: 3, Payouts: game.DefaultPayouts}
+	if g.RuleSet != want {
+		t.Errorf("rules = %+v, want %+v", g.RuleSet, want)
+	}
+	if len(g.Deck.Cards) != 104 {
+		t.Errorf("%d cards in the shoe, want two decks", len(g.Deck.Cards))
+	}
+
+	// Rules left out keep their defaults
+	s.do("POST", "/api/game/new", map[string]interface{}{"tableId": "table-2"})
+	if g, _ := s.store.GetActiveTableGame("table-2"); g == nil || g.RuleSet != game.DefaultRuleSet {
+		t.Errorf("rules = %+v, want the defaults", g)
+	}
+
+	if code, _ := s.do("POST", "/api/game/new", map[string]interface{}{"maxSplits": -1}); code != http.StatusBadRequest {
+		t.Errorf("negative maxSplits = %d, want 400", code)
+	}
+}
+
+func TestGameCreatedHidesDeck(t *testing.T) {
+	s := newTestServer(t)
+	watcher := s.connect("table-1", "")
+
+	code, reply := s.do("POST", "/api/game/new", map[string]interface{}{"tableId": "table-1"})
+	if code != http.StatusCreated {
+		t.Fatalf("status = %d (%v), want 201", code, reply)
+	}
+	msg, ok := findMessage(received(t, watcher), "gameCreated")
+	if !ok {
+		t.Fatal("no gameCreated broadcast")
+	}
+
+	for name, data := range map[string]interface{}{"broadcast": msg.Data, "response": reply} {
+		state, _ := data.(map[string]interface{})
+		if state["id"] == nil {
+			t.Errorf("the %s = %v, want the game state", name, data)
+		}
+		for _, key := range []string{"deck", "actions"} {
+			if _, leaked := state[key]; leaked {
+				t.Errorf("the %s includes %q", name, key)
+			}
+		}
+	}
+}
+
+func TestDeleteGame(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  game.GameStatus
+		query   string
+		want    int
+		deleted bool
+	}{
+		{"completed", game.Completed, "", http.StatusOK, true},
+		{"waiting", game.Waiting, "", http.StatusOK, true},
+		{"in progress", game.InProgress, "", http.StatusConflict, false},
+		{"in progress, forced", game.InProgress, "?force=true", http.StatusOK, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			g := newTestGame("table-1", "p1")
+			g.Status = tt.status
+			s.saveGame(g)
+			watcher := s.connect("table-1", "p1")
+
+			code, reply := s.admin("DELETE", "/api/game/"+g.ID+tt.query, nil)
+			if code != tt.want {
+				t.Fatalf("status = %d (%v), want %d", code, reply, tt.want)
+			}
+			if _, err := s.store.GetGame(g.ID); (err != nil) != tt.deleted {
+				t.Errorf("deleted = %v, want %v", err != nil, tt.deleted)
+			}
+			msg, broadcast := findMessage(received(t, watcher), "gameDeleted")
+			if broadcast != tt.deleted || (broadcast && msg.GameID != g.ID) {
+				t.Errorf("gameDeleted broadcast = %v (%+v), want %v", broadcast, msg, tt.deleted)
+			}
+		})
+	}
+}
+
+func TestDeleteGameNeedsAdmin(t *testing.T) {
+	s := newTestServer(t)
+	g := newTestGame("table-1", "p1")
+	s.saveGame(g)
+
+	if code, _ := s.do("DELETE", "/api/game/"+g.ID, nil); code != http.StatusUnauthorized {
+		t.Errorf("delete without the admin token = %d, want 401", code)
+	}
+	if code, _ := s.admin("DELETE", "/api/game/missing", nil); code != http.StatusNotFound {
+		t.Errorf("deleting a missing game = %d, want 404", code)
+	}
+
+	// The game is still there
+	s.game(g.ID)
+}
+
+func TestAnnounceReachesEveryClient(t *testing.T) {
+	s := newTestServer(t)
+	url := serveHub(t, s.hub)
+
+	var conns []*websocket.Conn
+	for _, query := range []string{
+		"playerId=p1&tableId=table-1",
+		"playerId=p2&tableId=table-2",
+		"tableId=table-1",
+		"playerId=p3",
+	} {
+		conns = append(conns, dialHub(t, url, query))
+	}
+	waitForClients(t, s.hub, len(conns))
+
+	if code, _ := s.do("POST", "/api/announce", map[string]interface{}{"message": "restarting soon"}); code != http.StatusUnauthorized {
+		t.Errorf("announce without the admin token = %d, want 401", code)
+	}
+	if code, _ := s.admin("POST", "/api/announce", map[string]interface{}{"message": "  "}); code != http.StatusBadRequest {
+		t.Errorf("blank announcement = %d, want 400", code)
+	}
+	if code, reply := s.admin("POST", "/api/announce", map[string]interface{}{"message": " Restarting in 5 minutes "}); code != http.StatusOK {
+		t.Fatalf("announce = %d (%v), want 200", code, reply)
+	}
+
+	for i, conn := range conns {
+		for {
+			msg, err := readMessage(t, conn)
+			if err != nil {
+				t.Fatalf("client %d: %v before the announcement", i, err)
+			}
+			if msg.Type != "announcement" {
+				continue
+			}
+			if text := msg.Data.(map[string]interface{})["message"]; text != "Restarting in 5 minutes" {
+				t.Errorf("client %d got %q", i, text)
+			}
+			break
+		}
+	}
+}
+
+func TestStartFailureReasons(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(g *game.BlackjackGame)
+		want  string
+	}{
+		{"not betting", func(g *game.BlackjackGame) {
+			g.AddPlayer("p1", "Player p1", 1000)
+		}, "Game is not in the betting phase"},
+		{"no players", func(g *game.BlackjackGame) {
+			g.Status = game.Betting
+		}, "No players at the table"},
+		{"missing bet", func(g *game.BlackjackGame) {
+			g.AddPlayer("p1", "Player p1", 1000)
+			g.AddPlayer("p2", "Player p2", 1000)
+			g.OpenBetting()
+			g.PlaceBet("p1", 10)
+		}, "Not all players have placed a bet"},
+		{"not ready", func(g *game.BlackjackGame) {
+			g.RequireReady = true
+			g.AddPlayer("p1", "Player p1", 1000)
+			g.OpenBetting()
+			g.PlaceBet("p1", 10)
+		}, "Not all players are ready"},
+		{"payout cap", func(g *game.BlackjackGame) {
+			g.MaxRoundPayout = 249
+			g.AddPlayer("p1", "Player p1", 1000)
+			g.OpenBetting()
+			g.PlaceBet("p1", 100)
+		}, "Total bets exceed the table's payout cap"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			g := game.NewBlackjackGame("table-1", 10, 500, 1)
+			tt.setup(g)
+			s.saveGame(g)
+
+			code, reply := s.do("POST", "/api/game/"+g.ID+"/start", nil)
+			if code != http.StatusBadRequest || reply["error"] != tt.want {
+				t.Errorf("start = %d %v, want 400 %q", code, reply, tt.want)
+			}
+			if status := s.game(g.ID).Status; status != g.Status {
+				t.Errorf("status = %s, want it left at %s", status, g.Status)
+			}
+		})
+	}
+}
+
+func TestGetGameForNonParticipants(t *testing.T) {
+	s := newTestServer(t)
+	g := dealTestGame(t, "table-1", []string{"p1", "p2"}, "9H", "7D", "10S", "8C", "5H", "QC")
+	s.saveGame(g)
+
+	tests := []struct {
+		playerID string
+		wantSeen string // Player whose cards and balance may be seen, if any
+	}{
+		{"", ""},
+		{"stranger", ""},
+		{"p1", "p1"},
+	}
+
+	for _, tt := range tests {
+		code, reply := s.do("GET", "/api/game/"+g.ID+"?playerId="+tt.playerID, nil)
+		if code != http.StatusOK {
+			t.Fatalf("%q: status = %d (%v), want 200", tt.playerID, code, reply)
+		}
+
+		view := decodeView(t, Message{Data: reply})
+		if view.Dealer.Hand[1].Face {
+			t.Errorf("%q sees the dealer's hole card", tt.playerID)
+		}
+		for _, p := range view.Players {
+			seen := p.ID == tt.wantSeen
+			if shown := p.Balance != nil; shown != seen {
+				t.Errorf("%q sees %s's balance = %v, want %v", tt.playerID, p.ID, shown, seen)
+			}
+			if shown := p.Hand[0].Face; shown != seen {
+				t.Errorf("%q sees %s's cards = %v, want %v", tt.playerID, p.ID, shown, seen)
+			}
+		}
+	}
+}
+
+func TestGetGameDebug(t *testing.T) {
+	tests := []struct {
+		name  string
+		debug bool
+		admin bool
+		want  int
+	}{
+		{"debug off", false, true, http.StatusNotFound},
+		{"debug on", true, true, http.StatusOK},
+		{"debug on without the admin token", true, false, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			s.handlers.SetDebug(tt.debug)
+			g := dealTestGame(t, "table-1", []string{"p1"}, "9H", "7D", "5H", "QC", "3S", "8D")
+			s.saveGame(g)
+
+			send := s.do
+			if tt.admin {
+				send = s.admin
+			}
+			code, reply := send("GET", "/api/game/"+g.ID+"/debug", nil)
+			if code != tt.want {
+				t.Fatalf("status = %d (%v), want %d", code, reply, tt.want)
+			}
+			if code != http.StatusOK {
+				return
+			}
+
+			// The raw game, hole card and the shoe's order included
+			var raw game.BlackjackGame
+			data, _ := json.Marshal(reply)
+			if err := json.Unmarshal(data, &raw); err != nil {
+				t.Fatalf("decode game: %v", err)
+			}
+			if raw.Deck == nil || len(raw.Deck.Cards) != len(g.Deck.Cards) {
+				t.Fatal("debug state is missing the deck")
+			}
+			if got := handCodes(raw.Deck.Cards[:2]); !reflect.DeepEqual(got, []string{"3S", "8D"}) {
+				t.Errorf("top of the shoe = %v, want [3S 8D]", got)
+			}
+			// It's still face down, but the card itself is there
+			hole := raw.Dealer.Hand[1]
+			hole.Face = true
+			if got := hole.String(); got != "QC" {
+				t.Errorf("dealer's hole card = %s, want QC", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// DefaultIdempotencyTTL is how long a response is kept for replay
+const DefaultIdempotencyTTL = 10 * time.Minute
+
+// maxActionBody bounds the request body read to find the acting player
+const maxActionBody = 1 << 20
+
+// idempotencyStore remembers the responses to requests sent with an
+// Idempotency-Key header, so a retried request gets the original response
+// instead of being applied twice
+type idempotencyStore struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]*idempotentResponse
+}
+
+// idempotentResponse is a recorded response. done is closed once it has been
+// recorded, so a retry that arrives while the original is still running
+// waits for it.
+type idempotentResponse struct {
+	done    chan struct{}
+	expires time.Time
+	header  http.Header
+	status  int
+	body    []byte
+}
+
+// newIdempotencyStore creates a store that keeps responses for ttl
+func newIdempotencyStore(ttl time.Duration) *idempotencyStore {
+	return &idempotencyStore{
+		ttl:     ttl,
+		entries: make(map[string]*idempotentResponse),
+	}
+}
+
+// begin returns the entry for key and whether the caller is the first to use
+// it, and so must run the request and record the response
+func (s *idempotencyStore) begin(key string) (*idempotentResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for k, e := range s.entries {
+		if !e.expires.IsZero() && now.After(e.expires) {
+			delete(s.entries, k)
+		}
+	}
+
+	if e, ok := s.entries[key]; ok {
+		return e, false
+	}
+
+	e := &idempotentResponse{done: make(chan struct{})}
+	s.entries[key] = e
+	return e, true
+}
+
+// finish records the response for key and releases any waiting retries.
+// Server errors aren't kept, so a later retry runs the request again.
+func (s *idempotencyStore) finish(key string, e *idempotentResponse, buf *bufferedResponse) {
+	s.mu.Lock()
+	e.header = buf.header.Clone()
+	e.status = buf.status
+	e.body = buf.body.Bytes()
+	e.expires = time.Now().Add(s.ttl)
+	if buf.status >= http.StatusInternalServerError {
+		delete(s.entries, key)
+	}
+	s.mu.Unlock()
+
+	close(e.done)
+}
+
+// SetIdempotencyTTL sets how long responses are kept for requests sent with
+// an Idempotency-Key header
+func (h *Handlers) SetIdempotencyTTL(ttl time.Duration) {
+	h.idempotency = newIdempotencyStore(ttl)
+}
+
+// idempotent makes a game action safe to retry. When the request has an
+// Idempotency-Key header, the response is recorded per key, game and player,
+// and replayed for repeats of the same request instead of acting again.
+func (h *Handlers) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" || h.idempotency == nil {
+			next(w, r)
+			return
+		}
+
+		playerID, err := requestPlayerID(r)
+		if err != nil {
+			errorResponse(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		cacheKey := key + "|" + mux.Vars(r)["id"] + "|" + playerID
+		entry, first := h.idempotency.begin(cacheKey)
+		if !first {
+			<-entry.done
+			for k, v := range entry.header {
+				w.Header()[k] = v
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(entry.status)
+			w.Write(entry.body)
+			return
+		}
+
+		buf := &bufferedResponse{header: make(http.Header), status: http.StatusOK}
+		next(buf, r)
+		h.idempotency.finish(cacheKey, entry, buf)
+
+		for k, v := range buf.header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(buf.status)
+		w.Write(buf.body.Bytes())
+	}
+}
+
+// requestPlayerID returns the playerId in a JSON request body, leaving the
+// body in place for the handler
+func requestPlayerID(r *http.Request) (string, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxActionBody))
+	if err != nil {
+		return "", err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	var req struct {
+		PlayerID string `json:"playerId"`
+	}
+	json.Unmarshal(body, &req)
+	return req.PlayerID, nil
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/calvinwijaya/card-games-be/internal/game"
+)
+
+func TestIdempotentRetryAppliesOnce(t *testing.T) {
+	tests := []struct {
+		action      string
+		dealt       bool
+		request     map[string]interface{}
+		wantBalance int
+	}{
+		{"bet", false, map[string]interface{}{"amount": 25}, 975},
+		{"double", true, nil, 980},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			s := newTestServer(t)
+			var g *game.BlackjackGame
+			if tt.dealt {
+				// 5 and 6 to double on, against the dealer's 10 7
+				g = dealTestGame(t, "table-1", []string{"p1"}, "5H", "6S", "10D", "7C", "2H")
+			} else {
+				g = newTestGame("table-1", "p1")
+				g.OpenBetting()
+			}
+			s.saveGame(g)
+
+			body := map[string]interface{}{"playerId": "p1"}
+			for key, value := range tt.request {
+				body[key] = value
+			}
+			header := http.Header{"Idempotency-Key": {"retry-1"}}
+			path := "/api/game/" + g.ID + "/" + tt.action
+
+			first := s.record(header, "POST", path, body)
+			retry := s.record(header, "POST", path, body)
+			if first.Code != http.StatusOK {
+				t.Fatalf("first request: status = %d (%s)", first.Code, first.Body)
+			}
+			if retry.Code != first.Code || !bytes.Equal(retry.Body.Bytes(), first.Body.Bytes()) {
+				t.Errorf("retry got %d %s, want the original response", retry.Code, retry.Body)
+			}
+			if retry.Header().Get("Idempotent-Replayed") != "true" {
+				t.Error("retry wasn't marked as replayed")
+			}
+			if got := s.game(g.ID).Players[0].Balance; got != tt.wantBalance {
+				t.Errorf("balance = %d, want %d after one charge", got, tt.wantBalance)
+			}
+		})
+	}
+}
+
+func TestIdempotencyKeyScope(t *testing.T) {
+	s := newTestServer(t)
+	g := newTestGame("table-1", "p1", "p2")
+	g.OpenBetting()
+	s.saveGame(g)
+	header := http.Header{"Idempotency-Key": {"same-key"}}
+	path := "/api/game/" + g.ID + "/bet"
+
+	// Another player's request with the same key is their own
+	for _, id := range []string{"p1", "p2"} {
+		rec := s.record(header, "POST", path, map[string]interface{}{"playerId": id, "amount": 10})
+		if rec.Code != http.StatusOK || rec.Header().Get("Idempotent-Replayed") != "" {
+			t.Errorf("%s: status = %d, replayed = %q, want the bet placed", id, rec.Code, rec.Header().Get("Idempotent-Replayed"))
+		}
+	}
+	for _, p := range s.game(g.ID).Players {
+		if p.Bet != 10 {
+			t.Errorf("%s's bet = %d, want 10", p.ID, p.Bet)
+		}
+	}
+}
+
+func TestIdempotencyExpires(t *testing.T) {
+	s := newTestServer(t)
+	s.handlers.SetIdempotencyTTL(time.Millisecond)
+	g := newTestGame("table-1", "p1")
+	g.OpenBetting()
+	s.saveGame(g)
+	header := http.Header{"Idempotency-Key": {"retry-1"}}
+	path := "/api/game/" + g.ID + "/bet/undo"
+
+	s.record(header, "POST", path, map[string]string{"playerId": "p1"})
+	time.Sleep(5 * time.Millisecond)
+
+	// Once the response has expired the request runs again
+	rec := s.record(header, "POST", path, map[string]string{"playerId": "p1"})
+	if rec.Header().Get("Idempotent-Replayed") != "" {
+		t.Error("an expired response was replayed")
+	}
+}
@@ -0,0 +1,51 @@
+package api
+
+import "sync"
+
+// gameLocks serializes actions on the same game, so two requests can't load,
+// mutate and save a game at the same time and overwrite each other.
+//
+// Locking order: a handler holds at most one game lock at a time and takes it
+// before loading the game. Table-level operations find the table's game
+// first, then lock it and reload it by ID, so they follow the same order.
+// Hub locks may be taken while a game lock is held (to broadcast), but a game
+// lock must never be taken while holding a hub lock.
+type gameLocks struct {
+	mu    sync.Mutex
+	locks map[string]*gameLock
+}
+
+// gameLock is a game's mutex and how many callers hold or wait for it
+type gameLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// lock acquires the game's lock and returns the function that releases it.
+// Locks are dropped once nobody holds or waits for them.
+func (l *gameLocks) lock(gameID string) func() {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[string]*gameLock)
+	}
+	entry, ok := l.locks[gameID]
+	if !ok {
+		entry = &gameLock{}
+		l.locks[gameID] = entry
+	}
+	entry.refs++
+	l.mu.Unlock()
+
+	entry.mu.Lock()
+
+	return func() {
+		entry.mu.Unlock()
+
+		l.mu.Lock()
+		entry.refs--
+		if entry.refs == 0 {
+			delete(l.locks, gameID)
+		}
+		l.mu.Unlock()
+	}
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/calvinwijaya/card-games-be/internal/game"
+)
+
+func TestGameLocks(t *testing.T) {
+	var locks gameLocks
+
+	// Holders of the same game's lock take turns
+	const workers = 50
+	var wg sync.WaitGroup
+	inside := 0
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock := locks.lock("game-1")
+			defer unlock()
+
+			inside++
+			if inside != 1 {
+				t.Errorf("%d holders of the lock at once", inside)
+			}
+			inside--
+		}()
+	}
+	wg.Wait()
+
+	// Another game's lock doesn't wait on it
+	unlock := locks.lock("game-1")
+	locks.lock("game-2")()
+	unlock()
+
+	if len(locks.locks) != 0 {
+		t.Errorf("%d locks left after every holder released them", len(locks.locks))
+	}
+}
+
+func TestConcurrentHitsDrawOnce(t *testing.T) {
+	s := newTestServer(t)
+
+	// Small cards, so a run of hits can be taken before the hand busts
+	g := dealTestGame(t, "table-1", []string{"p1"}, "2H", "2S", "10D", "7C", "AH", "AS", "AD", "AC", "2D", "2C", "3H", "3S")
+	s.saveGame(g)
+
+	const hits = 50
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	drawn := 0
+	for i := 0; i < hits; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			code, reply := s.do("POST", "/api/game/"+g.ID+"/hit", map[string]string{"playerId": "p1"})
+			switch code {
+			case http.StatusOK:
+				mu.Lock()
+				drawn++
+				mu.Unlock()
+			case http.StatusBadRequest:
+				// The hand is over
+			default:
+				t.Errorf("hit: status = %d (%v), want 200 or 400", code, reply)
+			}
+		}()
+	}
+	wg.Wait()
+
+	hand := s.game(g.ID).Players[0].Hand
+	if drawn == 0 || len(hand) != 2+drawn {
+		t.Errorf("hand holds %d cards after %d successful hits, want %d", len(hand), drawn, 2+drawn)
+	}
+	if status := s.game(g.ID).Players[0].Status; status == game.PlayerActive {
+		t.Errorf("player status = %s, want the hand finished after %d hits", status, hits)
+	}
+}
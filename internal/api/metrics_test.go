@@ -0,0 +1,95 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/calvinwijaya/card-games-be/internal/metrics"
+)
+
+// counterValue gathers the registry and returns the counter's value for the
+// label pairs, or 0 if it hasn't been touched yet
+func counterValue(t *testing.T, name string, labels ...string) float64 {
+	t.Helper()
+
+	families, err := metrics.Registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metric:
+		for _, m := range family.GetMetric() {
+			for i := 0; i+1 < len(labels); i += 2 {
+				found := false
+				for _, pair := range m.GetLabel() {
+					if pair.GetName() == labels[i] && pair.GetValue() == labels[i+1] {
+						found = true
+					}
+				}
+				if !found {
+					continue metric
+				}
+			}
+			return m.GetCounter().GetValue()
+		}
+	}
+	return 0
+}
+
+func TestActionsIncrementCounters(t *testing.T) {
+	s := newTestServer(t)
+	g := dealTestGame(t, "table-1", []string{"p1"}, "5H", "6S", "10D", "7C", "2H")
+	s.saveGame(g)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   map[string]interface{}
+		want   int
+		metric string
+		labels []string
+	}{
+		{"hit", "POST", "/api/game/" + g.ID + "/hit", map[string]interface{}{"playerId": "p1"}, http.StatusOK, "blackjack_actions_total", []string{"action", "hit"}},
+		{"stand", "POST", "/api/game/" + g.ID + "/stand", map[string]interface{}{"playerId": "p1"}, http.StatusOK, "blackjack_actions_total", []string{"action", "stand"}},
+		{"new game", "POST", "/api/game/new", map[string]interface{}{"tableId": "table-2", "minBet": 10, "maxBet": 500}, http.StatusCreated, "blackjack_games_created_total", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := counterValue(t, tt.metric, tt.labels...)
+			if code, reply := s.do(tt.method, tt.path, tt.body); code != tt.want {
+				t.Fatalf("status = %d (%v), want %d", code, reply, tt.want)
+			}
+			if got := counterValue(t, tt.metric, tt.labels...); got != before+1 {
+				t.Errorf("%s %v = %v, want %v", tt.metric, tt.labels, got, before+1)
+			}
+		})
+	}
+
+	// The counters are served on /metrics
+	rec := s.record(nil, "GET", "/metrics", nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `blackjack_actions_total{action="hit"}`) {
+		t.Errorf("/metrics = %d, want the action counters", rec.Code)
+	}
+}
+
+func TestFailedActionsAreNotCounted(t *testing.T) {
+	s := newTestServer(t)
+	g := newTestGame("table-1", "p1")
+	g.OpenBetting()
+	s.saveGame(g)
+
+	// Nobody can hit before the deal
+	before := counterValue(t, "blackjack_actions_total", "action", "hit")
+	if code, _ := s.do("POST", "/api/game/"+g.ID+"/hit", map[string]interface{}{"playerId": "p1"}); code == http.StatusOK {
+		t.Fatal("hit before the deal succeeded")
+	}
+	if got := counterValue(t, "blackjack_actions_total", "action", "hit"); got != before {
+		t.Errorf("hit counter = %v, want it left at %v", got, before)
+	}
+}
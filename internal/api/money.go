@@ -0,0 +1,115 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// moneyFields are the JSON fields holding chip amounts
+var moneyFields = map[string]bool{
+	"balance":       true,
+	"bet":           true,
+	"bonusBet":      true,
+	"winnings":      true,
+	"minBet":        true,
+	"maxBet":        true,
+	"amount":        true,
+	"totalBets":     true,
+	"totalWinnings": true,
+}
+
+// Money is a chip amount in a currency, used instead of a bare integer when
+// the client asks for structured amounts
+type Money struct {
+	Amount   int    `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// formatMoney replaces every money field in a normalized JSON value with a
+// Money object in the given currency
+func formatMoney(v interface{}, currency string) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			if amount, ok := field.(float64); ok && moneyFields[key] {
+				value[key] = Money{Amount: int(amount), Currency: currency}
+				continue
+			}
+			value[key] = formatMoney(field, currency)
+		}
+		return value
+
+	case []interface{}:
+		for i := range value {
+			value[i] = formatMoney(value[i], currency)
+		}
+		return value
+
+	default:
+		return v
+	}
+}
+
+// validCurrency reports whether code looks like an ISO 4217 currency code
+func validCurrency(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// bufferedResponse captures a response so it can be rewritten before sending
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header         { return b.header }
+func (b *bufferedResponse) Write(p []byte) (int, error) { return b.body.Write(p) }
+func (b *bufferedResponse) WriteHeader(status int)      { b.status = status }
+
+// MoneyFormatMiddleware returns money fields as {amount, currency} objects
+// when the request has an Accept-Currency header. Without the header
+// responses keep their bare integer amounts.
+func MoneyFormatMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		currency := strings.ToUpper(strings.TrimSpace(r.Header.Get("Accept-Currency")))
+
+		// WebSocket upgrades need the real connection, so never buffer them
+		if currency == "" || r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !validCurrency(currency) {
+			errorResponse(w, http.StatusBadRequest, "Invalid Accept-Currency header")
+			return
+		}
+
+		buf := &bufferedResponse{header: w.Header(), status: http.StatusOK}
+		next.ServeHTTP(buf, r)
+
+		body := buf.body.Bytes()
+		if strings.HasPrefix(buf.header.Get("Content-Type"), "application/json") {
+			var data interface{}
+			if err := json.Unmarshal(body, &data); err == nil {
+				if formatted, err := json.Marshal(formatMoney(data, currency)); err == nil {
+					body = append(formatted, '\n')
+				}
+			}
+		}
+
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(buf.status)
+		w.Write(body)
+	})
+}
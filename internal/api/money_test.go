@@ -0,0 +1,57 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestMoneyFormatMiddleware(t *testing.T) {
+	reply := map[string]interface{}{
+		"balance": 990,
+		"players": []map[string]interface{}{{"name": "Ann", "bet": 10, "score": 18}},
+	}
+	handler := MoneyFormatMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response(w, http.StatusOK, reply)
+	}))
+
+	tests := []struct {
+		name     string
+		currency string
+		status   int
+		want     string
+	}{
+		{"bare amounts", "", http.StatusOK, `{"balance":990,"players":[{"bet":10,"name":"Ann","score":18}]}`},
+		{"structured amounts", "usd", http.StatusOK, `{"balance":{"amount":990,"currency":"USD"},"players":[{"bet":{"amount":10,"currency":"USD"},"name":"Ann","score":18}]}`},
+		{"invalid currency", "dollars", http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/game/1", nil)
+			if tt.currency != "" {
+				req.Header.Set("Accept-Currency", tt.currency)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.want == "" {
+				return
+			}
+
+			var got, want interface{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			json.Unmarshal([]byte(tt.want), &want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("body = %s, want %s", rec.Body.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PatchOp is a single JSON Patch (RFC 6902) operation
+type PatchOp struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// normalizeJSON converts a value into its generic JSON form (maps, slices,
+// float64s, ...) so that two states can be compared structurally
+func normalizeJSON(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// diffJSON returns the patch operations that turn before into after. Both
+// values must be in normalized JSON form.
+func diffJSON(path string, before, after interface{}) []PatchOp {
+	switch b := before.(type) {
+	case map[string]interface{}:
+		a, ok := after.(map[string]interface{})
+		if !ok {
+			break
+		}
+
+		var ops []PatchOp
+
+		// Walk keys in order so patches are deterministic
+		keys := make([]string, 0, len(b)+len(a))
+		for k := range b {
+			keys = append(keys, k)
+		}
+		for k := range a {
+			if _, exists := b[k]; !exists {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			childPath := path + "/" + escapePointer(k)
+			bv, inBefore := b[k]
+			av, inAfter := a[k]
+
+			switch {
+			case !inAfter:
+				ops = append(ops, PatchOp{Op: "remove", Path: childPath})
+			case !inBefore:
+				ops = append(ops, PatchOp{Op: "add", Path: childPath, Value: av})
+			default:
+				ops = append(ops, diffJSON(childPath, bv, av)...)
+			}
+		}
+		return ops
+
+	case []interface{}:
+		a, ok := after.([]interface{})
+		if !ok || len(a) != len(b) {
+			// Arrays that change length are replaced wholesale
+			break
+		}
+
+		var ops []PatchOp
+		for i := range b {
+			ops = append(ops, diffJSON(path+"/"+strconv.Itoa(i), b[i], a[i])...)
+		}
+		return ops
+	}
+
+	if reflect.DeepEqual(before, after) {
+		return nil
+	}
+	return []PatchOp{{Op: "replace", Path: path, Value: after}}
+}
+
+// escapePointer escapes a key for use in a JSON Pointer path
+func escapePointer(key string) string {
+	key = strings.ReplaceAll(key, "~", "~0")
+	return strings.ReplaceAll(key, "/", "~1")
+}
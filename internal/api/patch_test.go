@@ -0,0 +1,119 @@
+package api
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// applyPatch applies patch operations from diffJSON to a normalized JSON
+// value, the way a client would
+func applyPatch(t *testing.T, doc interface{}, ops []PatchOp) interface{} {
+	t.Helper()
+
+	for _, op := range ops {
+		if op.Path == "" {
+			doc = op.Value
+			continue
+		}
+
+		keys := strings.Split(op.Path, "/")[1:]
+		for i, key := range keys {
+			keys[i] = strings.ReplaceAll(strings.ReplaceAll(key, "~1", "/"), "~0", "~")
+		}
+
+		// Walk to the parent of the target
+		parent := doc
+		for _, key := range keys[:len(keys)-1] {
+			switch node := parent.(type) {
+			case map[string]interface{}:
+				parent = node[key]
+			case []interface{}:
+				i, err := strconv.Atoi(key)
+				if err != nil {
+					t.Fatalf("bad array index in %s", op.Path)
+				}
+				parent = node[i]
+			}
+		}
+
+		last := keys[len(keys)-1]
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			if op.Op == "remove" {
+				delete(node, last)
+			} else {
+				node[last] = op.Value
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(last)
+			if err != nil || op.Op != "replace" {
+				t.Fatalf("unexpected %s of %s", op.Op, op.Path)
+			}
+			node[i] = op.Value
+		default:
+			t.Fatalf("%s has no parent to patch", op.Path)
+		}
+	}
+	return doc
+}
+
+// normalized returns the value in normalized JSON form
+func normalized(t *testing.T, v interface{}) interface{} {
+	t.Helper()
+
+	out, err := normalizeJSON(v)
+	if err != nil {
+		t.Fatalf("normalizeJSON: %v", err)
+	}
+	return out
+}
+
+func TestDiffJSONPatchReproducesState(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after interface{}
+	}{
+		{"unchanged", map[string]interface{}{"a": 1}, map[string]interface{}{"a": 1}},
+		{"replaced value", map[string]interface{}{"a": 1}, map[string]interface{}{"a": 2}},
+		{"added key", map[string]interface{}{"a": 1}, map[string]interface{}{"a": 1, "b": "x"}},
+		{"removed key", map[string]interface{}{"a": 1, "b": "x"}, map[string]interface{}{"a": 1}},
+		{"nested change", map[string]interface{}{"p": map[string]interface{}{"bet": 10}}, map[string]interface{}{"p": map[string]interface{}{"bet": 20}}},
+		{"array element", map[string]interface{}{"a": []int{1, 2}}, map[string]interface{}{"a": []int{1, 3}}},
+		{"array grows", map[string]interface{}{"a": []int{1}}, map[string]interface{}{"a": []int{1, 2}}},
+		{"escaped keys", map[string]interface{}{"a/b": 1, "c~d": 1}, map[string]interface{}{"a/b": 2, "c~d": 2}},
+		{"type change", map[string]interface{}{"a": map[string]interface{}{"b": 1}}, map[string]interface{}{"a": "b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, after := normalized(t, tt.before), normalized(t, tt.after)
+			got := applyPatch(t, normalized(t, tt.before), diffJSON("", before, after))
+			if !reflect.DeepEqual(got, after) {
+				t.Errorf("patched state = %v, want %v", got, after)
+			}
+		})
+	}
+}
+
+func TestDiffJSONPatchFollowsGame(t *testing.T) {
+	g := newTestGame("table-1", "p1", "p2")
+
+	state := normalized(t, g.GetGameState("p1"))
+	steps := []func(){
+		func() { g.OpenBetting() },
+		func() { g.PlaceBet("p1", 20) },
+		func() { g.PlaceBet("p2", 30) },
+		func() { g.Start() },
+		func() { g.Stand(g.Players[g.CurrentPlayerIndex].ID) },
+	}
+	for i, step := range steps {
+		step()
+		next := normalized(t, g.GetGameState("p1"))
+		if got := applyPatch(t, state, diffJSON("", state, next)); !reflect.DeepEqual(got, next) {
+			t.Fatalf("step %d: patched state doesn't match the new state", i+1)
+		}
+		state = next
+	}
+}
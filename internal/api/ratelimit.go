@@ -0,0 +1,165 @@
+package api
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// tokenBucket is a simple token bucket rate limiter
+type tokenBucket struct {
+	rate     float64 // Tokens added per second
+	burst    float64 // Maximum number of tokens
+	tokens   float64
+	lastFill time.Time
+	mu       sync.Mutex
+}
+
+// newTokenBucket creates a full token bucket
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{
+		rate:     rate,
+		burst:    float64(burst),
+		tokens:   float64(burst),
+		lastFill: time.Now(),
+	}
+}
+
+// Allow takes a token from the bucket, returning false if none are left
+func (b *tokenBucket) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.lastFill).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.lastFill = now
+
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens--
+	return true
+}
+
+// Default action rate limit per player
+const (
+	DefaultActionRate  = 5.0 // Actions per second
+	DefaultActionBurst = 10
+
+	// Limiters idle for this long are dropped
+	actionLimiterIdle = 5 * time.Minute
+)
+
+// actionLimiter rate limits game actions per player, or per remote address
+// for requests that don't name a player
+type actionLimiter struct {
+	rate        float64
+	burst       int
+	mu          sync.Mutex
+	buckets     map[string]*actionBucket
+	lastCleanup time.Time
+}
+
+// actionBucket is a client's token bucket and when it was last used
+type actionBucket struct {
+	bucket   *tokenBucket
+	lastSeen time.Time
+}
+
+// newActionLimiter creates a limiter allowing perSecond actions per client
+// with bursts of up to burst
+func newActionLimiter(perSecond float64, burst int) *actionLimiter {
+	return &actionLimiter{
+		rate:        perSecond,
+		burst:       burst,
+		buckets:     make(map[string]*actionBucket),
+		lastCleanup: time.Now(),
+	}
+}
+
+// Allow takes a token from the client's bucket, returning false if none are left
+func (l *actionLimiter) Allow(key string) bool {
+	l.mu.Lock()
+	now := time.Now()
+
+	// Drop clients that have gone quiet
+	if now.Sub(l.lastCleanup) >= actionLimiterIdle {
+		for k, b := range l.buckets {
+			if now.Sub(b.lastSeen) >= actionLimiterIdle {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastCleanup = now
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &actionBucket{bucket: newTokenBucket(l.rate, l.burst)}
+		l.buckets[key] = b
+	}
+	b.lastSeen = now
+	l.mu.Unlock()
+
+	return b.bucket.Allow()
+}
+
+// retryAfter is how many whole seconds until a throttled client has a token again
+func (l *actionLimiter) retryAfter() int {
+	return max(1, int(math.Ceil(1/l.rate)))
+}
+
+// SetActionRateLimit configures the game action rate allowed per player.
+// A rate of zero or less disables the limit.
+func (h *Handlers) SetActionRateLimit(perSecond float64, burst int) {
+	if perSecond <= 0 {
+		h.actionLimiter = nil
+		return
+	}
+	h.actionLimiter = newActionLimiter(perSecond, max(burst, 1))
+}
+
+// rateLimited throttles a game action per player, answering 429 with a
+// Retry-After header once the player's rate is exceeded
+func (h *Handlers) rateLimited(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.actionLimiter == nil {
+			next(w, r)
+			return
+		}
+
+		playerID, err := requestPlayerID(r)
+		if err != nil {
+			errorResponse(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		key := "player:" + playerID
+		if playerID == "" {
+			host, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				host = r.RemoteAddr
+			}
+			key = "addr:" + host
+		}
+
+		if !h.actionLimiter.Allow(key) {
+			w.Header().Set("Retry-After", strconv.Itoa(h.actionLimiter.retryAfter()))
+			errorResponse(w, http.StatusTooManyRequests, "Too many requests, please slow down")
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// action wraps a game action endpoint with rate limiting and idempotency keys
+func (h *Handlers) action(next http.HandlerFunc) http.HandlerFunc {
+	return h.rateLimited(h.idempotent(next))
+}
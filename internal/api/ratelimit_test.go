@@ -0,0 +1,177 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestTokenBucket(t *testing.T) {
+	tests := []struct {
+		name    string
+		elapsed time.Duration // Time passed after the burst is spent
+		want    int           // Further requests allowed
+	}{
+		{"no time passed", 0, 0},
+		{"half a token", 50 * time.Millisecond, 0},
+		{"one token", 100 * time.Millisecond, 1},
+		{"two tokens", 200 * time.Millisecond, 2},
+		{"capped at burst", time.Hour, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTokenBucket(10, 3)
+			for i := 0; i < 3; i++ {
+				if !b.Allow() {
+					t.Fatalf("request %d of the burst was throttled", i+1)
+				}
+			}
+
+			// Wind the clock forward by moving the last fill back
+			b.mu.Lock()
+			b.lastFill = b.lastFill.Add(-tt.elapsed)
+			b.mu.Unlock()
+
+			allowed := 0
+			for b.Allow() {
+				allowed++
+			}
+			if allowed != tt.want {
+				t.Errorf("allowed %d more requests, want %d", allowed, tt.want)
+			}
+		})
+	}
+}
+
+func TestActionLimiterKeepsClientsApart(t *testing.T) {
+	l := newActionLimiter(0.001, 2)
+
+	for i := 0; i < 2; i++ {
+		if !l.Allow("player:p1") {
+			t.Fatalf("p1 request %d was throttled", i+1)
+		}
+	}
+	if l.Allow("player:p1") {
+		t.Error("p1 wasn't throttled after its burst")
+	}
+	if !l.Allow("player:p2") {
+		t.Error("p2 was throttled by p1's requests")
+	}
+}
+
+func TestReadPumpDropsFloodingClient(t *testing.T) {
+	hub := NewHub()
+	hub.SetMessageRateLimit(0.001, 2)
+
+	var handled int32
+	hub.SetMessageHandler(func(c *Client, msg Message) {
+		atomic.AddInt32(&handled, 1)
+	})
+	conn := dialHub(t, serveHub(t, hub), "playerId=p1&tableId=table-1")
+
+	// The burst gets through, then every message is dropped until the
+	// client is disconnected
+	msg, _ := json.Marshal(Message{Type: "ping"})
+	for i := 0; i < 2+maxRateViolations; i++ {
+		if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+			t.Fatalf("write message %d: %v", i+1, err)
+		}
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		_, _, err := conn.ReadMessage()
+		if isTimeout(err) {
+			t.Fatal("the client wasn't disconnected")
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	if got := atomic.LoadInt32(&handled); got != 2 {
+		t.Errorf("handled %d messages, want the burst of 2", got)
+	}
+}
+
+// isTimeout reports whether a read failed because its deadline passed
+func isTimeout(err error) bool {
+	var netErr interface{ Timeout() bool }
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func TestActionRateLimit(t *testing.T) {
+	tests := []struct {
+		name  string
+		burst int
+	}{
+		{"burst of one", 1},
+		{"burst of three", 3},
+		{"default burst", DefaultActionBurst},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			s.handlers.SetActionRateLimit(0.01, tt.burst)
+			g := newTestGame("table-1", "p1", "p2")
+			g.OpenBetting()
+			s.saveGame(g)
+			path := "/api/game/" + g.ID + "/bet/undo"
+
+			// The burst goes through, whatever the game makes of it
+			for i := 1; i <= tt.burst; i++ {
+				if code, reply := s.do("POST", path, map[string]interface{}{"playerId": "p1"}); code == http.StatusTooManyRequests {
+					t.Fatalf("request %d throttled (%v), want the first %d let through", i, reply, tt.burst)
+				}
+			}
+
+			rec := s.record(nil, "POST", path, map[string]interface{}{"playerId": "p1"})
+			if rec.Code != http.StatusTooManyRequests {
+				t.Fatalf("request %d = %d, want 429", tt.burst+1, rec.Code)
+			}
+			if got := rec.Header().Get("Retry-After"); got != "100" {
+				t.Errorf("Retry-After = %q, want 100", got)
+			}
+
+			// Each player has a bucket of their own
+			if code, reply := s.do("POST", path, map[string]interface{}{"playerId": "p2"}); code == http.StatusTooManyRequests {
+				t.Errorf("p2 throttled (%v) by p1's requests", reply)
+			}
+		})
+	}
+}
+
+func TestActionRateLimitByAddress(t *testing.T) {
+	s := newTestServer(t)
+	s.handlers.SetActionRateLimit(0.01, 2)
+	g := newTestGame("table-1", "p1")
+	g.OpenBetting()
+	s.saveGame(g)
+	path := "/api/game/" + g.ID + "/bet/undo"
+
+	// Requests that don't name a player share their address's bucket
+	for i := 1; i <= 2; i++ {
+		if code, _ := s.do("POST", path, nil); code == http.StatusTooManyRequests {
+			t.Fatalf("anonymous request %d throttled", i)
+		}
+	}
+	if code, _ := s.do("POST", path, nil); code != http.StatusTooManyRequests {
+		t.Errorf("third anonymous request = %d, want 429", code)
+	}
+	if code, _ := s.do("POST", path, map[string]interface{}{"playerId": "p1"}); code == http.StatusTooManyRequests {
+		t.Error("p1 throttled by anonymous requests")
+	}
+
+	// Turning the limit off lets everything through again
+	s.handlers.SetActionRateLimit(0, 0)
+	if code, _ := s.do("POST", path, nil); code == http.StatusTooManyRequests {
+		t.Error("throttled with the limit turned off")
+	}
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// DefaultResumeGrace is how long a dropped client can reconnect with its
+// resume token and pick up where it left off
+const DefaultResumeGrace = 30 * time.Second
+
+// ConnectHandler is called once a client has connected. resumed is true when
+// the client reconnected with a valid resume token.
+type ConnectHandler func(client *Client, resumed bool)
+
+// resumeEntry is a disconnected client that may still resume
+type resumeEntry struct {
+	playerID string
+	tableID  string
+	expires  time.Time
+}
+
+// SetConnectHandler sets the function called when a client connects
+func (h *Hub) SetConnectHandler(handler ConnectHandler) {
+	h.onConnect = handler
+}
+
+// SetResumeGrace sets how long a dropped client can resume its session.
+// A value of 0 disables resuming.
+func (h *Hub) SetResumeGrace(grace time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.resumeGrace = grace
+}
+
+// holdForResume keeps a disconnected client's session so it can be resumed.
+// The caller must hold h.mu.
+func (h *Hub) holdForResume(c *Client) {
+	if c.playerID == "" || c.resumeToken == "" || h.resumeGrace <= 0 {
+		return
+	}
+
+	now := time.Now()
+	for token, entry := range h.resumable {
+		if now.After(entry.expires) {
+			delete(h.resumable, token)
+		}
+	}
+
+	h.resumable[c.resumeToken] = resumeEntry{
+		playerID: c.playerID,
+		tableID:  c.tableID,
+		expires:  now.Add(h.resumeGrace),
+	}
+}
+
+// takeResume claims the session held under token for the player, returning
+// false if there is none, it has expired or it belongs to someone else.
+// A token can only be used once.
+func (h *Hub) takeResume(token, playerID string) (resumeEntry, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	entry, ok := h.resumable[token]
+	if !ok || entry.playerID != playerID {
+		return resumeEntry{}, false
+	}
+	delete(h.resumable, token)
+
+	if time.Now().After(entry.expires) {
+		return resumeEntry{}, false
+	}
+	return entry, true
+}
+
+// Send queues a message for this client alone
+func (c *Client) Send(message interface{}) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Error marshaling message: %v", err)
+		return
+	}
+
+	// If client buffer is full, we'll handle on next write
+	c.hub.queue(c, data)
+}
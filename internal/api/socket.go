@@ -0,0 +1,185 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxChatLength is the longest chat message relayed, in characters
+const maxChatLength = 500
+
+// HandleSocketMessage processes an inbound WebSocket message from a client
+func (h *Handlers) HandleSocketMessage(c *Client, msg Message) {
+	switch msg.Type {
+	case "ready", "unready":
+		h.socketSetReady(c, msg.Type == "ready")
+	case "hit", "stand", "bet", "placeBet":
+		h.socketAction(c, msg)
+	case "chat":
+		h.socketChat(c, msg)
+	default:
+		log.Printf("Unknown WebSocket message type: %s", msg.Type)
+	}
+}
+
+// HandleSocketConnect sends a newly connected or resumed client the current
+// state of the game at its table, so it doesn't have to wait for the next
+// change to see the table
+func (h *Handlers) HandleSocketConnect(c *Client, resumed bool) {
+	if c.tableID == "" {
+		return
+	}
+
+	g, err := h.store.GetActiveTableGame(c.tableID)
+	if err != nil {
+		return
+	}
+
+	c.Send(Message{
+		Type:       "gameSnapshot",
+		GameID:     g.ID,
+		TableID:    g.TableID,
+		Data:       g.GetGameState(c.viewKey(g)),
+		ServerTime: serverTime(),
+	})
+}
+
+// socketSetReady marks the client's player as ready or not ready for the next deal
+func (h *Handlers) socketSetReady(c *Client, ready bool) {
+	if c.tableID == "" || c.playerID == "" {
+		return
+	}
+
+	g, unlock, err := h.lockTableGame(c.tableID)
+	if err != nil {
+		return
+	}
+	defer unlock()
+
+	if !g.SetReady(c.playerID, ready) {
+		return
+	}
+
+	if err := h.store.SaveGame(g); err != nil {
+		log.Printf("Error saving game %s: %v", g.ID, err)
+		return
+	}
+
+	h.hub.BroadcastToTable(g.TableID, Message{
+		Type:     "playerReady",
+		GameID:   g.ID,
+		TableID:  g.TableID,
+		PlayerID: c.playerID,
+		Data:     map[string]bool{"ready": ready},
+	})
+	h.broadcastGame(g)
+}
+
+// socketChat relays a chat message to everyone at the client's table.
+// Messages are trimmed and cut to maxChatLength; empty ones are dropped.
+func (h *Handlers) socketChat(c *Client, msg Message) {
+	if c.tableID == "" {
+		return
+	}
+
+	var data struct {
+		Text string `json:"text"`
+	}
+	if msg.Data != nil {
+		raw, err := json.Marshal(msg.Data)
+		if err == nil {
+			err = json.Unmarshal(raw, &data)
+		}
+		if err != nil {
+			h.socketError(c, msg, "Invalid message data")
+			return
+		}
+	}
+
+	text := strings.TrimSpace(data.Text)
+	if text == "" {
+		return
+	}
+	if utf8.RuneCountInString(text) > maxChatLength {
+		text = strings.TrimSpace(string([]rune(text)[:maxChatLength]))
+	}
+
+	h.hub.BroadcastToTable(c.tableID, Message{
+		Type:       "chat",
+		TableID:    c.tableID,
+		PlayerID:   c.playerID,
+		Data:       map[string]string{"text": text},
+		ServerTime: serverTime(),
+	})
+}
+
+// socketAction plays a hit, stand or bet sent over the socket for the
+// client's player, using the same code paths as the REST endpoints. The
+// updated game reaches the client through the usual broadcast; failures are
+// reported back to the client alone.
+func (h *Handlers) socketAction(c *Client, msg Message) {
+	if c.playerID == "" {
+		return
+	}
+
+	var data struct {
+		HandIndex int `json:"handIndex"`
+		Amount    int `json:"amount"`
+		Seat      int `json:"seat"`
+	}
+	if msg.Data != nil {
+		raw, err := json.Marshal(msg.Data)
+		if err == nil {
+			err = json.Unmarshal(raw, &data)
+		}
+		if err != nil {
+			h.socketError(c, msg, "Invalid message data")
+			return
+		}
+	}
+
+	// Act on the named game, or the active game at the client's table
+	gameID := msg.GameID
+	if gameID == "" {
+		if c.tableID == "" {
+			h.socketError(c, msg, "No game specified")
+			return
+		}
+		g, err := h.store.GetActiveTableGame(c.tableID)
+		if err != nil {
+			h.socketError(c, msg, "No active game found for table")
+			return
+		}
+		gameID = g.ID
+	}
+
+	var aerr *actionError
+	switch msg.Type {
+	case "hit":
+		_, _, aerr = h.hit(gameID, c.playerID, data.HandIndex)
+	case "stand":
+		_, aerr = h.stand(gameID, c.playerID, data.HandIndex)
+	case "bet", "placeBet":
+		_, aerr = h.placeBet(gameID, c.playerID, data.Seat, data.Amount)
+	}
+
+	if aerr != nil {
+		h.socketError(c, msg, aerr.message)
+	}
+}
+
+// socketError tells the client why its message couldn't be handled
+func (h *Handlers) socketError(c *Client, msg Message, reason string) {
+	h.hub.SendToPlayer(c.playerID, Message{
+		Type:     "error",
+		GameID:   msg.GameID,
+		TableID:  c.tableID,
+		PlayerID: c.playerID,
+		Data: map[string]string{
+			"action": msg.Type,
+			"error":  reason,
+		},
+	})
+}
@@ -0,0 +1,300 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/calvinwijaya/card-games-be/internal/game"
+)
+
+// tableView is the part of a game state message the socket tests look at
+type tableView struct {
+	Dealer  game.Dealer `json:"dealer"`
+	Players []struct {
+		ID      string      `json:"id"`
+		Hand    []game.Card `json:"hand"`
+		Balance *int        `json:"balance"`
+	} `json:"players"`
+}
+
+// decodeView decodes a message's game state
+func decodeView(t *testing.T, msg Message) tableView {
+	t.Helper()
+
+	data, err := json.Marshal(msg.Data)
+	if err != nil {
+		t.Fatalf("encode state: %v", err)
+	}
+	var view tableView
+	if err := json.Unmarshal(data, &view); err != nil {
+		t.Fatalf("decode state: %v", err)
+	}
+	return view
+}
+
+// handCodes returns the cards' shorthands
+func handCodes(hand []game.Card) []string {
+	codes := make([]string, len(hand))
+	for i, c := range hand {
+		codes[i] = c.String()
+	}
+	return codes
+}
+
+func TestSocketConnectSendsSnapshot(t *testing.T) {
+	s := newTestServer(t)
+	g := dealTestGame(t, "table-1", []string{"p1", "p2"}, "9H", "7D", "10S", "8C", "5H", "QC")
+	s.saveGame(g)
+
+	p1 := s.connect("table-1", "p1")
+	s.handlers.HandleSocketConnect(p1, false)
+
+	msg, ok := findMessage(received(t, p1), "gameSnapshot")
+	if !ok {
+		t.Fatal("no gameSnapshot sent on connect")
+	}
+	if msg.GameID != g.ID || msg.TableID != "table-1" || msg.ServerTime == 0 {
+		t.Errorf("snapshot for game %q at %q, time %d, want %q at table-1", msg.GameID, msg.TableID, msg.ServerTime, g.ID)
+	}
+
+	view := decodeView(t, msg)
+	if got, want := handCodes(view.Dealer.Hand), []string{"5H", game.HiddenCard}; !reflect.DeepEqual(got, want) {
+		t.Errorf("dealer hand = %v, want %v", got, want)
+	}
+	hands := make(map[string][]string)
+	for _, p := range view.Players {
+		hands[p.ID] = handCodes(p.Hand)
+	}
+	// Until the showdown other players' cards are kept from p1 too
+	hidden := []string{game.HiddenCard, game.HiddenCard}
+	want := map[string][]string{"p1": {"9H", "7D"}, "p2": hidden}
+	if !reflect.DeepEqual(hands, want) {
+		t.Errorf("player hands = %v, want %v", hands, want)
+	}
+}
+
+func TestSocketConnectWithoutGame(t *testing.T) {
+	s := newTestServer(t)
+	g := newTestGame("table-1", "p1")
+	g.Status = game.Completed
+	s.saveGame(g)
+
+	tests := []struct {
+		name    string
+		tableID string
+	}{
+		{"no table", ""},
+		{"table without a game", "table-2"},
+		{"table with only a finished game", "table-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := s.connect(tt.tableID, "p1")
+			s.handlers.HandleSocketConnect(c, false)
+			if messages := received(t, c); len(messages) != 0 {
+				t.Errorf("sent %v, want nothing", messages)
+			}
+		})
+	}
+}
+
+func TestSocketChat(t *testing.T) {
+	long := strings.Repeat("é", maxChatLength+20)
+
+	tests := []struct {
+		name string
+		text string
+		want string // Empty if nothing should be relayed
+	}{
+		{"plain", "good luck", "good luck"},
+		{"trimmed", "  nice hand \n", "nice hand"},
+		{"too long", long, long[:maxChatLength*len("é")]},
+		{"blank", "   ", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			sender := s.connect("table-1", "p1")
+			neighbour := s.connect("table-1", "p2")
+			spectator := s.connect("table-1", "")
+			elsewhere := s.connect("table-2", "p3")
+
+			s.handlers.HandleSocketMessage(sender, Message{
+				Type: "chat",
+				Data: map[string]interface{}{"text": tt.text},
+			})
+
+			for _, c := range []*Client{sender, neighbour, spectator} {
+				msg, ok := findMessage(received(t, c), "chat")
+				if tt.want == "" {
+					if ok {
+						t.Errorf("%q got %v, want nothing relayed", c.playerID, msg)
+					}
+					continue
+				}
+				text, _ := msg.Data.(map[string]interface{})["text"].(string)
+				if !ok || text != tt.want || msg.PlayerID != "p1" || msg.TableID != "table-1" || msg.ServerTime == 0 {
+					t.Errorf("%q got %+v, want p1's chat %q", c.playerID, msg, tt.want)
+				}
+			}
+			if messages := received(t, elsewhere); len(messages) != 0 {
+				t.Errorf("a client at another table got %v", messages)
+			}
+		})
+	}
+}
+
+func TestSocketChatNeedsTable(t *testing.T) {
+	s := newTestServer(t)
+	lobby := s.connect("", "p1")
+	other := s.connect("", "p2")
+
+	s.handlers.HandleSocketMessage(lobby, Message{
+		Type: "chat",
+		Data: map[string]interface{}{"text": "anyone here?"},
+	})
+	if messages := append(received(t, lobby), received(t, other)...); len(messages) != 0 {
+		t.Errorf("a chat without a table was relayed: %v", messages)
+	}
+}
+
+func TestHitSendsYouDrew(t *testing.T) {
+	tests := []struct {
+		name string
+		hit  func(s *testServer, p1 *Client, gameID string)
+	}{
+		{"http", func(s *testServer, p1 *Client, gameID string) {
+			s.do("POST", "/api/game/"+gameID+"/hit", map[string]interface{}{"playerId": "p1"})
+		}},
+		{"socket", func(s *testServer, p1 *Client, gameID string) {
+			s.handlers.HandleSocketMessage(p1, Message{Type: "hit", GameID: gameID})
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			g := dealTestGame(t, "table-1", []string{"p1", "p2"}, "5H", "6S", "9D", "8C", "10D", "7C", "2H")
+			s.saveGame(g)
+			p1 := s.connect("table-1", "p1")
+			p2 := s.connect("table-1", "p2")
+			spectator := s.connect("table-1", "")
+
+			tt.hit(s, p1, g.ID)
+
+			msg, ok := findMessage(received(t, p1), "youDrew")
+			if !ok {
+				t.Fatal("p1 got no youDrew after hitting")
+			}
+			data, _ := msg.Data.(map[string]interface{})
+			card, _ := data["card"].(map[string]interface{})
+			if msg.PlayerID != "p1" || msg.GameID != g.ID || card["rank"] != "2" || card["suit"] != "Hearts" {
+				t.Errorf("youDrew = %+v, want p1's 2H", msg)
+			}
+
+			for name, c := range map[string]*Client{"p2": p2, "spectator": spectator} {
+				if _, ok := findMessage(received(t, c), "youDrew"); ok {
+					t.Errorf("%s got p1's youDrew", name)
+				}
+			}
+		})
+	}
+}
+
+func TestNextRoundBroadcastsReshuffle(t *testing.T) {
+	s := newTestServer(t)
+	g := dealTestGame(t, "table-1", []string{"p1"}, "10H", "9S", "10D", "7C")
+	g.Stand("p1")
+	g.DrainEvents()
+
+	// Run the shoe down past the cut card
+	g.Deck.Cards = g.Deck.Cards[:0]
+	s.saveGame(g)
+	p1 := s.connect("table-1", "p1")
+	spectator := s.connect("table-1", "")
+
+	if code, reply := s.do("POST", "/api/game/"+g.ID+"/next-round", nil); code != http.StatusOK {
+		t.Fatalf("next-round = %d (%v), want 200", code, reply)
+	}
+
+	for name, c := range map[string]*Client{"p1": p1, "spectator": spectator} {
+		msg, ok := findMessage(received(t, c), "deckReshuffled")
+		if !ok {
+			t.Errorf("%s got no deckReshuffled", name)
+			continue
+		}
+		data, _ := msg.Data.(map[string]interface{})
+		if data["remainingCards"] != float64(52) || msg.TableID != "table-1" {
+			t.Errorf("%s got %+v, want a fresh deck of 52", name, msg)
+		}
+	}
+}
+
+func TestBetAcknowledgements(t *testing.T) {
+	tests := []struct {
+		name    string
+		funds   int // p1's balance before betting
+		amount  int
+		want    string
+		balance float64
+		reason  string
+	}{
+		{"accepted", 1000, 50, "betAccepted", 950, ""},
+		{"over the maximum", 1000, 600, "betRejected", 1000, "Bet is above the table maximum of 500"},
+		{"under the minimum", 1000, 5, "betRejected", 1000, "Bet is below the table minimum of 10"},
+		{"insufficient balance", 40, 50, "betRejected", 40, "Insufficient balance for this bet"},
+	}
+
+	bets := map[string]func(s *testServer, p1 *Client, gameID string, amount int){
+		"http": func(s *testServer, p1 *Client, gameID string, amount int) {
+			s.do("POST", "/api/game/"+gameID+"/bet", map[string]interface{}{"playerId": "p1", "amount": amount})
+		},
+		"socket": func(s *testServer, p1 *Client, gameID string, amount int) {
+			s.handlers.HandleSocketMessage(p1, Message{
+				Type:   "placeBet",
+				GameID: gameID,
+				Data:   map[string]interface{}{"amount": amount},
+			})
+		},
+	}
+
+	for _, tt := range tests {
+		for via, bet := range bets {
+			t.Run(tt.name+" via "+via, func(t *testing.T) {
+				s := newTestServer(t)
+				g := newTestGame("table-1", "p1", "p2")
+				g.Players[0].Balance = tt.funds
+				g.OpenBetting()
+				s.saveGame(g)
+				p1 := s.connect("table-1", "p1")
+				p2 := s.connect("table-1", "p2")
+
+				bet(s, p1, g.ID, tt.amount)
+
+				msg, ok := findMessage(received(t, p1), tt.want)
+				if !ok {
+					t.Fatalf("p1 got no %s", tt.want)
+				}
+				data, _ := msg.Data.(map[string]interface{})
+				if data["amount"] != float64(tt.amount) || data["balance"] != tt.balance {
+					t.Errorf("%s = %v, want a bet of %d leaving %v", tt.want, data, tt.amount, tt.balance)
+				}
+				if reason, _ := data["reason"].(string); reason != tt.reason {
+					t.Errorf("reason = %q, want %q", reason, tt.reason)
+				}
+
+				others := received(t, p2)
+				for _, other := range []string{"betAccepted", "betRejected"} {
+					if _, ok := findMessage(others, other); ok {
+						t.Errorf("p2 got p1's %s", other)
+					}
+				}
+			})
+		}
+	}
+}
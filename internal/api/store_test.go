@@ -0,0 +1,90 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/calvinwijaya/card-games-be/internal/game"
+)
+
+func TestStoreGameConflicts(t *testing.T) {
+	tests := []struct {
+		name        string
+		firstStatus game.GameStatus // What the first request moves the game to
+		lateStatus  game.GameStatus // What the late request moves it to
+	}{
+		{"neither changes status", game.Betting, game.Betting},
+		{"late request changes status", game.Betting, game.Waiting},
+		{"both change status", game.Waiting, game.InProgress},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			g := newTestGame("table-1", "p1")
+			g.Status = game.Betting
+			s.saveGame(g)
+
+			// Both requests load the same version
+			first, late := s.game(g.ID), s.game(g.ID)
+			first.Status = tt.firstStatus
+			first.Players[0].Balance = 900
+			if err := s.handlers.storeGame(first, game.Betting); err != nil {
+				t.Fatalf("first save: %v", err)
+			}
+
+			late.Status = tt.lateStatus
+			late.Players[0].Balance = 800
+			if err := s.handlers.storeGame(late, game.Betting); !errors.Is(err, errStatusConflict) {
+				t.Fatalf("late save = %v, want %v", err, errStatusConflict)
+			}
+
+			// The first request's game stands, status included
+			stored := s.game(g.ID)
+			if stored.Version != first.Version || stored.Status != tt.firstStatus || stored.Players[0].Balance != 900 {
+				t.Errorf("stored version %d, %s with %d, want the first save's version %d, %s with 900",
+					stored.Version, stored.Status, stored.Players[0].Balance, first.Version, tt.firstStatus)
+			}
+		})
+	}
+}
+
+func TestSaveGameConflictIs409(t *testing.T) {
+	s := newTestServer(t)
+	g := newTestGame("table-1", "p1")
+	s.saveGame(g)
+
+	stale := s.game(g.ID)
+	s.saveGame(s.game(g.ID))
+
+	rec := httptest.NewRecorder()
+	if s.handlers.saveGame(rec, stale, stale.Status) {
+		t.Fatal("saveGame accepted a stale game")
+	}
+	if rec.Code != http.StatusConflict {
+		t.Errorf("status = %d, want 409", rec.Code)
+	}
+}
+
+func TestActionOnStaleGameIs409(t *testing.T) {
+	s := newTestServer(t)
+	g := dealTestGame(t, "table-1", []string{"p1"}, "10H", "2D", "10C", "7S", "5H")
+	s.saveGame(g)
+
+	// Another server saves the game while this one is mid-action
+	s.store.onLoad = func(id string) {
+		s.store.onLoad = nil
+		other, _ := s.store.GetGame(id)
+		s.store.SaveGame(other)
+	}
+
+	code, reply := s.do("POST", "/api/game/"+g.ID+"/hit", map[string]interface{}{"playerId": "p1"})
+	if code != http.StatusConflict {
+		t.Errorf("status = %d (%v), want 409", code, reply)
+	}
+	if hand := s.game(g.ID).Players[0].Hand; len(hand) != 2 {
+		t.Errorf("p1 holds %d cards, want the conflicting hit dropped", len(hand))
+	}
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/calvinwijaya/card-games-be/internal/game"
+	"github.com/calvinwijaya/card-games-be/internal/metrics"
+)
+
+// DefaultSweepInterval is how often auto-managed tables are checked
+const DefaultSweepInterval = time.Second
+
+// RunTableSweeper advances tables through their policy, closes betting that
+// has timed out and stands players whose turn has timed out, every interval. It blocks, so run
+// it in its own goroutine.
+func (h *Handlers) RunTableSweeper(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		h.sweepTables(now)
+	}
+}
+
+// sweepTables advances every game with a policy step or turn timeout due and
+// publishes the changes
+func (h *Handlers) sweepTables(now time.Time) {
+	games, err := h.store.GetAllGames()
+	if err != nil {
+		log.Printf("Table sweep failed to load games: %v", err)
+		return
+	}
+
+	active := 0
+	for _, g := range games {
+		if g.Status != game.Completed {
+			active++
+		}
+
+		policyDue := (g.Status == game.Waiting && g.AutoManaged()) ||
+			(g.Status == game.Betting && g.HasBettingClock())
+		turnDue := g.Status == game.InProgress && !g.TurnDeadline.IsZero()
+		if !policyDue && !turnDue {
+			continue
+		}
+		h.advanceTable(g.ID, now)
+	}
+	metrics.ActiveGames.Set(float64(active))
+}
+
+// advanceTable advances one game under its lock
+func (h *Handlers) advanceTable(gameID string, now time.Time) {
+	unlock := h.locks.lock(gameID)
+	defer unlock()
+
+	// Reload the game, since a request may have changed it since the sweep
+	// loaded every game
+	g, err := h.store.GetGame(gameID)
+	if err != nil {
+		return
+	}
+
+	// Move the table's policy on, or stand a player whose turn has run out
+	before := g.Status
+	if !g.AdvancePolicy(now) && !g.ExpireTurn(now) {
+		return
+	}
+
+	if err := h.storeGame(g, before); err != nil {
+		if !errors.Is(err, errStatusConflict) {
+			log.Printf("Table sweep failed to save game %s: %v", g.ID, err)
+		}
+		return
+	}
+
+	if before == game.Waiting && g.Status == game.Betting && h.hub != nil {
+		h.hub.BroadcastToTable(g.TableID, Message{
+			Type:    "bettingOpened",
+			GameID:  g.ID,
+			TableID: g.TableID,
+		})
+	}
+	h.broadcastGame(g)
+
+	// Everyone may have been dealt blackjack, completing the round
+	if g.Status == game.Completed {
+		h.saveRoundResults(g)
+	}
+}
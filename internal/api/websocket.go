@@ -4,21 +4,34 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/calvinwijaya/card-games-be/internal/game"
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
+// upgrader is shared by every hub; each checks origins against its own list
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		return true // Allow all origins for now, customize this in production
-	},
 }
 
+const (
+	// Default inbound message rate limit per client
+	defaultMessageRate  = 10.0 // Messages per second
+	defaultMessageBurst = 20
+
+	// Number of dropped messages after which a client is disconnected
+	maxRateViolations = 50
+
+	// Number of patches sent to a client before it gets a full state again
+	fullResyncInterval = 20
+)
+
 // Message represents a WebSocket message
 type Message struct {
 	Type     string      `json:"type"`
@@ -26,8 +39,20 @@ type Message struct {
 	TableID  string      `json:"tableId,omitempty"`
 	PlayerID string      `json:"playerId,omitempty"`
 	Data     interface{} `json:"data,omitempty"`
+
+	// Server clock in Unix milliseconds, set on messages carrying deadlines so
+	// clients can correct for clock drift
+	ServerTime int64 `json:"serverTime,omitempty"`
 }
 
+// serverTime returns the server clock in Unix milliseconds
+func serverTime() int64 {
+	return time.Now().UnixMilli()
+}
+
+// MessageHandler processes an inbound message from a client
+type MessageHandler func(client *Client, msg Message)
+
 // Client represents a connected WebSocket client
 type Client struct {
 	conn     *websocket.Conn
@@ -35,6 +60,19 @@ type Client struct {
 	tableID  string
 	playerID string
 	hub      *Hub
+	limiter  *tokenBucket // Inbound message rate limiter
+	dropped  int          // Messages dropped for exceeding the rate limit
+	closed   bool         // Whether send has been closed, guarded by the hub's mu
+
+	resumeToken string // Lets the client resume its session after a disconnect
+	isSpectator bool   // Connected without a player ID: watches, but can't act
+
+	// State diffing for clients that opted in to gamePatch messages
+	patches     bool
+	patchMu     sync.Mutex
+	lastGameID  string
+	lastState   interface{}
+	patchesSent int
 }
 
 // Hub maintains the set of active clients and broadcasts messages to them
@@ -46,6 +84,24 @@ type Hub struct {
 	tables     map[string]map[*Client]bool
 	playerMap  map[string]*Client
 	mu         sync.RWMutex
+
+	messageRate  float64 // Inbound messages per second allowed per client
+	messageBurst int     // Inbound message burst allowed per client
+
+	onMessage MessageHandler // Handles inbound client messages
+	onConnect ConnectHandler // Called once a client has connected
+
+	resumeGrace time.Duration          // How long a dropped client can resume
+	resumable   map[string]resumeEntry // Dropped clients by resume token, guarded by mu
+
+	maxClients  int // Maximum concurrent connections, 0 for no limit
+	connections int // Connections holding a slot, guarded by mu
+
+	allowedOrigins []string // Origins browsers may connect from, any if empty
+
+	quit     chan struct{} // Closed to stop Run
+	done     chan struct{} // Closed once Run has returned
+	quitOnce sync.Once
 }
 
 // NewHub creates a new WebSocket hub
@@ -57,13 +113,83 @@ func NewHub() *Hub {
 		broadcast:  make(chan []byte),
 		tables:     make(map[string]map[*Client]bool),
 		playerMap:  make(map[string]*Client),
+
+		messageRate:  defaultMessageRate,
+		messageBurst: defaultMessageBurst,
+
+		resumeGrace: DefaultResumeGrace,
+		resumable:   make(map[string]resumeEntry),
+
+		quit: make(chan struct{}),
+		done: make(chan struct{}),
 	}
 }
 
-// Run starts the hub
+// SetMessageHandler sets the function that processes inbound client messages
+func (h *Hub) SetMessageHandler(handler MessageHandler) {
+	h.onMessage = handler
+}
+
+// SetMaxClients caps the number of concurrent WebSocket connections.
+// A value of 0 removes the limit.
+func (h *Hub) SetMaxClients(max int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.maxClients = max
+}
+
+// SetAllowedOrigins limits which origins browsers may open connections
+// from. With no origins set, any origin may connect.
+func (h *Hub) SetAllowedOrigins(origins []string) {
+	h.allowedOrigins = origins
+}
+
+// checkOrigin reports whether a connection's origin is allowed. Requests
+// without an Origin header don't come from a browser page, so they are let
+// through.
+func (h *Hub) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || len(h.allowedOrigins) == 0 {
+		return true
+	}
+
+	for _, allowed := range h.allowedOrigins {
+		if allowed == "*" || strings.EqualFold(origin, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// reserveSlot claims a connection slot, returning false if the hub is full
+func (h *Hub) reserveSlot() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.maxClients > 0 && h.connections >= h.maxClients {
+		return false
+	}
+	h.connections++
+	return true
+}
+
+// SetMessageRateLimit configures the inbound message rate allowed per client.
+// It only applies to clients that connect after the call.
+func (h *Hub) SetMessageRateLimit(perSecond float64, burst int) {
+	h.messageRate = perSecond
+	h.messageBurst = burst
+}
+
+// Run starts the hub. It returns once the hub is shut down.
 func (h *Hub) Run() {
+	defer close(h.done)
+
 	for {
 		select {
+		case <-h.quit:
+			h.closeAll()
+			return
+
 		case client := <-h.register:
 			h.mu.Lock()
 			h.clients[client] = true
@@ -81,12 +207,14 @@ func (h *Hub) Run() {
 				h.playerMap[client.playerID] = client
 			}
 			h.mu.Unlock()
+			h.broadcastPresence(client)
 
 		case client := <-h.unregister:
 			h.mu.Lock()
 			if _, ok := h.clients[client]; ok {
 				delete(h.clients, client)
-				close(client.send)
+				client.closeSend()
+				h.connections--
 
 				// Remove from table map
 				if client.tableID != "" && h.tables[client.tableID] != nil {
@@ -97,34 +225,112 @@ func (h *Hub) Run() {
 					}
 				}
 
-				// Remove from player map
-				if client.playerID != "" {
+				// Remove from player map, unless the player has
+				// already reconnected on another connection
+				if client.playerID != "" && h.playerMap[client.playerID] == client {
 					delete(h.playerMap, client.playerID)
 				}
+
+				// Give the client a chance to pick up where it left off
+				h.holdForResume(client)
 			}
 			h.mu.Unlock()
+			h.broadcastPresence(client)
 
 		case message := <-h.broadcast:
 			for client := range h.clients {
 				select {
 				case client.send <- message:
 				default:
+					// Too far behind to take it, so drop the client
 					h.mu.Lock()
-					close(client.send)
+					client.closeSend()
 					delete(h.clients, client)
+					h.connections--
 					if client.tableID != "" && h.tables[client.tableID] != nil {
 						delete(h.tables[client.tableID], client)
+						if len(h.tables[client.tableID]) == 0 {
+							delete(h.tables, client.tableID)
+						}
 					}
-					if client.playerID != "" {
+					if client.playerID != "" && h.playerMap[client.playerID] == client {
 						delete(h.playerMap, client.playerID)
 					}
 					h.mu.Unlock()
+					h.broadcastPresence(client)
 				}
 			}
 		}
 	}
 }
 
+// Close stops Run, which unregisters every client on the way out. It doesn't
+// wait for Run to return and is safe to call more than once.
+func (h *Hub) Close() {
+	h.quitOnce.Do(func() { close(h.quit) })
+}
+
+// Shutdown closes the hub and waits for Run to return
+func (h *Hub) Shutdown() {
+	h.Close()
+	<-h.done
+}
+
+// closeAll unregisters every client. Closing a client's send channel makes
+// its write pump send a close message and drop the connection.
+func (h *Hub) closeAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for client := range h.clients {
+		client.closeSend()
+	}
+	h.clients = make(map[*Client]bool)
+	h.tables = make(map[string]map[*Client]bool)
+	h.playerMap = make(map[string]*Client)
+	h.connections = 0
+}
+
+// closeSend closes the client's send channel, which makes its write pump
+// drop the connection. The hub's mu must be held.
+func (c *Client) closeSend() {
+	c.closed = true
+	close(c.send)
+}
+
+// queue hands a message to the client's write pump. It reports false if the
+// client's buffer is full or the hub has already let go of the client and
+// closed its send channel.
+func (h *Hub) queue(client *Client, data []byte) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if client.closed {
+		return false
+	}
+	select {
+	case client.send <- data:
+		return true
+	default:
+		return false
+	}
+}
+
+// BroadcastAll sends a message to every connected client, whatever table
+// they are at. Clients too far behind to take it are disconnected.
+func (h *Hub) BroadcastAll(message interface{}) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Error marshaling message: %v", err)
+		return
+	}
+
+	select {
+	case h.broadcast <- data:
+	case <-h.quit:
+	}
+}
+
 // BroadcastToTable sends a message to all clients in a specific table
 func (h *Hub) BroadcastToTable(tableID string, message interface{}) {
 	data, err := json.Marshal(message)
@@ -148,6 +354,47 @@ func (h *Hub) BroadcastToTable(tableID string, message interface{}) {
 	}
 }
 
+// TablePresence returns the IDs of the players connected to a table, sorted.
+// Spectators aren't included.
+func (h *Hub) TablePresence(tableID string) []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	players := []string{}
+	for client := range h.tables[tableID] {
+		if client.playerID == "" || seen[client.playerID] {
+			continue
+		}
+		seen[client.playerID] = true
+		players = append(players, client.playerID)
+	}
+	sort.Strings(players)
+	return players
+}
+
+// broadcastPresence tells the client's table who is connected after the
+// client joined or left. It must be called without holding h.mu.
+func (h *Hub) broadcastPresence(client *Client) {
+	if client.tableID == "" || client.playerID == "" {
+		return
+	}
+
+	h.BroadcastToTable(client.tableID, Message{
+		Type:    "presence",
+		TableID: client.tableID,
+		Data:    map[string][]string{"players": h.TablePresence(client.tableID)},
+	})
+}
+
+// gameView is one distinct view of a game's state, shared by every client
+// that should see it
+type gameView struct {
+	full  []byte      // Marshaled gameUpdate message
+	state interface{} // Normalized state, computed lazily for patch clients
+	data  interface{} // The state as returned by GetGameState
+}
+
 // BroadcastGameUpdate broadcasts a game update to all clients in the table
 func (h *Hub) BroadcastGameUpdate(game *game.BlackjackGame) {
 	// Send a sanitized game state to all players in the table
@@ -160,21 +407,35 @@ func (h *Hub) BroadcastGameUpdate(game *game.BlackjackGame) {
 		return
 	}
 
+	// Clients that share a view (spectators, or several connections for the
+	// same player) get the same bytes, so marshal once per distinct view
+	views := make(map[string]*gameView)
+
 	for client := range tableClients {
-		// Create a customized game state for this player
-		gameState := game.GetGameState(client.playerID)
-
-		msg := Message{
-			Type:    "gameUpdate",
-			GameID:  game.ID,
-			TableID: game.TableID,
-			Data:    gameState,
+		key := client.viewKey(game)
+
+		view, ok := views[key]
+		if !ok {
+			gameState := game.GetGameState(key)
+			msg := Message{
+				Type:    "gameUpdate",
+				GameID:  game.ID,
+				TableID: game.TableID,
+				Data:    gameState,
+			}
+
+			data, err := json.Marshal(msg)
+			if err != nil {
+				log.Printf("Error marshaling game update: %v", err)
+				continue
+			}
+			view = &gameView{full: data, data: gameState}
+			views[key] = view
 		}
 
-		data, err := json.Marshal(msg)
-		if err != nil {
-			log.Printf("Error marshaling game update: %v", err)
-			continue
+		data := view.full
+		if client.patches {
+			data = client.nextUpdate(game, view)
 		}
 
 		select {
@@ -185,6 +446,70 @@ func (h *Hub) BroadcastGameUpdate(game *game.BlackjackGame) {
 	}
 }
 
+// nextUpdate returns the message a patch-enabled client should receive for
+// a view: a gamePatch against the last state it was sent, or a full
+// gameUpdate when it has no prior state or is due a resync
+func (c *Client) nextUpdate(g *game.BlackjackGame, view *gameView) []byte {
+	if view.state == nil {
+		state, err := normalizeJSON(view.data)
+		if err != nil {
+			log.Printf("Error normalizing game state: %v", err)
+			return view.full
+		}
+		view.state = state
+	}
+
+	c.patchMu.Lock()
+	defer c.patchMu.Unlock()
+
+	if c.lastState == nil || c.lastGameID != g.ID || c.patchesSent >= fullResyncInterval {
+		c.lastGameID = g.ID
+		c.lastState = view.state
+		c.patchesSent = 0
+		return view.full
+	}
+
+	ops := diffJSON("", c.lastState, view.state)
+	if ops == nil {
+		ops = []PatchOp{}
+	}
+
+	data, err := json.Marshal(Message{
+		Type:    "gamePatch",
+		GameID:  g.ID,
+		TableID: g.TableID,
+		Data:    ops,
+	})
+	if err != nil {
+		log.Printf("Error marshaling game patch: %v", err)
+		return view.full
+	}
+
+	c.lastState = view.state
+	c.patchesSent++
+	return data
+}
+
+// viewKey returns the player ID whose view of the game the client should
+// receive. Spectators always get the public view.
+func (c *Client) viewKey(g *game.BlackjackGame) string {
+	if c.isSpectator {
+		return ""
+	}
+	return stateViewKey(g, c.playerID)
+}
+
+// stateViewKey returns the player ID whose view of the game a client should
+// receive. Anyone who isn't seated in the game gets the public view.
+func stateViewKey(g *game.BlackjackGame, playerID string) string {
+	for _, p := range g.Players {
+		if p.ID == playerID {
+			return playerID
+		}
+	}
+	return ""
+}
+
 // SendToPlayer sends a message to a specific player
 func (h *Hub) SendToPlayer(playerID string, message interface{}) {
 	data, err := json.Marshal(message)
@@ -210,36 +535,84 @@ func (h *Hub) SendToPlayer(playerID string, message interface{}) {
 
 // WebSocketHandler handles WebSocket connections
 func (h *Hub) WebSocketHandler(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+	up := upgrader
+	up.CheckOrigin = h.checkOrigin
+	conn, err := up.Upgrade(w, r, nil)
 	if err != nil {
 		log.Println(err)
 		return
 	}
 
+	// Turn the connection away if the server is at capacity
+	if !h.reserveSlot() {
+		conn.WriteControl(
+			websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "serverFull"),
+			time.Now().Add(time.Second),
+		)
+		conn.Close()
+		return
+	}
+
 	// Extract playerID and tableID from query params
 	playerID := r.URL.Query().Get("playerId")
 	tableID := r.URL.Query().Get("tableId")
+	patches := r.URL.Query().Get("patches") == "true"
+
+	// A client reconnecting within the grace period resumes its session
+	resumed := false
+	if token := r.URL.Query().Get("resumeToken"); token != "" && playerID != "" {
+		if entry, ok := h.takeResume(token, playerID); ok {
+			resumed = true
+			if tableID == "" {
+				tableID = entry.tableID
+			}
+		}
+	}
 
 	client := &Client{
-		conn:     conn,
-		send:     make(chan []byte, 256),
-		tableID:  tableID,
-		playerID: playerID,
-		hub:      h,
+		conn:        conn,
+		send:        make(chan []byte, 256),
+		tableID:     tableID,
+		playerID:    playerID,
+		hub:         h,
+		limiter:     newTokenBucket(h.messageRate, h.messageBurst),
+		patches:     patches,
+		resumeToken: uuid.New().String(),
+		isSpectator: playerID == "",
+	}
+	select {
+	case h.register <- client:
+	case <-h.quit:
+		// The hub has stopped, so the client would never be served
+		conn.Close()
+		return
 	}
-	h.register <- client
 
-	// Send a welcome message
+	// Send a welcome message, or confirm the resumed session
 	welcomeMsg := Message{
-		Type: "welcome",
+		Type:       "welcome",
+		ServerTime: serverTime(),
 		Data: map[string]string{
-			"message":  "Connected to BlackJack game server",
-			"playerId": playerID,
-			"tableId":  tableID,
+			"message":     "Connected to BlackJack game server",
+			"playerId":    playerID,
+			"tableId":     tableID,
+			"resumeToken": client.resumeToken,
 		},
 	}
+	if resumed {
+		welcomeMsg.Type = "resumed"
+	}
 	welcomeData, _ := json.Marshal(welcomeMsg)
-	client.send <- welcomeData
+	if !h.queue(client, welcomeData) {
+		// The hub shut down or dropped the client since it registered
+		conn.Close()
+		return
+	}
+
+	if h.onConnect != nil {
+		h.onConnect(client, resumed)
+	}
 
 	// Start goroutines for reading and writing
 	go client.readPump()
@@ -249,7 +622,11 @@ func (h *Hub) WebSocketHandler(w http.ResponseWriter, r *http.Request) {
 // readPump pumps messages from the WebSocket connection to the hub
 func (c *Client) readPump() {
 	defer func() {
-		c.hub.unregister <- c
+		// Once the hub has stopped it has already let go of every client
+		select {
+		case c.hub.unregister <- c:
+		case <-c.hub.quit:
+		}
 		c.conn.Close()
 	}()
 
@@ -269,6 +646,16 @@ func (c *Client) readPump() {
 			break
 		}
 
+		// Drop messages over the rate limit and disconnect persistent offenders
+		if !c.limiter.Allow() {
+			c.dropped++
+			if c.dropped >= maxRateViolations {
+				log.Printf("Disconnecting client %s: exceeded message rate limit", c.playerID)
+				break
+			}
+			continue
+		}
+
 		// Parse the message
 		var msg Message
 		if err := json.Unmarshal(message, &msg); err != nil {
@@ -276,8 +663,15 @@ func (c *Client) readPump() {
 			continue
 		}
 
+		// Spectators only watch, so nothing they send is acted on
+		if c.isSpectator {
+			continue
+		}
+
 		// Process message based on type
-		// This will be handled by the API handler
+		if c.hub.onMessage != nil {
+			c.hub.onMessage(c, msg)
+		}
 	}
 }
 
@@ -0,0 +1,747 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/calvinwijaya/card-games-be/internal/game"
+	"github.com/gorilla/websocket"
+)
+
+// serveHub runs the hub and serves its WebSocket endpoint until the test
+// ends, returning the server's URL
+func serveHub(t *testing.T, hub *Hub) string {
+	t.Helper()
+
+	go hub.Run()
+	server := httptest.NewServer(http.HandlerFunc(hub.WebSocketHandler))
+	t.Cleanup(func() {
+		server.Close()
+		hub.Shutdown()
+	})
+	return server.URL
+}
+
+// dialHub opens a WebSocket to a server from serveHub with the query string
+func dialHub(t *testing.T, url, query string) *websocket.Conn {
+	t.Helper()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(url, "http")+"?"+query, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// unread holds messages read off a connection but not yet returned. The
+// write pump batches queued messages into one frame, one per line, so a
+// single read can bring in several.
+var (
+	unreadMu sync.Mutex
+	unread   = make(map[*websocket.Conn][]Message)
+)
+
+// readMessage reads the next message sent over the connection
+func readMessage(t *testing.T, conn *websocket.Conn) (Message, error) {
+	t.Helper()
+
+	unreadMu.Lock()
+	defer unreadMu.Unlock()
+
+	for len(unread[conn]) == 0 {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, frame, err := conn.ReadMessage()
+		if err != nil {
+			delete(unread, conn)
+			return Message{}, err
+		}
+		for _, line := range bytes.Split(frame, []byte{'\n'}) {
+			var msg Message
+			if err := json.Unmarshal(line, &msg); err != nil {
+				return Message{}, err
+			}
+			unread[conn] = append(unread[conn], msg)
+		}
+	}
+
+	msg := unread[conn][0]
+	unread[conn] = unread[conn][1:]
+	return msg, nil
+}
+
+func TestHubCheckOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		origin  string
+		want    bool
+	}{
+		{"listed origin", []string{"https://example.com", "http://localhost:5173"}, "http://localhost:5173", true},
+		{"unlisted origin", []string{"https://example.com"}, "https://evil.example.net", false},
+		{"case differs", []string{"https://example.com"}, "https://EXAMPLE.com", true},
+		{"no origin header", []string{"https://example.com"}, "", true},
+		{"no list", nil, "https://evil.example.net", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := NewHub()
+			hub.SetAllowedOrigins(tt.allowed)
+
+			req := httptest.NewRequest("GET", "/ws", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if got := hub.checkOrigin(req); got != tt.want {
+				t.Errorf("checkOrigin(%q) = %v, want %v", tt.origin, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHubRejectsConnectionsOverLimit(t *testing.T) {
+	hub := NewHub()
+	hub.SetMaxClients(2)
+	url := serveHub(t, hub)
+
+	for _, player := range []string{"p1", "p2"} {
+		conn := dialHub(t, url, "playerId="+player+"&tableId=table-1")
+		if msg, err := readMessage(t, conn); err != nil || msg.Type != "welcome" {
+			t.Fatalf("%s: got %q, %v, want a welcome", player, msg.Type, err)
+		}
+	}
+
+	conn := dialHub(t, url, "playerId=p3&tableId=table-1")
+	_, err := readMessage(t, conn)
+	if !websocket.IsCloseError(err, websocket.CloseTryAgainLater) {
+		t.Fatalf("third connection: got %v, want it closed with try again later", err)
+	}
+	if reason := err.(*websocket.CloseError).Text; reason != "serverFull" {
+		t.Errorf("close reason = %q, want serverFull", reason)
+	}
+}
+
+// attachClient registers a client without a connection with the hub, as if
+// it had connected to the table. Leave playerID empty for a spectator.
+func attachClient(h *Hub, tableID, playerID string) *Client {
+	c := &Client{
+		send:        make(chan []byte, 256),
+		tableID:     tableID,
+		playerID:    playerID,
+		hub:         h,
+		isSpectator: playerID == "",
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.clients[c] = true
+	if h.tables[tableID] == nil {
+		h.tables[tableID] = make(map[*Client]bool)
+	}
+	h.tables[tableID][c] = true
+	if playerID != "" {
+		h.playerMap[playerID] = c
+	}
+	return c
+}
+
+// broadcastTable sets up a table with seated players and a crowd of
+// spectators watching it
+func broadcastTable(players, spectators int) (*Hub, *game.BlackjackGame, []*Client) {
+	h := NewHub()
+	g := game.NewBlackjackGame("table-1", 10, 500, 1)
+
+	var clients []*Client
+	for i := 0; i < players; i++ {
+		id := fmt.Sprintf("p%d", i)
+		g.AddPlayer(id, "Player "+id, 1000)
+		clients = append(clients, attachClient(h, g.TableID, id))
+	}
+	for i := 0; i < spectators; i++ {
+		clients = append(clients, attachClient(h, g.TableID, ""))
+	}
+	return h, g, clients
+}
+
+func TestBroadcastGameUpdateViews(t *testing.T) {
+	h, g, clients := broadcastTable(2, 3)
+	h.BroadcastGameUpdate(g)
+
+	// Spectators share one public view; players each get their own
+	sent := make(map[*Client][]byte)
+	for _, c := range clients {
+		sent[c] = <-c.send
+	}
+	spectatorView := sent[clients[2]]
+	for _, c := range clients {
+		if shared := bytes.Equal(sent[c], spectatorView); shared != c.isSpectator {
+			t.Errorf("client for %q shares the spectator view = %v, want %v", c.playerID, shared, c.isSpectator)
+		}
+	}
+}
+
+// sevenSeatTable fills a full 7-player table, plus the given number of
+// spectators, with clients that are registered on the hub but never read
+func sevenSeatTable(spectators int) (*Hub, *game.BlackjackGame, []*Client) {
+	h := NewHub()
+	g := game.NewBlackjackGame("table-1", 10, 500, 1)
+	h.tables[g.TableID] = make(map[*Client]bool)
+
+	var clients []*Client
+	for i := 0; i < 7+spectators; i++ {
+		c := &Client{send: make(chan []byte, 256), tableID: g.TableID, hub: h}
+		if i < 7 {
+			c.playerID = fmt.Sprintf("seat-%d", i)
+			g.AddPlayer(c.playerID, "Player "+c.playerID, 1000)
+			h.playerMap[c.playerID] = c
+		}
+		h.clients[c] = true
+		h.tables[g.TableID][c] = true
+		clients = append(clients, c)
+	}
+	return h, g, clients
+}
+
+// benchmarkBroadcast runs broadcast against a 7-player table with and without
+// spectators, emptying every send buffer between rounds
+func benchmarkBroadcast(b *testing.B, broadcast func(*Hub, *game.BlackjackGame, []*Client)) {
+	for _, spectators := range []int{0, 20} {
+		b.Run(fmt.Sprintf("spectators=%d", spectators), func(b *testing.B) {
+			h, g, clients := sevenSeatTable(spectators)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				broadcast(h, g, clients)
+				for _, c := range clients {
+					for len(c.send) > 0 {
+						<-c.send
+					}
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkBroadcastGrouped measures BroadcastGameUpdate, which marshals
+// once per distinct view
+func BenchmarkBroadcastGrouped(b *testing.B) {
+	benchmarkBroadcast(b, func(h *Hub, g *game.BlackjackGame, _ []*Client) {
+		h.BroadcastGameUpdate(g)
+	})
+}
+
+// BenchmarkBroadcastPerClient measures the same fan-out with the state built
+// and marshaled separately for every client, as it was before grouping
+func BenchmarkBroadcastPerClient(b *testing.B) {
+	benchmarkBroadcast(b, func(_ *Hub, g *game.BlackjackGame, clients []*Client) {
+		for _, c := range clients {
+			data, err := json.Marshal(Message{
+				Type:    "gameUpdate",
+				GameID:  g.ID,
+				TableID: g.TableID,
+				Data:    g.GetGameState(c.playerID),
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+			c.send <- data
+		}
+	})
+}
+
+func TestWelcomeCarriesServerTime(t *testing.T) {
+	url := serveHub(t, NewHub())
+
+	var last int64
+	for _, player := range []string{"p1", "p2"} {
+		before := time.Now().UnixMilli()
+		msg, err := readMessage(t, dialHub(t, url, "playerId="+player+"&tableId=table-1"))
+		if err != nil || msg.Type != "welcome" {
+			t.Fatalf("%s: got %q, %v, want a welcome", player, msg.Type, err)
+		}
+		if msg.ServerTime < before || msg.ServerTime < last {
+			t.Errorf("%s: serverTime = %d after %d, want it to keep up with the clock at %d", player, msg.ServerTime, last, before)
+		}
+		last = msg.ServerTime
+	}
+}
+
+func TestBroadcastHidesHoleCard(t *testing.T) {
+	h, g, clients := broadcastTable(1, 1)
+	g.OpenBetting()
+	g.PlaceBet("p0", 10)
+	stackDeck(t, g, "9H", "7D", "10S", "QC")
+	if err := g.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	h.BroadcastGameUpdate(g)
+	for _, c := range clients {
+		if data := <-c.send; bytes.Contains(data, []byte(game.Queen)) || bytes.Contains(data, []byte(game.Clubs)) {
+			t.Errorf("update for %q shows the hole card", c.playerID)
+		}
+	}
+}
+
+// dropConnection connects the player to the table, then drops the
+// connection and waits for the hub to hold the session, returning the
+// resume token from the welcome
+func dropConnection(t *testing.T, hub *Hub, url, playerID string) string {
+	t.Helper()
+
+	conn := dialHub(t, url, "playerId="+playerID+"&tableId=table-1")
+	msg, err := readMessage(t, conn)
+	if err != nil || msg.Type != "welcome" {
+		t.Fatalf("got %q, %v, want a welcome", msg.Type, err)
+	}
+	token, _ := msg.Data.(map[string]interface{})["resumeToken"].(string)
+	if token == "" {
+		t.Fatalf("welcome %v has no resume token", msg.Data)
+	}
+	conn.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		hub.mu.RLock()
+		_, held := hub.resumable[token]
+		hub.mu.RUnlock()
+		if held {
+			return token
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("the hub never held the dropped session")
+	return ""
+}
+
+func TestResumeSession(t *testing.T) {
+	tests := []struct {
+		name        string
+		playerID    string
+		expire      bool
+		wantType    string
+		wantTableID string
+	}{
+		{"within grace", "p1", false, "resumed", "table-1"},
+		{"after expiry", "p1", true, "welcome", ""},
+		{"another player's token", "p2", false, "welcome", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := NewHub()
+			var resumed []bool
+			var mu sync.Mutex
+			hub.SetConnectHandler(func(c *Client, r bool) {
+				mu.Lock()
+				resumed = append(resumed, r)
+				mu.Unlock()
+			})
+			url := serveHub(t, hub)
+			token := dropConnection(t, hub, url, "p1")
+
+			if tt.expire {
+				hub.mu.Lock()
+				entry := hub.resumable[token]
+				entry.expires = time.Now().Add(-time.Second)
+				hub.resumable[token] = entry
+				hub.mu.Unlock()
+			}
+
+			// Reconnect without naming the table, so only a resumed
+			// session knows where the player was sitting
+			msg, err := readMessage(t, dialHub(t, url, "playerId="+tt.playerID+"&resumeToken="+token))
+			if err != nil || msg.Type != tt.wantType {
+				t.Fatalf("got %q, %v, want %q", msg.Type, err, tt.wantType)
+			}
+			if tableID := msg.Data.(map[string]interface{})["tableId"]; tableID != tt.wantTableID {
+				t.Errorf("tableId = %v, want %q", tableID, tt.wantTableID)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if want := []bool{false, tt.wantType == "resumed"}; !reflect.DeepEqual(resumed, want) {
+				t.Errorf("connect handler saw resumed = %v, want %v", resumed, want)
+			}
+		})
+	}
+}
+
+func TestResumeTokenWorksOnce(t *testing.T) {
+	hub := NewHub()
+	url := serveHub(t, hub)
+	token := dropConnection(t, hub, url, "p1")
+
+	for i, want := range []string{"resumed", "welcome"} {
+		msg, err := readMessage(t, dialHub(t, url, "playerId=p1&tableId=table-1&resumeToken="+token))
+		if err != nil || msg.Type != want {
+			t.Errorf("connection %d: got %q, %v, want %q", i+1, msg.Type, err, want)
+		}
+	}
+}
+
+// waitForClients waits until the hub has n clients registered
+func waitForClients(t *testing.T, hub *Hub, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		hub.mu.RLock()
+		count := len(hub.clients)
+		hub.mu.RUnlock()
+		if count == n {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("the hub never got to %d clients", n)
+}
+
+func TestSpectatorNeverSeesBalances(t *testing.T) {
+	h, g, clients := broadcastTable(2, 1)
+	spectator := clients[2]
+
+	// Broadcast the table while betting, once dealt and once settled
+	g.OpenBetting()
+	h.BroadcastGameUpdate(g)
+	g.PlaceBet("p0", 10)
+	g.PlaceBet("p1", 10)
+	stackDeck(t, g, "9H", "7D", "10S", "8C", "5H", "QC", "10D")
+	g.Start()
+	h.BroadcastGameUpdate(g)
+	g.Stand("p0")
+	g.Stand("p1")
+	h.BroadcastGameUpdate(g)
+
+	for _, phase := range []string{"betting", "dealt", "settled"} {
+		spectatorMsg := decodeSent(t, spectator)
+		for _, p := range decodeView(t, spectatorMsg).Players {
+			if p.Balance != nil {
+				t.Errorf("%s: the spectator sees %s's balance of %d", phase, p.ID, *p.Balance)
+			}
+		}
+
+		// Players only ever see their own balance
+		for _, c := range clients[:2] {
+			for _, p := range decodeView(t, decodeSent(t, c)).Players {
+				if shown := p.Balance != nil; shown != (p.ID == c.playerID) {
+					t.Errorf("%s: %s sees %s's balance = %v", phase, c.playerID, p.ID, shown)
+				}
+			}
+		}
+	}
+}
+
+// decodeSent decodes the next message queued for the client
+func decodeSent(t *testing.T, c *Client) Message {
+	t.Helper()
+
+	var msg Message
+	if err := json.Unmarshal(<-c.send, &msg); err != nil {
+		t.Fatalf("decode message: %v", err)
+	}
+	return msg
+}
+
+func TestSpectatorCannotAct(t *testing.T) {
+	hub := NewHub()
+	var mu sync.Mutex
+	var handled []string
+	hub.SetMessageHandler(func(c *Client, msg Message) {
+		mu.Lock()
+		handled = append(handled, c.playerID+":"+msg.Type)
+		mu.Unlock()
+	})
+	url := serveHub(t, hub)
+
+	spectator := dialHub(t, url, "tableId=table-1")
+	player := dialHub(t, url, "playerId=p1&tableId=table-1")
+	waitForClients(t, hub, 2)
+	for _, msgType := range []string{"hit", "stand", "bet", "chat"} {
+		if err := spectator.WriteJSON(Message{Type: msgType}); err != nil {
+			t.Fatalf("write %s: %v", msgType, err)
+		}
+	}
+	if err := player.WriteJSON(Message{Type: "hit"}); err != nil {
+		t.Fatalf("write hit: %v", err)
+	}
+
+	// Once both have hung up, everything they sent has been read
+	spectator.Close()
+	player.Close()
+	waitForClients(t, hub, 0)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{"p1:hit"}; !reflect.DeepEqual(handled, want) {
+		t.Errorf("handled %v, want only %v", handled, want)
+	}
+}
+
+// readPresence reads from the connection until the next presence message,
+// returning the players it lists
+func readPresence(t *testing.T, conn *websocket.Conn) []string {
+	t.Helper()
+
+	for {
+		msg, err := readMessage(t, conn)
+		if err != nil {
+			t.Fatalf("waiting for presence: %v", err)
+		}
+		if msg.Type != "presence" {
+			continue
+		}
+		var players []string
+		for _, id := range msg.Data.(map[string]interface{})["players"].([]interface{}) {
+			players = append(players, id.(string))
+		}
+		return players
+	}
+}
+
+func TestTablePresence(t *testing.T) {
+	hub := NewHub()
+	url := serveHub(t, hub)
+
+	p1 := dialHub(t, url, "playerId=p1&tableId=table-1")
+	if got := readPresence(t, p1); !reflect.DeepEqual(got, []string{"p1"}) {
+		t.Errorf("presence after p1 joined = %v", got)
+	}
+
+	// Spectators and other tables don't show up
+	dialHub(t, url, "tableId=table-1")
+	dialHub(t, url, "playerId=p3&tableId=table-2")
+	p2 := dialHub(t, url, "playerId=p2&tableId=table-1")
+	if got := readPresence(t, p1); !reflect.DeepEqual(got, []string{"p1", "p2"}) {
+		t.Errorf("presence after p2 joined = %v", got)
+	}
+	if got := hub.TablePresence("table-1"); !reflect.DeepEqual(got, []string{"p1", "p2"}) {
+		t.Errorf("TablePresence(table-1) = %v", got)
+	}
+
+	p2.Close()
+	if got := readPresence(t, p1); !reflect.DeepEqual(got, []string{"p1"}) {
+		t.Errorf("presence after p2 dropped = %v", got)
+	}
+	if got := hub.TablePresence("table-1"); !reflect.DeepEqual(got, []string{"p1"}) {
+		t.Errorf("TablePresence(table-1) after p2 dropped = %v", got)
+	}
+	if got := hub.TablePresence("table-3"); len(got) != 0 {
+		t.Errorf("TablePresence(table-3) = %v, want nobody", got)
+	}
+}
+
+func TestHubCloseStopsRun(t *testing.T) {
+	h, _, clients := broadcastTable(2, 1)
+	done := make(chan struct{})
+	go func() {
+		h.Run()
+		close(done)
+	}()
+
+	h.Close()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run didn't return after Close")
+	}
+
+	for _, c := range clients {
+		if _, open := <-c.send; open {
+			t.Errorf("client %q still has an open send channel", c.playerID)
+		}
+	}
+	if len(h.clients) != 0 || len(h.tables) != 0 || len(h.playerMap) != 0 {
+		t.Error("the hub still holds clients after closing")
+	}
+
+	// Closing again and broadcasting after the hub stopped don't block
+	h.Close()
+	h.Shutdown()
+	h.BroadcastAll(Message{Type: "announcement"})
+}
+
+func TestHubCloseDisconnectsClients(t *testing.T) {
+	hub := NewHub()
+	url := serveHub(t, hub)
+	conn := dialHub(t, url, "playerId=p1&tableId=table-1")
+	waitForClients(t, hub, 1)
+
+	hub.Shutdown()
+	for {
+		_, err := readMessage(t, conn)
+		if isTimeout(err) {
+			t.Fatal("the connection stayed open after the hub shut down")
+		}
+		if err != nil {
+			break
+		}
+	}
+}
+
+func TestSendToPlayer(t *testing.T) {
+	tests := []struct {
+		name     string
+		playerID string
+		want     int // Index of the client that should get the message, or -1
+	}{
+		{"first player", "p0", 0},
+		{"second player", "p1", 1},
+		{"unknown player", "p9", -1},
+		{"spectator", "", -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _, clients := broadcastTable(2, 1)
+			h.SendToPlayer(tt.playerID, Message{Type: "yourTurn", PlayerID: tt.playerID})
+
+			for i, c := range clients {
+				if i != tt.want {
+					if len(c.send) != 0 {
+						t.Errorf("client %d (%q) got a message meant for %q", i, c.playerID, tt.playerID)
+					}
+					continue
+				}
+				if len(c.send) != 1 {
+					t.Fatalf("client %d got %d messages, want 1", i, len(c.send))
+				}
+				if msg := decodeSent(t, c); msg.Type != "yourTurn" || msg.PlayerID != tt.playerID {
+					t.Errorf("client %d got %+v, want %q's yourTurn", i, msg, tt.playerID)
+				}
+			}
+		})
+	}
+}
+
+func TestSendAfterHubLetGo(t *testing.T) {
+	h, _, clients := broadcastTable(2, 0)
+	go h.Run()
+	h.Shutdown()
+
+	// The hub closed both clients' send channels on the way out, so anything
+	// still sending to them is turned away instead of panicking
+	for _, c := range clients {
+		c.Send(Message{Type: "gameSnapshot"})
+		if h.queue(c, []byte(`{"type":"welcome"}`)) {
+			t.Errorf("queued a message for %q after the hub let go of it", c.playerID)
+		}
+	}
+	h.SendToPlayer("p0", Message{Type: "yourTurn"})
+}
+
+func TestShutdownWhileClientsConnect(t *testing.T) {
+	hub := NewHub()
+	hub.SetConnectHandler(func(c *Client, resumed bool) {
+		c.Send(Message{Type: "gameSnapshot"})
+	})
+
+	// net/http recovers handler panics, so count them here instead
+	var panics int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if recover() != nil {
+				atomic.AddInt32(&panics, 1)
+			}
+		}()
+		hub.WebSocketHandler(w, r)
+	}))
+	defer server.Close()
+	go hub.Run()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			conn, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("%s?playerId=p%d&tableId=table-1", url, i), nil)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+
+			// Read until the hub drops the connection
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}(i)
+
+		if i == 25 {
+			hub.Shutdown()
+		}
+	}
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&panics); n != 0 {
+		t.Errorf("%d connections panicked while the hub shut down", n)
+	}
+}
+
+func TestBroadcastDropsClientTooFarBehind(t *testing.T) {
+	tests := []struct {
+		name        string
+		reconnected bool // Whether p0 reconnected before its old connection was dropped
+		want        []string
+	}{
+		{"player gone", false, []string{"p1"}},
+		{"player reconnected", true, []string{"p0", "p1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _, clients := broadcastTable(2, 0)
+			stale, p1 := clients[0], clients[1]
+			var fresh *Client
+			if tt.reconnected {
+				fresh = attachClient(h, "table-1", "p0")
+			}
+			go h.Run()
+			defer h.Shutdown()
+
+			// Fill p0's old connection's buffer so the next broadcast drops it
+			for len(stale.send) < cap(stale.send) {
+				stale.send <- []byte(`{"type":"gameUpdate"}`)
+			}
+			h.BroadcastAll(Message{Type: "announcement"})
+			// Run takes the next broadcast only once it's done with the first
+			h.BroadcastAll(Message{Type: "announcement"})
+
+			h.mu.RLock()
+			held, kept := h.clients[stale], h.playerMap["p0"]
+			h.mu.RUnlock()
+			if held {
+				t.Error("the hub still holds the client that fell behind")
+			}
+			if kept != fresh {
+				t.Errorf("playerMap[p0] = %p, want %p", kept, fresh)
+			}
+
+			msg, ok := findMessage(received(t, p1), "presence")
+			if !ok {
+				t.Fatal("p1 wasn't told p0's connection dropped")
+			}
+			var players []string
+			for _, id := range msg.Data.(map[string]interface{})["players"].([]interface{}) {
+				players = append(players, id.(string))
+			}
+			if !reflect.DeepEqual(players, tt.want) {
+				t.Errorf("presence = %v, want %v", players, tt.want)
+			}
+		})
+	}
+}
@@ -6,14 +6,21 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/calvinwijaya/card-games-be/internal/game"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
+// ErrGameNotFound is returned when there is no game with the requested ID
+var ErrGameNotFound = errors.New("game not found")
+
 type Database struct {
-	db *sql.DB
+	db        *sql.DB
+	snapshots bool // Record every saved game state in game_snapshots
 }
 
 type PlayerStats struct {
@@ -26,27 +33,165 @@ type PlayerStats struct {
 	LastPlayed    time.Time `json:"lastPlayed"`
 }
 
-// NewDatabase creates a new database connection
-func NewDatabase() (*Database, error) {
-	// Get database connection details from environment variables
-	// dbHost := os.Getenv("DB_HOST")
-	// dbPort := os.Getenv("DB_PORT")
-	// dbName := os.Getenv("DB_NAME")
-	// dbUser := os.Getenv("DB_USER")
-	// dbPassword := os.Getenv("DB_PASSWORD")
-
-	// TODO: Remove hardcoded values
-	dbHost := "localhost"
-	dbPort := "5433"
-	dbName := "card_games"
-	dbUser := "card_games_user"
-	dbPassword := "card_games_password"
-
-	// Construct connection string
-	connStr := fmt.Sprintf(
-		"host=%s port=%s dbname=%s user=%s password=%s sslmode=disable",
-		dbHost, dbPort, dbName, dbUser, dbPassword,
+// GameResult is a player's settled result for a single game
+type GameResult struct {
+	GameID      string    `json:"gameId"`
+	PlayerID    string    `json:"playerId"`
+	HandIndex   int       `json:"handIndex"`
+	Bet         int       `json:"bet"`
+	Result      string    `json:"result"`
+	Winnings    int       `json:"winnings"`
+	PlayerScore int       `json:"playerScore"`
+	DealerScore int       `json:"dealerScore"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// GameResultRecord is one of a player's hand results, with the game and table
+// it was played at
+type GameResultRecord struct {
+	GameID      string    `json:"gameId"`
+	TableID     string    `json:"tableId"`
+	HandIndex   int       `json:"handIndex"`
+	Bet         int       `json:"bet"`
+	Result      string    `json:"result"`
+	Winnings    int       `json:"winnings"`
+	PlayerScore int       `json:"playerScore"`
+	DealerScore int       `json:"dealerScore"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// PlayerSummary is the public information about a player returned by searches
+type PlayerSummary struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Balance int    `json:"balance"`
+}
+
+// HouseBalance is the house's running result at a table
+type HouseBalance struct {
+	TableID   string    `json:"tableId"`
+	Balance   int       `json:"balance"`
+	Rounds    int       `json:"rounds"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// GameSnapshot is a game's full state as of one save
+type GameSnapshot struct {
+	GameID    string          `json:"gameId"`
+	Sequence  int             `json:"sequence"`
+	GameState json.RawMessage `json:"gameState"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+// Connection retry defaults, used while waiting for the database to come up
+const (
+	DefaultConnectAttempts = 5
+	DefaultConnectDelay    = 500 * time.Millisecond
+)
+
+// DatabaseConfig holds the Postgres connection settings
+type DatabaseConfig struct {
+	Host     string
+	Port     string
+	Name     string
+	User     string
+	Password string
+	SSLMode  string
+
+	ConnectAttempts int           // Times to try connecting before giving up
+	ConnectDelay    time.Duration // Wait before the first retry, doubled after each one
+}
+
+// DatabaseConfigFromEnv reads the connection settings from DB_HOST, DB_PORT,
+// DB_NAME, DB_USER, DB_PASSWORD and DB_SSLMODE. Host, port and SSL mode
+// default to localhost, 5432 and disable; the rest are required.
+// DB_CONNECT_ATTEMPTS and DB_CONNECT_DELAY control how long to wait for the
+// database to come up.
+func DatabaseConfigFromEnv() (DatabaseConfig, error) {
+	cfg := DatabaseConfig{
+		Host:            envOrDefault("DB_HOST", "localhost"),
+		Port:            envOrDefault("DB_PORT", "5432"),
+		Name:            os.Getenv("DB_NAME"),
+		User:            os.Getenv("DB_USER"),
+		Password:        os.Getenv("DB_PASSWORD"),
+		SSLMode:         envOrDefault("DB_SSLMODE", "disable"),
+		ConnectAttempts: DefaultConnectAttempts,
+		ConnectDelay:    DefaultConnectDelay,
+	}
+
+	if v := os.Getenv("DB_CONNECT_ATTEMPTS"); v != "" {
+		attempts, err := strconv.Atoi(v)
+		if err != nil || attempts < 1 {
+			return DatabaseConfig{}, fmt.Errorf("invalid DB_CONNECT_ATTEMPTS %q", v)
+		}
+		cfg.ConnectAttempts = attempts
+	}
+	if v := os.Getenv("DB_CONNECT_DELAY"); v != "" {
+		delay, err := time.ParseDuration(v)
+		if err != nil || delay < 0 {
+			return DatabaseConfig{}, fmt.Errorf("invalid DB_CONNECT_DELAY %q", v)
+		}
+		cfg.ConnectDelay = delay
+	}
+
+	required := []struct {
+		name  string
+		value string
+	}{
+		{"DB_NAME", cfg.Name},
+		{"DB_USER", cfg.User},
+		{"DB_PASSWORD", cfg.Password},
+	}
+	for _, r := range required {
+		if r.value == "" {
+			return DatabaseConfig{}, fmt.Errorf("missing required environment variable %s", r.name)
+		}
+	}
+
+	return cfg, nil
+}
+
+// envOrDefault returns the environment variable, or def if it is unset or empty
+func envOrDefault(key, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return def
+}
+
+// connString builds a lib/pq connection string, quoting each value
+func (c DatabaseConfig) connString() string {
+	quote := func(v string) string {
+		v = strings.ReplaceAll(v, `\`, `\\`)
+		v = strings.ReplaceAll(v, `'`, `\'`)
+		return "'" + v + "'"
+	}
+
+	sslMode := c.SSLMode
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+
+	return fmt.Sprintf(
+		"host=%s port=%s dbname=%s user=%s password=%s sslmode=%s",
+		quote(c.Host), quote(c.Port), quote(c.Name), quote(c.User), quote(c.Password), quote(sslMode),
 	)
+}
+
+// NewDatabase creates a new database connection configured from the
+// environment
+func NewDatabase() (*Database, error) {
+	cfg, err := DatabaseConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return NewDatabaseWithConfig(cfg)
+}
+
+// NewDatabaseWithConfig creates a new database connection with the given
+// settings
+func NewDatabaseWithConfig(cfg DatabaseConfig) (*Database, error) {
+	connStr := cfg.connString()
 
 	// Open database connection
 	db, err := sql.Open("postgres", connStr)
@@ -54,8 +199,9 @@ func NewDatabase() (*Database, error) {
 		return nil, fmt.Errorf("error opening database: %v", err)
 	}
 
-	// Test the connection
-	if err := db.Ping(); err != nil {
+	// Test the connection, waiting for the database if it is still starting
+	if err := pingWithRetry(db, cfg.ConnectAttempts, cfg.ConnectDelay); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("error connecting to the database: %v", err)
 	}
 
@@ -72,6 +218,34 @@ func NewDatabase() (*Database, error) {
 	return &Database{db: db}, nil
 }
 
+// pinger is a connection that can be checked, such as *sql.DB
+type pinger interface {
+	Ping() error
+}
+
+// pingWithRetry pings until it succeeds or attempts run out, doubling the
+// delay after each failure. It returns the last error if every attempt fails.
+func pingWithRetry(p pinger, attempts int, delay time.Duration) error {
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = p.Ping(); err == nil {
+			return nil
+		}
+		if attempt == attempts {
+			break
+		}
+
+		log.Printf("Database not ready (attempt %d of %d): %v; retrying in %v", attempt, attempts, err, delay)
+		time.Sleep(delay)
+		delay *= 2
+	}
+	return err
+}
+
 // initTables creates the necessary tables if they don't exist
 func initTables(db *sql.DB) error {
 	// Players table
@@ -123,7 +297,19 @@ func initTables(db *sql.DB) error {
 		return fmt.Errorf("error creating game_results table: %v", err)
 	}
 
-	return nil
+	return runMigrations(db)
+}
+
+// Ping checks that the database can still be reached
+func (d *Database) Ping() error {
+	return d.db.Ping()
+}
+
+// EnableSnapshots makes every SaveGame also append the game's state to
+// game_snapshots. This is meant for debugging, since it stores a full copy of
+// the game on every action.
+func (d *Database) EnableSnapshots() {
+	d.snapshots = true
 }
 
 // Close closes the database connection
@@ -137,7 +323,7 @@ func (d *Database) GetPlayerByID(playerID string) (*game.Player, error) {
 	var balanceInt int
 	var lastLogin time.Time
 
-	err := d.db.QueryRow("SELECT id, name, balance, last_login FROM players WHERE id = ?", playerID).Scan(
+	err := d.db.QueryRow("SELECT id, name, balance, last_login FROM players WHERE id = $1", playerID).Scan(
 		&player.ID,
 		&player.Name,
 		&balanceInt,
@@ -161,6 +347,34 @@ func (d *Database) GetPlayerByID(playerID string) (*game.Player, error) {
 	return &player, nil
 }
 
+// SearchPlayers returns up to limit players whose name starts with prefix
+func (d *Database) SearchPlayers(prefix string, limit int) ([]PlayerSummary, error) {
+	// Escape LIKE wildcards so the prefix is matched literally
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(prefix)
+
+	rows, err := d.db.Query(`
+		SELECT id, name, balance FROM players
+		WHERE name LIKE $1 || '%'
+		ORDER BY name
+		LIMIT $2
+	`, escaped, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	players := []PlayerSummary{}
+	for rows.Next() {
+		var p PlayerSummary
+		if err := rows.Scan(&p.ID, &p.Name, &p.Balance); err != nil {
+			return nil, err
+		}
+		players = append(players, p)
+	}
+
+	return players, rows.Err()
+}
+
 // CreatePlayer creates a new player in the database
 func (d *Database) CreatePlayer(playerID, playerName string, initialBalance int) error {
 	now := time.Now()
@@ -173,40 +387,150 @@ func (d *Database) CreatePlayer(playerID, playerName string, initialBalance int)
 
 // UpdatePlayerBalance updates a player's balance in the database
 func (d *Database) UpdatePlayerBalance(playerID string, newBalance int) error {
+	if newBalance < 0 {
+		return fmt.Errorf("refusing to set negative balance %d for player %s", newBalance, playerID)
+	}
+
 	_, err := d.db.Exec(
-		"UPDATE players SET balance = ?, last_login = ? WHERE id = ?",
+		"UPDATE players SET balance = $1, last_login = $2 WHERE id = $3",
 		newBalance, time.Now(), playerID,
 	)
 	return err
 }
 
+// AddToBalance adds amount to a player's balance in a single update, so
+// concurrent top-ups can't overwrite each other, and returns the new balance.
+// It returns sql.ErrNoRows if the player doesn't exist.
+func (d *Database) AddToBalance(playerID string, amount int) (int, error) {
+	var balance int
+	err := d.db.QueryRow(
+		"UPDATE players SET balance = balance + $1 WHERE id = $2 RETURNING balance",
+		amount, playerID,
+	).Scan(&balance)
+	return balance, err
+}
+
 // UpdatePlayerLastLogin updates a player's last login timestamp
 func (d *Database) UpdatePlayerLastLogin(playerID string) error {
 	_, err := d.db.Exec(
-		"UPDATE players SET last_login = ? WHERE id = ?",
+		"UPDATE players SET last_login = $1 WHERE id = $2",
 		time.Now(), playerID,
 	)
 	return err
 }
 
-// SaveGame saves a game to the database
-func (d *Database) SaveGame(game *game.BlackjackGame) error {
+// SaveGame saves a game to the database, bumping its version. It returns
+// game.ErrStaleVersion without saving if the stored game has a different
+// version from the one being saved, i.e. someone else saved it since it was
+// loaded.
+func (d *Database) SaveGame(g *game.BlackjackGame) error {
+	expected := g.Version
+	g.Version++
+
 	// Convert game state to JSON
-	gameState, err := json.Marshal(game)
+	gameState, err := json.Marshal(g)
 	if err != nil {
+		g.Version = expected
 		return err
 	}
 
-	_, err = d.db.Exec(`
-		INSERT INTO games (id, table_id, created_at, updated_at, status, game_state, min_bet, max_bet)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	result, err := d.db.Exec(`
+		INSERT INTO games (id, table_id, created_at, updated_at, status, game_state, min_bet, max_bet, version)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 		ON CONFLICT (id) DO UPDATE
-		SET updated_at = $4, status = $5, game_state = $6, min_bet = $7, max_bet = $8
+		SET updated_at = $4, status = $5, game_state = $6, min_bet = $7, max_bet = $8, version = $9
+		WHERE games.version = $10
 	`,
-		game.ID, game.TableID, game.CreatedAt, time.Now(), string(game.Status), gameState, game.MinBet, game.MaxBet)
+		g.ID, g.TableID, g.CreatedAt, time.Now(), string(g.Status), gameState, g.MinBet, g.MaxBet, g.Version, expected)
+	if err != nil {
+		g.Version = expected
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		g.Version = expected
+		return err
+	}
+	if rows == 0 {
+		g.Version = expected
+		return game.ErrStaleVersion
+	}
+
+	if d.snapshots {
+		if err := d.SaveSnapshot(g.ID, gameState); err != nil {
+			log.Printf("Error saving snapshot for game %s: %v", g.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// SaveSnapshot appends a game state to the game's snapshot history
+func (d *Database) SaveSnapshot(gameID string, gameState []byte) error {
+	_, err := d.db.Exec(`
+		INSERT INTO game_snapshots (game_id, sequence, game_state, created_at)
+		SELECT $1, COALESCE(MAX(sequence), 0) + 1, $2, $3
+		FROM game_snapshots WHERE game_id = $1
+	`, gameID, gameState, time.Now())
+	return err
+}
+
+// GetSnapshots retrieves a game's snapshot history, oldest first
+func (d *Database) GetSnapshots(gameID string) ([]GameSnapshot, error) {
+	rows, err := d.db.Query(`
+		SELECT game_id, sequence, game_state, created_at FROM game_snapshots
+		WHERE game_id = $1 ORDER BY sequence
+	`, gameID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshots := []GameSnapshot{}
+	for rows.Next() {
+		var snapshot GameSnapshot
+		var gameState []byte
+		if err := rows.Scan(&snapshot.GameID, &snapshot.Sequence, &gameState, &snapshot.CreatedAt); err != nil {
+			return nil, err
+		}
+		snapshot.GameState = gameState
+		snapshots = append(snapshots, snapshot)
+	}
+
+	return snapshots, rows.Err()
+}
+
+// AdjustHouseBalance adds a settled round's house result to the table's
+// running balance
+func (d *Database) AdjustHouseBalance(tableID string, delta int) error {
+	_, err := d.db.Exec(`
+		INSERT INTO house_balances (table_id, balance, rounds, updated_at)
+		VALUES ($1, $2, 1, $3)
+		ON CONFLICT (table_id) DO UPDATE
+		SET balance = house_balances.balance + EXCLUDED.balance,
+			rounds = house_balances.rounds + 1,
+			updated_at = EXCLUDED.updated_at
+	`, tableID, delta, time.Now())
 	return err
 }
 
+// GetHouseBalance retrieves the house's running balance at a table. A table
+// with no settled rounds has a zero balance.
+func (d *Database) GetHouseBalance(tableID string) (*HouseBalance, error) {
+	house := &HouseBalance{TableID: tableID}
+	err := d.db.QueryRow(`
+		SELECT balance, rounds, updated_at FROM house_balances WHERE table_id = $1
+	`, tableID).Scan(&house.Balance, &house.Rounds, &house.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return house, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return house, nil
+}
+
 // GetGame retrieves a game by ID
 func (d *Database) GetGame(id string) (*game.BlackjackGame, error) {
 	var gameState []byte
@@ -216,8 +540,11 @@ func (d *Database) GetGame(id string) (*game.BlackjackGame, error) {
 		SELECT game_state FROM games WHERE id = $1
 	`, id).Scan(&gameState)
 
+	if err == sql.ErrNoRows {
+		return nil, ErrGameNotFound
+	}
 	if err != nil {
-		return nil, errors.New("game not found")
+		return nil, err
 	}
 
 	if err := json.Unmarshal(gameState, &g); err != nil {
@@ -256,7 +583,8 @@ func (d *Database) GetTableGames(tableID string) ([]*game.BlackjackGame, error)
 	return games, nil
 }
 
-// GetActiveTableGame retrieves the active game for a table
+// GetActiveTableGame retrieves the most recently updated active game for a
+// table
 func (d *Database) GetActiveTableGame(tableID string) (*game.BlackjackGame, error) {
 	var gameState []byte
 	var g game.BlackjackGame
@@ -264,7 +592,7 @@ func (d *Database) GetActiveTableGame(tableID string) (*game.BlackjackGame, erro
 	err := d.db.QueryRow(`
 		SELECT game_state FROM games 
 		WHERE table_id = $1 AND status != $2 
-		ORDER BY created_at DESC LIMIT 1
+		ORDER BY updated_at DESC LIMIT 1
 	`, tableID, string(game.Completed)).Scan(&gameState)
 
 	if err != nil {
@@ -278,10 +606,176 @@ func (d *Database) GetActiveTableGame(tableID string) (*game.BlackjackGame, erro
 	return &g, nil
 }
 
-// DeleteGame removes a game from the database
+// GetActiveGames retrieves the most recently updated active game of every
+// table in a single query
+func (d *Database) GetActiveGames() ([]*game.BlackjackGame, error) {
+	rows, err := d.db.Query(`
+		SELECT DISTINCT ON (table_id) game_state FROM games
+		WHERE status != $1
+		ORDER BY table_id, updated_at DESC
+	`, string(game.Completed))
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var games []*game.BlackjackGame
+	for rows.Next() {
+		var gameState []byte
+		if err := rows.Scan(&gameState); err != nil {
+			return nil, err
+		}
+
+		var g game.BlackjackGame
+		if err := json.Unmarshal(gameState, &g); err != nil {
+			return nil, err
+		}
+
+		games = append(games, &g)
+	}
+
+	return games, rows.Err()
+}
+
+// JoinGame seats a player in a game inside a transaction. The game row is
+// locked while the seat check and update happen, so two players racing for
+// the last seat can't both get it.
+func (d *Database) JoinGame(gameID string, player game.Player) (*game.BlackjackGame, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error starting join: %w", err)
+	}
+	defer tx.Rollback()
+
+	var gameState []byte
+	err = tx.QueryRow(`
+		SELECT game_state FROM games WHERE id = $1 FOR UPDATE
+	`, gameID).Scan(&gameState)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrGameNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error loading game %s: %w", gameID, err)
+	}
+
+	var g game.BlackjackGame
+	if err := json.Unmarshal(gameState, &g); err != nil {
+		return nil, fmt.Errorf("error decoding game %s: %w", gameID, err)
+	}
+
+	// Players already seated can always rejoin
+	seated := false
+	for _, p := range g.Players {
+		if p.ID == player.ID {
+			seated = true
+			break
+		}
+	}
+	if !seated && g.IsFull() {
+		return nil, game.ErrTableFull
+	}
+
+	if g.AddPlayer(player.ID, player.Name, player.Balance) == nil {
+		return nil, errors.New("unable to join game")
+	}
+	g.Version++
+
+	gameState, err = json.Marshal(&g)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding game %s: %w", gameID, err)
+	}
+
+	_, err = tx.Exec(`
+		UPDATE games SET updated_at = $2, status = $3, game_state = $4, version = $5 WHERE id = $1
+	`, g.ID, time.Now(), string(g.Status), gameState, g.Version)
+	if err != nil {
+		return nil, fmt.Errorf("error saving game %s: %w", gameID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing join: %w", err)
+	}
+
+	return &g, nil
+}
+
+// DeleteGame removes a game from the database, along with its results and
+// snapshots
 func (d *Database) DeleteGame(id string) error {
-	_, err := d.db.Exec("DELETE FROM games WHERE id = $1", id)
-	return err
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Results reference the game, so they have to go first
+	for _, query := range []string{
+		"DELETE FROM game_results WHERE game_id = $1",
+		"DELETE FROM game_snapshots WHERE game_id = $1",
+		"DELETE FROM games WHERE id = $1",
+	} {
+		if _, err := tx.Exec(query, id); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// DeleteStaleGames removes waiting and completed games that haven't been
+// updated since cutoff, along with their snapshots, and returns how many
+// games were removed. Games with recorded results are kept so player history
+// stays intact.
+func (d *Database) DeleteStaleGames(cutoff time.Time) (int, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Lock the stale games so one updated meanwhile isn't half deleted
+	rows, err := tx.Query(`
+		SELECT id FROM games
+		WHERE updated_at < $1 AND status IN ($2, $3)
+		AND NOT EXISTS (SELECT 1 FROM game_results WHERE game_results.game_id = games.id)
+		FOR UPDATE
+	`, cutoff, string(game.Waiting), string(game.Completed))
+	if err != nil {
+		return 0, err
+	}
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	for _, query := range []string{
+		"DELETE FROM game_snapshots WHERE game_id = ANY($1)",
+		"DELETE FROM games WHERE id = ANY($1)",
+	} {
+		if _, err := tx.Exec(query, pq.Array(ids)); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(ids), nil
 }
 
 // GetAllGames returns all games in the database
@@ -323,58 +817,253 @@ func (d *Database) UpdateGameStatus(gameID string, status game.GameStatus) error
 	}
 
 	_, err := d.db.Exec(
-		"UPDATE games SET status = ?, completed_at = ? WHERE id = ?",
+		"UPDATE games SET status = $1, completed_at = $2 WHERE id = $3",
 		string(status), completedAt, gameID,
 	)
 	return err
 }
 
+// TransitionStatus moves a game from one status to another only if it is
+// still in the expected status, returning whether the transition applied
+func (d *Database) TransitionStatus(gameID string, from, to game.GameStatus) (bool, error) {
+	result, err := d.db.Exec(`
+		UPDATE games
+		SET status = $3, game_state = jsonb_set(game_state, '{status}', to_jsonb($3::text)), updated_at = $4
+		WHERE id = $1 AND status = $2
+	`, gameID, string(from), string(to), time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
 // SaveGameResult saves a game result for a player
-func (d *Database) SaveGameResult(gameID, playerID string, bet int, result string, winnings int) error {
+func (d *Database) SaveGameResult(gameID, playerID string, handIndex, bet int, result string, winnings, playerScore, dealerScore int) error {
 	_, err := d.db.Exec(
-		"INSERT INTO game_results (game_id, player_id, bet, result, winnings, created_at) VALUES (?, ?, ?, ?, ?, ?)",
-		gameID, playerID, bet, result, winnings, time.Now(),
+		"INSERT INTO game_results (game_id, player_id, hand_index, bet, result, winnings, player_score, dealer_score, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)",
+		gameID, playerID, handIndex, bet, result, winnings, playerScore, dealerScore, time.Now(),
 	)
 	return err
 }
 
+// SaveRoundResults records a completed round in a single transaction: a
+// result for every hand, bonus and side bet, each player's new balance and
+// the house's side of the round. Either all of it is saved or none of it is.
+func (d *Database) SaveRoundResults(g *game.BlackjackGame) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	for _, result := range g.SettleResults() {
+		_, err := tx.Exec(
+			"INSERT INTO game_results (game_id, player_id, hand_index, bet, result, winnings, player_score, dealer_score, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)",
+			g.ID, result.PlayerID, result.HandIndex, result.Bet, result.Outcome, result.Winnings, result.PlayerScore, g.Dealer.Score, now,
+		)
+		if err != nil {
+			return fmt.Errorf("error saving result for player %s: %v", result.PlayerID, err)
+		}
+	}
+
+	// A player with several seats has one balance, so it is written once
+	updated := make(map[string]bool)
+	for _, player := range g.Players {
+		if player.SitsOutRound() || updated[player.ID] {
+			continue
+		}
+		if player.Balance < 0 {
+			return fmt.Errorf("refusing to set negative balance %d for player %s", player.Balance, player.ID)
+		}
+
+		_, err := tx.Exec(
+			"UPDATE players SET balance = $1, last_login = $2 WHERE id = $3",
+			player.Balance, now, player.ID,
+		)
+		if err != nil {
+			return fmt.Errorf("error updating balance for player %s: %v", player.ID, err)
+		}
+		updated[player.ID] = true
+	}
+
+	// The house takes the other side of every settlement
+	_, err = tx.Exec(`
+		INSERT INTO house_balances (table_id, balance, rounds, updated_at)
+		VALUES ($1, $2, 1, $3)
+		ON CONFLICT (table_id) DO UPDATE
+		SET balance = house_balances.balance + EXCLUDED.balance,
+			rounds = house_balances.rounds + 1,
+			updated_at = EXCLUDED.updated_at
+	`, g.TableID, g.HouseNet(), now)
+	if err != nil {
+		return fmt.Errorf("error updating house balance for table %s: %v", g.TableID, err)
+	}
+
+	return tx.Commit()
+}
+
+// GetGameResult retrieves a player's settled result for a game. It returns
+// nil if the game hasn't been settled for the player.
+func (d *Database) GetGameResult(gameID, playerID string) (*GameResult, error) {
+	var r GameResult
+	var playerScore, dealerScore sql.NullInt64
+
+	err := d.db.QueryRow(`
+		SELECT game_id, player_id, hand_index, bet, result, winnings, player_score, dealer_score, created_at
+		FROM game_results
+		WHERE game_id = $1 AND player_id = $2
+		ORDER BY created_at DESC LIMIT 1
+	`, gameID, playerID).Scan(
+		&r.GameID,
+		&r.PlayerID,
+		&r.HandIndex,
+		&r.Bet,
+		&r.Result,
+		&r.Winnings,
+		&playerScore,
+		&dealerScore,
+		&r.CreatedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // Not settled yet
+		}
+		return nil, err
+	}
+
+	r.PlayerScore = int(playerScore.Int64)
+	r.DealerScore = int(dealerScore.Int64)
+
+	return &r, nil
+}
+
+// GetGameResults retrieves every settled result for a game, one row per
+// hand, ordered by player and hand
+func (d *Database) GetGameResults(gameID string) ([]GameResult, error) {
+	rows, err := d.db.Query(`
+		SELECT game_id, player_id, hand_index, bet, result, winnings, player_score, dealer_score, created_at
+		FROM game_results
+		WHERE game_id = $1
+		ORDER BY player_id, hand_index, id
+	`, gameID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []GameResult{}
+	for rows.Next() {
+		var r GameResult
+		var playerScore, dealerScore sql.NullInt64
+		err := rows.Scan(
+			&r.GameID,
+			&r.PlayerID,
+			&r.HandIndex,
+			&r.Bet,
+			&r.Result,
+			&r.Winnings,
+			&playerScore,
+			&dealerScore,
+			&r.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		r.PlayerScore = int(playerScore.Int64)
+		r.DealerScore = int(dealerScore.Int64)
+		results = append(results, r)
+	}
+
+	return results, rows.Err()
+}
+
+// GetPlayerHistory returns a page of a player's hand results, most recent first
+func (d *Database) GetPlayerHistory(playerID string, limit, offset int) ([]GameResultRecord, error) {
+	rows, err := d.db.Query(`
+		SELECT r.game_id, g.table_id, r.hand_index, r.bet, r.result, r.winnings, r.player_score, r.dealer_score, r.created_at
+		FROM game_results r
+		JOIN games g ON g.id = r.game_id
+		WHERE r.player_id = $1
+		ORDER BY r.created_at DESC, r.id DESC
+		LIMIT $2 OFFSET $3
+	`, playerID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []GameResultRecord{}
+	for rows.Next() {
+		var r GameResultRecord
+		var playerScore, dealerScore sql.NullInt64
+		err := rows.Scan(
+			&r.GameID,
+			&r.TableID,
+			&r.HandIndex,
+			&r.Bet,
+			&r.Result,
+			&r.Winnings,
+			&playerScore,
+			&dealerScore,
+			&r.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		r.PlayerScore = int(playerScore.Int64)
+		r.DealerScore = int(dealerScore.Int64)
+		records = append(records, r)
+	}
+
+	return records, rows.Err()
+}
+
 // GetPlayerStats retrieves a player's statistics
 func (d *Database) GetPlayerStats(playerID string) (*PlayerStats, error) {
 	var stats PlayerStats
 	var playerName string
 
 	// Get player name
-	err := d.db.QueryRow("SELECT name FROM players WHERE id = ?", playerID).Scan(&playerName)
+	err := d.db.QueryRow("SELECT name FROM players WHERE id = $1", playerID).Scan(&playerName)
 	if err != nil {
 		return nil, err
 	}
 
 	// Get total games played
-	err = d.db.QueryRow("SELECT COUNT(DISTINCT game_id) FROM game_results WHERE player_id = ?", playerID).Scan(&stats.GamesPlayed)
+	err = d.db.QueryRow("SELECT COUNT(DISTINCT game_id) FROM game_results WHERE player_id = $1", playerID).Scan(&stats.GamesPlayed)
 	if err != nil {
 		log.Printf("Error getting games played: %v", err)
 	}
 
 	// Get total games won
-	err = d.db.QueryRow("SELECT COUNT(DISTINCT game_id) FROM game_results WHERE player_id = ? AND result = 'win'", playerID).Scan(&stats.GamesWon)
+	err = d.db.QueryRow("SELECT COUNT(DISTINCT game_id) FROM game_results WHERE player_id = $1 AND result = 'win'", playerID).Scan(&stats.GamesWon)
 	if err != nil {
 		log.Printf("Error getting games won: %v", err)
 	}
 
 	// Get total bets
-	err = d.db.QueryRow("SELECT COALESCE(SUM(bet), 0) FROM game_results WHERE player_id = ?", playerID).Scan(&stats.TotalBets)
+	err = d.db.QueryRow("SELECT COALESCE(SUM(bet), 0) FROM game_results WHERE player_id = $1", playerID).Scan(&stats.TotalBets)
 	if err != nil {
 		log.Printf("Error getting total bets: %v", err)
 	}
 
 	// Get total winnings
-	err = d.db.QueryRow("SELECT COALESCE(SUM(winnings), 0) FROM game_results WHERE player_id = ?", playerID).Scan(&stats.TotalWinnings)
+	err = d.db.QueryRow("SELECT COALESCE(SUM(winnings), 0) FROM game_results WHERE player_id = $1", playerID).Scan(&stats.TotalWinnings)
 	if err != nil {
 		log.Printf("Error getting total winnings: %v", err)
 	}
 
 	// Get last played timestamp
-	err = d.db.QueryRow("SELECT MAX(created_at) FROM game_results WHERE player_id = ?", playerID).Scan(&stats.LastPlayed)
+	err = d.db.QueryRow("SELECT MAX(created_at) FROM game_results WHERE player_id = $1", playerID).Scan(&stats.LastPlayed)
 	if err != nil && err != sql.ErrNoRows {
 		log.Printf("Error getting last played: %v", err)
 	}
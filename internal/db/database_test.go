@@ -0,0 +1,899 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/calvinwijaya/card-games-be/internal/game"
+	"github.com/google/uuid"
+)
+
+// testDatabase connects to a fresh test schema with every table created and
+// every migration applied
+func testDatabase(t *testing.T) *Database {
+	t.Helper()
+
+	conn := testSchema(t)
+	if err := initTables(conn); err != nil {
+		t.Fatalf("initTables: %v", err)
+	}
+	return &Database{db: conn}
+}
+
+// testSchema connects to the Postgres database named by TEST_DB_NAME and
+// gives the test an empty schema of its own, dropped again when the test
+// ends. The other settings come from the usual DB_* variables. Tests using it
+// are skipped when TEST_DB_NAME is unset.
+func testSchema(t *testing.T) *sql.DB {
+	t.Helper()
+
+	name := os.Getenv("TEST_DB_NAME")
+	if name == "" {
+		t.Skip("TEST_DB_NAME not set, skipping Postgres test")
+	}
+
+	cfg := DatabaseConfig{
+		Host:     envOrDefault("DB_HOST", "localhost"),
+		Port:     envOrDefault("DB_PORT", "5432"),
+		Name:     name,
+		User:     envOrDefault("DB_USER", "postgres"),
+		Password: os.Getenv("DB_PASSWORD"),
+		SSLMode:  envOrDefault("DB_SSLMODE", "disable"),
+	}
+
+	admin, err := sql.Open("postgres", cfg.connString())
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+
+	schema := "test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		admin.Close()
+		t.Fatalf("create schema: %v", err)
+	}
+
+	// Every pooled connection starts with the test's schema on its path
+	conn, err := sql.Open("postgres", cfg.connString()+" search_path="+schema)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+
+	t.Cleanup(func() {
+		conn.Close()
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		admin.Close()
+	})
+	return conn
+}
+
+// createTestPlayer adds a player with the balance, failing the test if it
+// can't
+func createTestPlayer(t *testing.T, d *Database, balance int) string {
+	t.Helper()
+
+	id := uuid.NewString()
+	if err := d.CreatePlayer(id, "player-"+id[:8], balance); err != nil {
+		t.Fatalf("CreatePlayer: %v", err)
+	}
+	return id
+}
+
+// playerBalance reads a player's stored balance
+func playerBalance(t *testing.T, d *Database, playerID string) int {
+	t.Helper()
+
+	player, err := d.GetPlayerByID(playerID)
+	if err != nil || player == nil {
+		t.Fatalf("GetPlayerByID(%s) = %v, %v", playerID, player, err)
+	}
+	return player.Balance
+}
+
+func TestBalanceCannotGoNegative(t *testing.T) {
+	d := testDatabase(t)
+	playerID := createTestPlayer(t, d, 10)
+
+	tests := []struct {
+		name  string
+		write func() error
+	}{
+		{"set negative", func() error { return d.UpdatePlayerBalance(playerID, -1) }},
+		{"add past zero", func() error { _, err := d.AddToBalance(playerID, -11); return err }},
+		{"insert negative", func() error { return d.CreatePlayer(uuid.NewString(), "broke", -5) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.write(); err == nil {
+				t.Fatal("write succeeded, want an error")
+			}
+			if got := playerBalance(t, d, playerID); got != 10 {
+				t.Errorf("balance = %d, want 10", got)
+			}
+		})
+	}
+
+	// Down to exactly zero is allowed
+	balance, err := d.AddToBalance(playerID, -10)
+	if err != nil || balance != 0 {
+		t.Errorf("AddToBalance(-10) = %d, %v, want 0, nil", balance, err)
+	}
+}
+
+func TestAddToBalanceConcurrent(t *testing.T) {
+	d := testDatabase(t)
+	playerID := createTestPlayer(t, d, 100)
+
+	const topUps = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, topUps)
+	for i := 0; i < topUps; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := d.AddToBalance(playerID, 50); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("AddToBalance: %v", err)
+	}
+	if got, want := playerBalance(t, d, playerID), 100+topUps*50; got != want {
+		t.Errorf("balance = %d, want %d", got, want)
+	}
+
+	if _, err := d.AddToBalance("no-such-player", 50); err != sql.ErrNoRows {
+		t.Errorf("AddToBalance for a missing player = %v, want sql.ErrNoRows", err)
+	}
+}
+
+// saveTestGame saves a new game with the status, last updated at updatedAt
+func saveTestGame(t *testing.T, d *Database, tableID string, status game.GameStatus, updatedAt time.Time) *game.BlackjackGame {
+	t.Helper()
+
+	g := game.NewBlackjackGame(tableID, 10, 1000, 1)
+	g.Status = status
+	if err := d.SaveGame(g); err != nil {
+		t.Fatalf("SaveGame: %v", err)
+	}
+	if _, err := d.db.Exec("UPDATE games SET updated_at = $1 WHERE id = $2", updatedAt, g.ID); err != nil {
+		t.Fatalf("backdate game: %v", err)
+	}
+	return g
+}
+
+func TestDeleteStaleGames(t *testing.T) {
+	d := testDatabase(t)
+	playerID := createTestPlayer(t, d, 1000)
+	old := time.Now().Add(-48 * time.Hour)
+
+	staleCompleted := saveTestGame(t, d, "table-1", game.Completed, old)
+	staleWaiting := saveTestGame(t, d, "table-2", game.Waiting, old)
+	staleInProgress := saveTestGame(t, d, "table-3", game.InProgress, old)
+	freshCompleted := saveTestGame(t, d, "table-4", game.Completed, time.Now())
+	staleWithResults := saveTestGame(t, d, "table-5", game.Completed, old)
+
+	for _, g := range []*game.BlackjackGame{freshCompleted, staleWithResults} {
+		if err := d.SaveGameResult(g.ID, playerID, 0, 10, "win", 20, 20, 18); err != nil {
+			t.Fatalf("SaveGameResult: %v", err)
+		}
+	}
+
+	deleted, err := d.DeleteStaleGames(time.Now().Add(-24 * time.Hour))
+	if err != nil {
+		t.Fatalf("DeleteStaleGames: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("deleted %d games, want 2", deleted)
+	}
+
+	tests := []struct {
+		name    string
+		g       *game.BlackjackGame
+		kept    bool
+		results int
+	}{
+		{"stale completed", staleCompleted, false, 0},
+		{"stale waiting", staleWaiting, false, 0},
+		{"stale in progress", staleInProgress, true, 0},
+		{"fresh completed", freshCompleted, true, 1},
+		{"stale with results", staleWithResults, true, 1},
+	}
+	for _, tt := range tests {
+		_, err := d.GetGame(tt.g.ID)
+		if kept := err == nil; kept != tt.kept {
+			t.Errorf("%s game kept = %v, want %v", tt.name, kept, tt.kept)
+		}
+
+		// Player history outlives the sweep
+		results, err := d.GetGameResults(tt.g.ID)
+		if err != nil {
+			t.Fatalf("GetGameResults: %v", err)
+		}
+		if len(results) != tt.results {
+			t.Errorf("%s game has %d results, want %d", tt.name, len(results), tt.results)
+		}
+	}
+}
+
+func TestMissingGame(t *testing.T) {
+	d := testDatabase(t)
+
+	if _, err := d.GetGame("missing"); !errors.Is(err, ErrGameNotFound) {
+		t.Errorf("GetGame = %v, want %v", err, ErrGameNotFound)
+	}
+	if _, err := d.JoinGame("missing", game.Player{ID: "p1", Name: "Ann", Balance: 1000}); !errors.Is(err, ErrGameNotFound) {
+		t.Errorf("JoinGame = %v, want %v", err, ErrGameNotFound)
+	}
+}
+
+func TestJoinGame(t *testing.T) {
+	d := testDatabase(t)
+	g := saveTestGame(t, d, "table-1", game.Waiting, time.Now())
+	g.MaxPlayers = 1
+	if err := d.SaveGame(g); err != nil {
+		t.Fatalf("SaveGame: %v", err)
+	}
+
+	joined, err := d.JoinGame(g.ID, game.Player{ID: "p1", Name: "Ann", Balance: 500})
+	if err != nil {
+		t.Fatalf("JoinGame: %v", err)
+	}
+	if !joined.HasPlayer("p1") || joined.Version != g.Version+1 {
+		t.Errorf("joined game has p1 = %v at version %d, want true at %d", joined.HasPlayer("p1"), joined.Version, g.Version+1)
+	}
+
+	tests := []struct {
+		name   string
+		gameID string
+		player string
+		want   error
+	}{
+		{"rejoin", g.ID, "p1", nil},
+		{"table full", g.ID, "p2", game.ErrTableFull},
+		{"missing game", "no-such-game", "p1", ErrGameNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := d.JoinGame(tt.gameID, game.Player{ID: tt.player, Balance: 500})
+			if !errors.Is(err, tt.want) {
+				t.Errorf("JoinGame = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	// Anything other than a missing row is passed on, not reported as not found
+	d.Close()
+	_, err = d.JoinGame(g.ID, game.Player{ID: "p3"})
+	if err == nil || errors.Is(err, ErrGameNotFound) {
+		t.Errorf("JoinGame on a closed database = %v, want a connection error", err)
+	}
+}
+
+func TestGetActiveGames(t *testing.T) {
+	d := testDatabase(t)
+	now := time.Now()
+
+	saveTestGame(t, d, "table-1", game.Waiting, now.Add(-2*time.Hour))
+	latest := saveTestGame(t, d, "table-1", game.Betting, now.Add(-time.Hour))
+	saveTestGame(t, d, "table-1", game.Completed, now)
+	only := saveTestGame(t, d, "table-2", game.InProgress, now.Add(-3*time.Hour))
+	saveTestGame(t, d, "table-3", game.Completed, now)
+
+	games, err := d.GetActiveGames()
+	if err != nil {
+		t.Fatalf("GetActiveGames: %v", err)
+	}
+
+	got := make(map[string]string)
+	for _, g := range games {
+		got[g.TableID] = g.ID
+	}
+	want := map[string]string{"table-1": latest.ID, "table-2": only.ID}
+	if len(got) != len(want) || len(games) != len(want) {
+		t.Fatalf("got games for %v, want one each for table-1 and table-2", got)
+	}
+	for tableID, id := range want {
+		if got[tableID] != id {
+			t.Errorf("%s: got game %s, want the latest active game %s", tableID, got[tableID], id)
+		}
+	}
+}
+
+func TestGetGameResult(t *testing.T) {
+	d := testDatabase(t)
+	playerID := createTestPlayer(t, d, 1000)
+	g := saveTestGame(t, d, "table-1", game.Completed, time.Now())
+
+	result, err := d.GetGameResult(g.ID, playerID)
+	if err != nil || result != nil {
+		t.Fatalf("unsettled game: GetGameResult = %v, %v, want nil, nil", result, err)
+	}
+
+	if err := d.SaveGameResult(g.ID, playerID, 0, 10, "win", 20, 20, 18); err != nil {
+		t.Fatalf("SaveGameResult: %v", err)
+	}
+	result, err = d.GetGameResult(g.ID, playerID)
+	if err != nil || result == nil {
+		t.Fatalf("GetGameResult = %v, %v", result, err)
+	}
+	want := GameResult{GameID: g.ID, PlayerID: playerID, Bet: 10, Result: "win", Winnings: 20, PlayerScore: 20, DealerScore: 18}
+	result.CreatedAt = time.Time{}
+	if *result != want {
+		t.Errorf("GetGameResult = %+v, want %+v", result, want)
+	}
+
+	// Another player's result isn't returned
+	if other, err := d.GetGameResult(g.ID, "someone-else"); err != nil || other != nil {
+		t.Errorf("other player: GetGameResult = %v, %v, want nil, nil", other, err)
+	}
+}
+
+func TestSearchPlayers(t *testing.T) {
+	d := testDatabase(t)
+	for _, name := range []string{"alice", "alicia", "albert", "bob", "al_x", "alxa"} {
+		if err := d.CreatePlayer(uuid.NewString(), name, 100); err != nil {
+			t.Fatalf("CreatePlayer(%s): %v", name, err)
+		}
+	}
+
+	tests := []struct {
+		prefix string
+		limit  int
+		want   []string
+	}{
+		{"ali", 10, []string{"alice", "alicia"}},
+		{"ali", 1, []string{"alice"}},
+		{"al_", 10, []string{"al_x"}},
+		{"bo", 10, []string{"bob"}},
+		{"zed", 10, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			players, err := d.SearchPlayers(tt.prefix, tt.limit)
+			if err != nil {
+				t.Fatalf("SearchPlayers: %v", err)
+			}
+			names := []string{}
+			for _, p := range players {
+				names = append(names, p.Name)
+			}
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("SearchPlayers(%q, %d) = %v, want %v", tt.prefix, tt.limit, names, tt.want)
+			}
+		})
+	}
+}
+
+func TestSnapshots(t *testing.T) {
+	d := testDatabase(t)
+	d.EnableSnapshots()
+
+	g := game.NewBlackjackGame("table-1", 10, 500, 1)
+	g.AddPlayer("p1", "Ann", 1000)
+	if err := d.SaveGame(g); err != nil {
+		t.Fatalf("SaveGame: %v", err)
+	}
+
+	// Deal hands that leave p1 to act, so the round stays in progress
+	ten := game.Card{Suit: game.Hearts, Rank: game.Ten}
+	seven := game.Card{Suit: game.Spades, Rank: game.Seven}
+	g.Deck.Cards = append([]game.Card{ten, seven, ten, seven}, g.Deck.Cards...)
+
+	actions := []func() bool{
+		g.OpenBetting,
+		func() bool { return g.PlaceBet("p1", 10) == nil },
+		func() bool { return g.Start() == nil },
+	}
+	for i, act := range actions {
+		if !act() {
+			t.Fatalf("action %d failed", i+1)
+		}
+		if err := d.SaveGame(g); err != nil {
+			t.Fatalf("SaveGame after action %d: %v", i+1, err)
+		}
+	}
+
+	snapshots, err := d.GetSnapshots(g.ID)
+	if err != nil {
+		t.Fatalf("GetSnapshots: %v", err)
+	}
+	if len(snapshots) != len(actions)+1 {
+		t.Fatalf("got %d snapshots, want %d", len(snapshots), len(actions)+1)
+	}
+
+	wantStatus := []game.GameStatus{game.Waiting, game.Betting, game.Betting, game.InProgress}
+	for i, snapshot := range snapshots {
+		var state game.BlackjackGame
+		if err := json.Unmarshal(snapshot.GameState, &state); err != nil {
+			t.Fatalf("snapshot %d: %v", i, err)
+		}
+		if snapshot.Sequence != i+1 || state.Status != wantStatus[i] {
+			t.Errorf("snapshot %d is #%d with status %s, want #%d with %s", i, snapshot.Sequence, state.Status, i+1, wantStatus[i])
+		}
+	}
+}
+
+func TestTransitionStatusConcurrent(t *testing.T) {
+	d := testDatabase(t)
+	g := saveTestGame(t, d, "table-1", game.Betting, time.Now())
+
+	const racers = 10
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	applied := 0
+	for i := 0; i < racers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := d.TransitionStatus(g.ID, game.Betting, game.InProgress)
+			if err != nil {
+				t.Errorf("TransitionStatus: %v", err)
+			}
+			if ok {
+				mu.Lock()
+				applied++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if applied != 1 {
+		t.Errorf("%d transitions applied, want exactly 1", applied)
+	}
+	stored, err := d.GetGame(g.ID)
+	if err != nil {
+		t.Fatalf("GetGame: %v", err)
+	}
+	if stored.Status != game.InProgress {
+		t.Errorf("stored status = %s, want %s", stored.Status, game.InProgress)
+	}
+}
+
+// dealTestRound starts a round with a bet of 10 on every seat, dealing the
+// given cards (such as "AS" or "10H") from the top of the deck
+func dealTestRound(t *testing.T, g *game.BlackjackGame, codes ...string) {
+	t.Helper()
+
+	if g.Status == game.Waiting && !g.OpenBetting() {
+		t.Fatal("OpenBetting failed")
+	}
+	for _, p := range g.Players {
+		if err := g.PlaceSeatBet(p.ID, p.Seat, 10); err != nil {
+			t.Fatalf("PlaceSeatBet(%s): %v", p.ID, err)
+		}
+	}
+
+	stacked := make([]game.Card, len(codes))
+	for i, code := range codes {
+		card, err := game.ParseCard(code)
+		if err != nil {
+			t.Fatalf("ParseCard(%q): %v", code, err)
+		}
+		stacked[i] = card
+	}
+	g.Deck.Cards = append(stacked, g.Deck.Cards...)
+
+	if err := g.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+}
+
+func TestSaveRoundResultsPerHand(t *testing.T) {
+	d := testDatabase(t)
+	playerID := createTestPlayer(t, d, 1000)
+
+	// Split eights into 8 10 and 8 5 against the dealer's 10 7, winning
+	// the first hand and losing the second
+	g := game.NewBlackjackGame("table-1", 10, 500, 1)
+	g.AddPlayer(playerID, "Ann", 1000)
+	dealTestRound(t, g, "8H", "8S", "10D", "7C", "10H", "5S")
+	if !g.Split(playerID) || !g.Stand(playerID) || !g.Stand(playerID) {
+		t.Fatal("split and stand failed")
+	}
+	if err := d.SaveGame(g); err != nil {
+		t.Fatalf("SaveGame: %v", err)
+	}
+	if err := d.SaveRoundResults(g); err != nil {
+		t.Fatalf("SaveRoundResults: %v", err)
+	}
+
+	results, err := d.GetGameResults(g.ID)
+	if err != nil {
+		t.Fatalf("GetGameResults: %v", err)
+	}
+	want := []struct {
+		result   string
+		winnings int
+	}{{"win", 20}, {"lose", 0}}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(results), len(want))
+	}
+
+	net := 0
+	for i, w := range want {
+		r := results[i]
+		if r.HandIndex != i || r.Bet != 10 || r.Result != w.result || r.Winnings != w.winnings {
+			t.Errorf("hand %d: %+v, want a bet of 10 with %s paying %d", i, r, w.result, w.winnings)
+		}
+		net += r.Winnings - r.Bet
+	}
+	if got := playerBalance(t, d, playerID); got != 1000+net {
+		t.Errorf("balance = %d, want %d to reconcile with the hands", got, 1000+net)
+	}
+}
+
+func TestDatabaseConfigFromEnv(t *testing.T) {
+	required := map[string]string{"DB_NAME": "cards", "DB_USER": "dealer", "DB_PASSWORD": "secret"}
+	defaults := DatabaseConfig{
+		Host: "localhost", Port: "5432", Name: "cards", User: "dealer", Password: "secret", SSLMode: "disable",
+		ConnectAttempts: DefaultConnectAttempts, ConnectDelay: DefaultConnectDelay,
+	}
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    func(cfg *DatabaseConfig)
+		wantErr string
+	}{
+		{"defaults", nil, func(cfg *DatabaseConfig) {}, ""},
+		{
+			"everything set",
+			map[string]string{"DB_HOST": "db.internal", "DB_PORT": "6543", "DB_SSLMODE": "require", "DB_CONNECT_ATTEMPTS": "3", "DB_CONNECT_DELAY": "500ms"},
+			func(cfg *DatabaseConfig) {
+				cfg.Host, cfg.Port, cfg.SSLMode = "db.internal", "6543", "require"
+				cfg.ConnectAttempts, cfg.ConnectDelay = 3, 500*time.Millisecond
+			},
+			"",
+		},
+		{"missing name", map[string]string{"DB_NAME": ""}, nil, "DB_NAME"},
+		{"missing user", map[string]string{"DB_USER": ""}, nil, "DB_USER"},
+		{"missing password", map[string]string{"DB_PASSWORD": ""}, nil, "DB_PASSWORD"},
+		{"bad attempts", map[string]string{"DB_CONNECT_ATTEMPTS": "0"}, nil, "DB_CONNECT_ATTEMPTS"},
+		{"bad delay", map[string]string{"DB_CONNECT_DELAY": "soon"}, nil, "DB_CONNECT_DELAY"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"DB_HOST", "DB_PORT", "DB_SSLMODE", "DB_CONNECT_ATTEMPTS", "DB_CONNECT_DELAY"} {
+				t.Setenv(key, "")
+			}
+			for key, value := range required {
+				t.Setenv(key, value)
+			}
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			cfg, err := DatabaseConfigFromEnv()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want one naming %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DatabaseConfigFromEnv: %v", err)
+			}
+			want := defaults
+			tt.want(&want)
+			if cfg != want {
+				t.Errorf("config = %+v, want %+v", cfg, want)
+			}
+		})
+	}
+}
+
+func TestConnStringQuotesValues(t *testing.T) {
+	cfg := DatabaseConfig{Host: "localhost", Port: "5432", Name: "cards", User: "dealer", Password: `it's a \ secret`}
+	want := `host='localhost' port='5432' dbname='cards' user='dealer' password='it\'s a \\ secret' sslmode='disable'`
+	if got := cfg.connString(); got != want {
+		t.Errorf("connString = %s, want %s", got, want)
+	}
+}
+
+func TestPlayerRoundTrip(t *testing.T) {
+	d := testDatabase(t)
+
+	id := uuid.NewString()
+	if err := d.CreatePlayer(id, "Ann", 1000); err != nil {
+		t.Fatalf("CreatePlayer: %v", err)
+	}
+	player, err := d.GetPlayerByID(id)
+	if err != nil || player == nil || player.Name != "Ann" || player.Balance != 1000 {
+		t.Fatalf("GetPlayerByID = %+v, %v, want Ann with 1000", player, err)
+	}
+
+	if err := d.UpdatePlayerBalance(id, 750); err != nil {
+		t.Fatalf("UpdatePlayerBalance: %v", err)
+	}
+	if err := d.UpdatePlayerLastLogin(id); err != nil {
+		t.Fatalf("UpdatePlayerLastLogin: %v", err)
+	}
+	if got := playerBalance(t, d, id); got != 750 {
+		t.Errorf("balance = %d, want 750", got)
+	}
+
+	// A missing player isn't an error
+	if missing, err := d.GetPlayerByID(uuid.NewString()); missing != nil || err != nil {
+		t.Errorf("GetPlayerByID(unknown) = %+v, %v, want nil, nil", missing, err)
+	}
+
+	// The player's results feed their stats
+	g := saveTestGame(t, d, "table-1", game.InProgress, time.Now())
+	if err := d.UpdateGameStatus(g.ID, game.Completed); err != nil {
+		t.Fatalf("UpdateGameStatus: %v", err)
+	}
+	if err := d.SaveGameResult(g.ID, id, 0, 10, "win", 20, 20, 18); err != nil {
+		t.Fatalf("SaveGameResult: %v", err)
+	}
+	stats, err := d.GetPlayerStats(id)
+	if err != nil {
+		t.Fatalf("GetPlayerStats: %v", err)
+	}
+	if stats.PlayerName != "Ann" || stats.GamesPlayed != 1 || stats.GamesWon != 1 || stats.TotalBets != 10 || stats.TotalWinnings != 20 {
+		t.Errorf("stats = %+v, want one win of 20 on a bet of 10", stats)
+	}
+}
+
+func TestHouseBalanceMirrorsPlayers(t *testing.T) {
+	d := testDatabase(t)
+	ids := []string{createTestPlayer(t, d, 1000), createTestPlayer(t, d, 1000)}
+
+	g := game.NewBlackjackGame("table-1", 10, 500, 1)
+	for _, id := range ids {
+		g.AddPlayer(id, "Player", 1000)
+	}
+
+	const rounds = 3
+	for round := 0; round < rounds; round++ {
+		dealTestRound(t, g)
+		for g.Status == game.InProgress {
+			g.Stand(g.Players[g.CurrentPlayerIndex].ID)
+		}
+		if err := d.SaveRoundResults(g); err != nil {
+			t.Fatalf("round %d: SaveRoundResults: %v", round+1, err)
+		}
+		g.PrepareForNextRound()
+	}
+
+	players := 0
+	for _, id := range ids {
+		players += playerBalance(t, d, id) - 1000
+	}
+	house, err := d.GetHouseBalance("table-1")
+	if err != nil {
+		t.Fatalf("GetHouseBalance: %v", err)
+	}
+	if house.Balance != -players || house.Rounds != rounds {
+		t.Errorf("house has %d over %d rounds, want %d over %d", house.Balance, house.Rounds, -players, rounds)
+	}
+}
+
+func TestSavingAgainDoesNotDuplicate(t *testing.T) {
+	d := testDatabase(t)
+
+	g := game.NewBlackjackGame("table-1", 10, 500, 1)
+	for i := 0; i < 3; i++ {
+		if err := d.SaveGame(g); err != nil {
+			t.Fatalf("save %d: %v", i+1, err)
+		}
+	}
+	games, err := d.GetTableGames("table-1")
+	if err != nil {
+		t.Fatalf("GetTableGames: %v", err)
+	}
+	if len(games) != 1 || games[0].ID != g.ID {
+		t.Fatalf("got %d games for the table, want just %s", len(games), g.ID)
+	}
+
+	// The most recently updated active game is the table's game, whichever
+	// was created first
+	older := saveTestGame(t, d, "table-1", game.Betting, time.Now().Add(-time.Hour))
+	if _, err := d.db.Exec("UPDATE games SET created_at = $1 WHERE id = $2", time.Now().Add(time.Hour), older.ID); err != nil {
+		t.Fatalf("move creation: %v", err)
+	}
+	active, err := d.GetActiveTableGame("table-1")
+	if err != nil || active.ID != g.ID {
+		t.Errorf("GetActiveTableGame = %v, %v, want the latest updated %s", active, err, g.ID)
+	}
+}
+
+func TestSavedResultsMatchBalances(t *testing.T) {
+	d := testDatabase(t)
+	winner, loser := createTestPlayer(t, d, 1000), createTestPlayer(t, d, 1000)
+
+	// 19 and 16 against the dealer's 18
+	g := game.NewBlackjackGame("table-1", 10, 500, 1)
+	g.AddPlayer(winner, "Winner", 1000)
+	g.AddPlayer(loser, "Loser", 1000)
+	dealTestRound(t, g, "10H", "9H", "10C", "6C", "10S", "8S")
+	g.Stand(winner)
+	g.Stand(loser)
+	if err := d.SaveGame(g); err != nil {
+		t.Fatalf("SaveGame: %v", err)
+	}
+	if err := d.SaveRoundResults(g); err != nil {
+		t.Fatalf("SaveRoundResults: %v", err)
+	}
+
+	results, err := d.GetGameResults(g.ID)
+	if err != nil {
+		t.Fatalf("GetGameResults: %v", err)
+	}
+	net := make(map[string]int)
+	for _, r := range results {
+		net[r.PlayerID] += r.Winnings - r.Bet
+	}
+	for id, want := range map[string]int{winner: 10, loser: -10} {
+		if net[id] != want {
+			t.Errorf("saved results net %d for %s, want %d", net[id], id, want)
+		}
+		if got := playerBalance(t, d, id) - 1000; got != net[id] {
+			t.Errorf("balance moved by %d, the saved results say %d", got, net[id])
+		}
+	}
+}
+
+func TestMidRoundGameRoundTrip(t *testing.T) {
+	d := testDatabase(t)
+	g := game.NewBlackjackGame("table-1", 10, 500, 1)
+	g.AddPlayer("p1", "Ann", 1000)
+	dealTestRound(t, g, "10H", "2D", "10C", "6S", "5H", "8C")
+	g.Hit("p1")
+	if err := d.SaveGame(g); err != nil {
+		t.Fatalf("SaveGame: %v", err)
+	}
+
+	loaded, err := d.GetGame(g.ID)
+	if err != nil {
+		t.Fatalf("GetGame: %v", err)
+	}
+	if loaded.Deck == nil || len(loaded.Deck.Cards) != len(g.Deck.Cards) || len(loaded.Actions) != len(g.Actions) {
+		t.Fatal("the shoe or action log didn't survive the save")
+	}
+
+	// The loaded game carries on where the saved one left off
+	if !loaded.Stand("p1") || loaded.Status != game.Completed || loaded.Players[0].Balance != 1010 {
+		t.Errorf("status %s with p1 on %d, want p1's 17 paid when the dealer busts", loaded.Status, loaded.Players[0].Balance)
+	}
+	if err := d.SaveGame(loaded); err != nil {
+		t.Errorf("saving the loaded game: %v", err)
+	}
+}
+
+func TestSaveGameStaleVersion(t *testing.T) {
+	d := testDatabase(t)
+	g := saveTestGame(t, d, "table-1", game.Betting, time.Now())
+
+	first, err := d.GetGame(g.ID)
+	if err != nil {
+		t.Fatalf("GetGame: %v", err)
+	}
+	late, _ := d.GetGame(g.ID)
+
+	first.Players = append(first.Players, game.Player{ID: "p1", Balance: 900})
+	if err := d.SaveGame(first); err != nil {
+		t.Fatalf("first save: %v", err)
+	}
+	if err := d.SaveGame(late); !errors.Is(err, game.ErrStaleVersion) {
+		t.Fatalf("late save = %v, want %v", err, game.ErrStaleVersion)
+	}
+
+	stored, _ := d.GetGame(g.ID)
+	if stored.Version != first.Version || len(stored.Players) != 1 {
+		t.Errorf("stored version %d with %d players, want the first save's version %d", stored.Version, len(stored.Players), first.Version)
+	}
+}
+
+// fakePinger fails until it has been pinged enough times
+type fakePinger struct {
+	failures int // Pings to fail before succeeding
+	pings    int
+}
+
+func (p *fakePinger) Ping() error {
+	p.pings++
+	if p.pings <= p.failures {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func TestPingWithRetry(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  int
+		attempts  int
+		wantErr   bool
+		wantPings int
+	}{
+		{"up straight away", 0, 5, false, 1},
+		{"up on the third try", 2, 5, false, 3},
+		{"up on the last try", 4, 5, false, 5},
+		{"never up", 10, 5, true, 5},
+		{"no retries", 10, 0, true, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &fakePinger{failures: tt.failures}
+			start := time.Now()
+			err := pingWithRetry(p, tt.attempts, time.Millisecond)
+
+			if (err != nil) != tt.wantErr || p.pings != tt.wantPings {
+				t.Errorf("pingWithRetry = %v after %d pings, want error %v after %d", err, p.pings, tt.wantErr, tt.wantPings)
+			}
+
+			// The delay doubles after each failed ping: 1ms, 2ms, 4ms, ...
+			if wait, want := time.Since(start), time.Duration(1<<(p.pings-1)-1)*time.Millisecond; wait < want {
+				t.Errorf("waited %v, want at least %v of backoff", wait, want)
+			}
+		})
+	}
+}
+
+func TestSaveRoundResultsRollsBack(t *testing.T) {
+	d := testDatabase(t)
+	winner, second := createTestPlayer(t, d, 1000), createTestPlayer(t, d, 1000)
+
+	// 19 and 16 against the dealer's 18
+	g := game.NewBlackjackGame("table-1", 10, 500, 1)
+	g.AddPlayer(winner, "Winner", 1000)
+	g.AddPlayer(second, "Second", 1000)
+	dealTestRound(t, g, "10H", "9H", "10C", "6C", "10S", "8S")
+	g.Stand(winner)
+	g.Stand(second)
+	if err := d.SaveGame(g); err != nil {
+		t.Fatalf("SaveGame: %v", err)
+	}
+
+	// The second player's balance update fails after the first player's
+	// results and balance have been written
+	g.Players[1].Balance = -1
+	if err := d.SaveRoundResults(g); err == nil {
+		t.Fatal("SaveRoundResults succeeded with a negative balance")
+	}
+
+	results, err := d.GetGameResults(g.ID)
+	if err != nil {
+		t.Fatalf("GetGameResults: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("%d results saved, want the inserts rolled back", len(results))
+	}
+	for _, id := range []string{winner, second} {
+		if got := playerBalance(t, d, id); got != 1000 {
+			t.Errorf("balance = %d, want it left at 1000", got)
+		}
+	}
+	house, err := d.GetHouseBalance("table-1")
+	if err != nil {
+		t.Fatalf("GetHouseBalance: %v", err)
+	}
+	if house.Rounds != 0 || house.Balance != 0 {
+		t.Errorf("house at %d after %d rounds, want nothing settled", house.Balance, house.Rounds)
+	}
+
+	// Once fixed, the round saves in full
+	g.Players[1].Balance = 990
+	if err := d.SaveRoundResults(g); err != nil {
+		t.Fatalf("SaveRoundResults: %v", err)
+	}
+	if got := playerBalance(t, d, winner); got != 1010 {
+		t.Errorf("winner's balance = %d, want 1010", got)
+	}
+}
@@ -0,0 +1,96 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// migrations are applied in order after the base tables are created. Each
+// entry runs once and is recorded in schema_migrations by its 1-based index,
+// so existing entries must never be edited or reordered.
+var migrations = []string{
+	// 1: Balances can never go negative
+	`ALTER TABLE players ADD CONSTRAINT players_balance_nonnegative CHECK (balance >= 0)`,
+
+	// 2: Final scores recorded with each result
+	`ALTER TABLE game_results ADD COLUMN player_score INTEGER, ADD COLUMN dealer_score INTEGER`,
+
+	// 3: Prefix searches on player names
+	`CREATE INDEX IF NOT EXISTS idx_players_name_prefix ON players (name text_pattern_ops)`,
+
+	// 4: Append-only history of every saved game state
+	`CREATE TABLE IF NOT EXISTS game_snapshots (
+		game_id TEXT NOT NULL,
+		sequence INTEGER NOT NULL,
+		game_state JSONB NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (game_id, sequence)
+	)`,
+
+	// 5: One result row per hand once players can split
+	`ALTER TABLE game_results ADD COLUMN hand_index INTEGER NOT NULL DEFAULT 0`,
+
+	// 6: The house's running result at each table
+	`CREATE TABLE IF NOT EXISTS house_balances (
+		table_id TEXT PRIMARY KEY,
+		balance BIGINT NOT NULL DEFAULT 0,
+		rounds INTEGER NOT NULL DEFAULT 0,
+		updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`,
+
+	// 7: Optimistic concurrency on saved games
+	`ALTER TABLE games ADD COLUMN version INTEGER NOT NULL DEFAULT 0`,
+
+	// 8: Balances left negative are zeroed
+	`UPDATE players SET balance = 0 WHERE balance < 0`,
+}
+
+// runMigrations applies any migrations that haven't been applied yet
+func runMigrations(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating schema_migrations table: %v", err)
+	}
+
+	for i, migration := range migrations {
+		version := i + 1
+
+		var applied bool
+		err := db.QueryRow(
+			"SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)",
+			version,
+		).Scan(&applied)
+		if err != nil {
+			return fmt.Errorf("error checking migration %d: %v", version, err)
+		}
+		if applied {
+			continue
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("error starting migration %d: %v", version, err)
+		}
+
+		if _, err := tx.Exec(migration); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error applying migration %d: %v", version, err)
+		}
+
+		if _, err := tx.Exec("INSERT INTO schema_migrations (version) VALUES ($1)", version); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error recording migration %d: %v", version, err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("error committing migration %d: %v", version, err)
+		}
+	}
+
+	return nil
+}
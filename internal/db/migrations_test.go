@@ -0,0 +1,46 @@
+package db
+
+import "testing"
+
+func TestMigrationsApplyToFreshDatabase(t *testing.T) {
+	d := testDatabase(t)
+
+	var applied int
+	if err := d.db.QueryRow("SELECT COUNT(*) FROM schema_migrations").Scan(&applied); err != nil {
+		t.Fatalf("count migrations: %v", err)
+	}
+	if applied != len(migrations) {
+		t.Errorf("applied %d migrations, want %d", applied, len(migrations))
+	}
+
+	// Running them again is a no-op
+	if err := runMigrations(d.db); err != nil {
+		t.Errorf("runMigrations again: %v", err)
+	}
+}
+
+func TestMigrationsZeroNegativeBalances(t *testing.T) {
+	d := testDatabase(t)
+
+	// A balance written negative while the constraint wasn't there
+	if _, err := d.db.Exec("ALTER TABLE players DROP CONSTRAINT players_balance_nonnegative"); err != nil {
+		t.Fatalf("drop constraint: %v", err)
+	}
+	if _, err := d.db.Exec("INSERT INTO players (id, name, balance) VALUES ('p1', 'Broke', -50), ('p2', 'Flush', 200)"); err != nil {
+		t.Fatalf("insert players: %v", err)
+	}
+
+	// Migration 8 picks it up
+	if _, err := d.db.Exec("DELETE FROM schema_migrations WHERE version = 8"); err != nil {
+		t.Fatalf("unrecord migration 8: %v", err)
+	}
+	if err := runMigrations(d.db); err != nil {
+		t.Fatalf("runMigrations: %v", err)
+	}
+
+	for id, want := range map[string]int{"p1": 0, "p2": 200} {
+		if got := playerBalance(t, d, id); got != want {
+			t.Errorf("balance of %s = %d, want %d", id, got, want)
+		}
+	}
+}
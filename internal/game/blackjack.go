@@ -1,7 +1,8 @@
 package game
 
 import (
-	"fmt"
+	"errors"
+	"log"
 	"time"
 
 	"github.com/google/uuid"
@@ -16,24 +17,71 @@ const (
 	Completed  GameStatus = "completed"  // Game is completed
 )
 
+// DefaultNumDecks is the number of decks in the shoe unless configured otherwise
+const DefaultNumDecks = 1
+
+// DefaultMaxPlayers is the number of seats at a table unless configured otherwise
+const DefaultMaxPlayers = 7
+
+// ErrTableFull is returned when a player tries to take a seat at a full table
+var ErrTableFull = errors.New("table is full")
+
+// ErrStaleVersion is returned when saving a game that someone else saved
+// since it was loaded
+var ErrStaleVersion = errors.New("game was changed since it was loaded")
+
+// Reasons a bet can be refused
+var (
+	ErrNotSeated           = errors.New("player is not seated in the game")
+	ErrBetBelowMin         = errors.New("bet is below the table minimum")
+	ErrBetAboveMax         = errors.New("bet is above the table maximum")
+	ErrInsufficientBalance = errors.New("balance doesn't cover the bet")
+)
+
+// Reasons a round can't be dealt
+var (
+	ErrNotBetting = errors.New("game is not in the betting phase")
+	ErrNoPlayers  = errors.New("no players to deal in")
+	ErrMissingBet = errors.New("not every player has placed a bet")
+	ErrNotReady   = errors.New("not every player is ready")
+	ErrPayoutCap  = errors.New("total bets exceed the table's payout cap")
+)
+
 type PlayerStatus string
 
 const (
-	PlayerActive    PlayerStatus = "active"    // Player is still in the game
-	PlayerBusted    PlayerStatus = "busted"    // Player busted (score > 21)
-	PlayerStood     PlayerStatus = "stood"     // Player decided to stand
-	PlayerBlackjack PlayerStatus = "blackjack" // Player has blackjack
+	PlayerActive      PlayerStatus = "active"      // Player is still in the game
+	PlayerBusted      PlayerStatus = "busted"      // Player busted (score > 21)
+	PlayerStood       PlayerStatus = "stood"       // Player decided to stand
+	PlayerBlackjack   PlayerStatus = "blackjack"   // Player has blackjack
+	PlayerSittingOut  PlayerStatus = "sittingOut"  // Player didn't bet in time and isn't dealt this round
+	PlayerCharlie     PlayerStatus = "charlie"     // Player reached five cards without busting (FiveCardCharlie tables)
+	PlayerWaiting     PlayerStatus = "waiting"     // Player joined mid-round and is dealt in from the next round
+	PlayerSurrendered PlayerStatus = "surrendered" // Player gave up their hand for half their bet back
 )
 
 type Player struct {
-	ID       string       `json:"id"`
-	Name     string       `json:"name"`
-	Hand     []Card       `json:"hand"`
-	Score    int          `json:"score"`
-	Status   PlayerStatus `json:"status"`
-	Bet      int          `json:"bet"`
-	Balance  int          `json:"balance"`
-	IsActive bool         `json:"isActive"` // True if it's this player's turn
+	ID           string       `json:"id"`
+	Name         string       `json:"name"`
+	Hand         []Card       `json:"hand"`
+	Score        int          `json:"score"`
+	Status       PlayerStatus `json:"status"`
+	Bet          int          `json:"bet"`
+	Balance      int          `json:"balance"`
+	IsActive     bool         `json:"isActive"`           // True if it's this player's turn
+	Ready        bool         `json:"ready"`              // True once the player confirmed they're ready to be dealt
+	BonusBet     int          `json:"bonusBet"`           // House-funded bet that never draws from the balance
+	RoundsPlayed int          `json:"roundsPlayed"`       // Rounds dealt to the player at this table
+	Hands        []Hand       `json:"hands,omitempty"`    // Hands held after splitting, empty if the player hasn't split
+	ActiveHand   int          `json:"activeHand"`         // Index of the split hand being played
+	SideBets     []SideBet    `json:"sideBets,omitempty"` // Side bets for the round, settled on the deal
+	Seat         int          `json:"seat"`               // Which of the player's seats this is, 0 for the one they joined with
+}
+
+// SitsOutRound reports whether the player isn't dealt into the current round,
+// either because they didn't bet in time or because they joined mid-round
+func (p Player) SitsOutRound() bool {
+	return p.Status == PlayerSittingOut || p.Status == PlayerWaiting
 }
 
 type Dealer struct {
@@ -41,32 +89,92 @@ type Dealer struct {
 	Score int    `json:"score"`
 }
 
+// SanitizedHand returns the dealer's hand with every face-down card replaced
+// by a blank placeholder, so the hole card isn't sent to players before the
+// dealer turns it over
+func (d Dealer) SanitizedHand() []Card {
+	hand := make([]Card, len(d.Hand))
+	for i, card := range d.Hand {
+		if card.Face {
+			hand[i] = card
+		} else {
+			hand[i] = Card{Face: false}
+		}
+	}
+	return hand
+}
+
+// VisibleScore returns the score of the dealer's face-up cards alone, so the
+// hole card doesn't show through the score before the dealer turns it over
+func (d Dealer) VisibleScore(overrides map[Rank]int) int {
+	var faceUp []Card
+	for _, card := range d.Hand {
+		if card.Face {
+			faceUp = append(faceUp, card)
+		}
+	}
+	score, _ := scoreHand(faceUp, overrides)
+	return score
+}
+
 type BlackjackGame struct {
-	ID                 string     `json:"id"`
-	Players            []Player   `json:"players"`
-	Dealer             Dealer     `json:"dealer"`
-	Deck               *Deck      `json:"deck,omitempty"`
-	Status             GameStatus `json:"status"`
-	CreatedAt          time.Time  `json:"createdAt"`
-	UpdatedAt          time.Time  `json:"updatedAt"`
-	MinBet             int        `json:"minBet"`
-	MaxBet             int        `json:"maxBet"`
-	TableID            string     `json:"tableId"`
-	CurrentPlayerIndex int        `json:"currentPlayerIndex"`
-}
-
-// NewBlackjackGame creates a new blackjack game
-func NewBlackjackGame(tableID string, minBet, maxBet int) *BlackjackGame {
-	deck := NewDeck()
-	deck.Shuffle()
+	ID                    string         `json:"id"`
+	Players               []Player       `json:"players"`
+	Dealer                Dealer         `json:"dealer"`
+	Deck                  *Deck          `json:"deck,omitempty"` // Nil only for games saved without one; see ensureDeck
+	Status                GameStatus     `json:"status"`
+	CreatedAt             time.Time      `json:"createdAt"`
+	UpdatedAt             time.Time      `json:"updatedAt"`
+	MinBet                int            `json:"minBet"`
+	MaxBet                int            `json:"maxBet"`
+	TableID               string         `json:"tableId"`
+	CurrentPlayerIndex    int            `json:"currentPlayerIndex"`
+	MaxPlayers            int            `json:"maxPlayers"`
+	RequireReady          bool           `json:"requireReady"`             // Players must confirm readiness before dealing
+	ShowComposition       bool           `json:"showComposition"`          // Remaining deck composition may be shown to players
+	ValueOverrides        map[Rank]int   `json:"valueOverrides,omitempty"` // Variant card values replacing the standard ones
+	RevealHandsAtShowdown bool           `json:"revealHandsAtShowdown"`    // All hands are shown once the round completes
+	Ranked                bool           `json:"ranked"`                   // Results count towards rankings
+	Practice              bool           `json:"practice"`                 // Practice table: results aren't recorded and hits can be undone
+	FairnessMode          FairnessMode   `json:"fairnessMode"`
+	Seed                  int64          `json:"seed"` // Seed of the current shoe, disclosed according to FairnessMode
+	SeedHash              string         `json:"seedHash,omitempty"`
+	BonusEnabled          bool           `json:"bonusEnabled"`           // Players get a free bonus bet on their first round
+	BonusAmount           int            `json:"bonusAmount,omitempty"`  // Size of the bonus bet, defaults to MinBet
+	PreviousSeed          int64          `json:"previousSeed,omitempty"` // Seed of the last shoe, revealed in commit-reveal mode
+	PreviousSeedHash      string         `json:"previousSeedHash,omitempty"`
+	MaxRoundPayout        int            `json:"maxRoundPayout,omitempty"`      // Cap on what a round can pay out in total (0 = no cap)
+	AutoStartMinPlayers   int            `json:"autoStartMinPlayers,omitempty"` // Seated players needed for the table to open betting and deal by itself (0 = manual)
+	BettingWindowMs       int            `json:"bettingWindowMs,omitempty"`     // How long auto-managed betting stays open
+	StartWhenReady        bool           `json:"startWhenReady,omitempty"`      // Deal as soon as every seated player has bet
+	PhaseDeadline         time.Time      `json:"phaseDeadline,omitempty"`       // When the current auto-managed phase ends
+	TurnTimeout           time.Duration  `json:"turnTimeout,omitempty"`         // How long a player has to act before being stood automatically (0 = no limit)
+	TurnDeadline          time.Time      `json:"turnDeadline,omitempty"`        // When the active player's turn times out
+	BettingTimeout        time.Duration  `json:"bettingTimeout,omitempty"`      // How long betting stays open before the round is dealt to whoever bet (0 = no limit)
+	DealerPeek            bool           `json:"dealerPeek"`                    // Dealer checks for blackjack under a ten or ace before players act
+	FiveCardCharlie       bool           `json:"fiveCardCharlie"`               // Five cards without busting win at the charlie payout
+	SideBetPayouts        SideBetPayouts `json:"sideBetPayouts"`                // Side bet payouts of games saved before they moved into Payouts
+	Actions               []Action       `json:"actions,omitempty"`             // Every move made in the game, for replays. Never sent to clients.
+	Version               int            `json:"version"`                       // Bumped by the store on every save, to catch lost updates
+
+	RuleSet // House rules
+
+	events      []Event // Events waiting to be published, not persisted so a reloaded game doesn't publish them twice
+	replaySeeds []int64 // Recorded shuffle seeds still to use while replaying
+}
+
+// NewBlackjackGame creates a new blackjack game dealt from a shoe of numDecks decks
+func NewBlackjackGame(tableID string, minBet, maxBet, numDecks int) *BlackjackGame {
+	if numDecks < 1 {
+		numDecks = DefaultNumDecks
+	}
 
 	now := time.Now()
 
-	return &BlackjackGame{
+	g := &BlackjackGame{
 		ID:                 uuid.New().String(),
 		Players:            []Player{},
 		Dealer:             Dealer{Hand: []Card{}, Score: 0},
-		Deck:               deck,
 		Status:             Waiting,
 		CreatedAt:          now,
 		UpdatedAt:          now,
@@ -74,7 +182,76 @@ func NewBlackjackGame(tableID string, minBet, maxBet int) *BlackjackGame {
 		MaxBet:             maxBet,
 		TableID:            tableID,
 		CurrentPlayerIndex: 0,
+		MaxPlayers:         DefaultMaxPlayers,
+		FairnessMode:       FairnessOff,
+		RuleSet:            DefaultRuleSet,
+	}
+	g.NumDecks = numDecks
+
+	g.record(Action{Type: ActionCreate, Setup: &TableSetup{
+		GameID:   g.ID,
+		TableID:  tableID,
+		MinBet:   minBet,
+		MaxBet:   maxBet,
+		NumDecks: numDecks,
+	}})
+
+	// Create a new shoe and shuffle
+	g.newShoe()
+
+	return g
+}
+
+// IsFull reports whether every seat at the table is taken
+func (g *BlackjackGame) IsFull() bool {
+	return len(g.Players) >= g.seats()
+}
+
+// seats returns the number of seats at the table
+func (g *BlackjackGame) seats() int {
+	// Games saved before seat limits existed have no limit set
+	if g.MaxPlayers <= 0 {
+		return DefaultMaxPlayers
+	}
+	return g.MaxPlayers
+}
+
+// HasPlayer reports whether the player is seated in the game
+func (g *BlackjackGame) HasPlayer(playerID string) bool {
+	for _, p := range g.Players {
+		if p.ID == playerID {
+			return true
+		}
+	}
+	return false
+}
+
+// PlayerBalance returns the balance of a player at the table. A player
+// with several seats has the same balance on each.
+func (g *BlackjackGame) PlayerBalance(playerID string) (int, bool) {
+	for _, p := range g.Players {
+		if p.ID == playerID {
+			return p.Balance, true
+		}
 	}
+	return 0, false
+}
+
+// SetBalance replaces a player's balance on every seat they hold, such as
+// when their stored balance was topped up between rounds. It returns false
+// if the player isn't at the table or the balance is negative.
+func (g *BlackjackGame) SetBalance(playerID string, balance int) bool {
+	if balance < 0 || !g.HasPlayer(playerID) {
+		return false
+	}
+
+	for i := range g.Players {
+		if g.Players[i].ID == playerID {
+			g.Players[i].Balance = balance
+		}
+	}
+	g.record(Action{Type: ActionBalance, PlayerID: playerID, Amount: balance})
+	return true
 }
 
 // AddPlayer adds a player to the game
@@ -91,23 +268,28 @@ func (g *BlackjackGame) AddPlayer(playerID, playerName string, initialBalance in
 		}
 	}
 
-	fmt.Println("Adding player", playerName)
-	fmt.Println("Initial balance", initialBalance)
-	fmt.Println("Player ID", playerID)
-	fmt.Println("g.Status", g.Status)
-	fmt.Println("g.Players", g.Players)
-	// If the game is already in progress, don't add new players
-	if g.Status != Waiting {
+	logger.Debug("Adding player",
+		"game", g.ID, "player", playerID, "name", playerName,
+		"balance", initialBalance, "status", g.Status, "seated", len(g.Players))
+
+	// Don't seat more players than the table allows
+	if g.IsFull() {
 		return nil
 	}
 
+	// Players who join once the cards are out wait for the next round
+	status := PlayerActive
+	if g.Status == InProgress || g.Status == Completed {
+		status = PlayerWaiting
+	}
+
 	// Add new player
 	player := Player{
 		ID:       playerID,
 		Name:     playerName,
 		Hand:     []Card{},
 		Score:    0,
-		Status:   PlayerActive,
+		Status:   status,
 		Bet:      0,
 		Balance:  initialBalance,
 		IsActive: false,
@@ -115,89 +297,349 @@ func (g *BlackjackGame) AddPlayer(playerID, playerName string, initialBalance in
 
 	g.Players = append(g.Players, player)
 	g.UpdatedAt = time.Now()
+	g.record(Action{Type: ActionJoin, PlayerID: playerID, Name: playerName, Amount: initialBalance})
 
 	return &player
 }
 
-// RemovePlayer removes a player from the game
+// AddSeat gives a seated player another seat at the table, so they can
+// play several boxes with separate bets. Each seat takes its own turn, but
+// they all share the player's balance. A seat added once the cards are out
+// is dealt in from the next round. It returns false if the player isn't
+// seated or the table is full.
+func (g *BlackjackGame) AddSeat(playerID string) bool {
+	first := -1
+	seat := 0
+	for i, p := range g.Players {
+		if p.ID != playerID {
+			continue
+		}
+		if first < 0 {
+			first = i
+		}
+		seat = max(seat, p.Seat+1)
+	}
+	if first < 0 || g.IsFull() {
+		return false
+	}
+
+	status := PlayerActive
+	if g.Status == InProgress || g.Status == Completed {
+		status = PlayerWaiting
+	}
+
+	g.Players = append(g.Players, Player{
+		ID:      playerID,
+		Name:    g.Players[first].Name,
+		Hand:    []Card{},
+		Status:  status,
+		Balance: g.Players[first].Balance,
+		Seat:    seat,
+	})
+	g.UpdatedAt = time.Now()
+	g.record(Action{Type: ActionAddSeat, PlayerID: playerID})
+	return true
+}
+
+// seatIndex returns the index in Players of one of the player's seats, or
+// -1 if they don't hold it
+func (g *BlackjackGame) seatIndex(playerID string, seat int) int {
+	for i, p := range g.Players {
+		if p.ID == playerID && p.Seat == seat {
+			return i
+		}
+	}
+	return -1
+}
+
+// actingSeat returns the index in Players of the player's seat whose turn
+// it is, or of their first seat if it isn't their turn. It returns -1 if the
+// player isn't seated.
+func (g *BlackjackGame) actingSeat(playerID string) int {
+	first := -1
+	for i, p := range g.Players {
+		if p.ID != playerID {
+			continue
+		}
+		if i == g.CurrentPlayerIndex && p.IsActive {
+			return i
+		}
+		if first < 0 {
+			first = i
+		}
+	}
+	return first
+}
+
+// RemovePlayer removes a player and all of their seats from the game. The
+// game is over once the last player has left.
 func (g *BlackjackGame) RemovePlayer(playerID string) bool {
+	removed := false
+	for i := len(g.Players) - 1; i >= 0; i-- {
+		if g.Players[i].ID == playerID {
+			g.removePlayer(i)
+			removed = true
+		}
+	}
+	if removed {
+		g.record(Action{Type: ActionLeave, PlayerID: playerID})
+	}
+	return removed
+}
+
+// removePlayer removes the player in seat i, completing the game if nobody
+// is left
+func (g *BlackjackGame) removePlayer(i int) {
+	g.Players = append(g.Players[:i], g.Players[i+1:]...)
+	if len(g.Players) == 0 {
+		g.Status = Completed
+	}
+	g.UpdatedAt = time.Now()
+}
+
+// KickPlayer removes a player and all of their seats from the table,
+// returning their first seat as it was when removed. Bets placed before the
+// deal are refunded; once the cards are out the player's bets are forfeited.
+// If it was the player's turn, play moves on to the next player.
+func (g *BlackjackGame) KickPlayer(playerID string) (Player, bool) {
+	first := -1
 	for i, p := range g.Players {
-		if p.ID == playerID {
-			// Remove player from slice
-			g.Players = append(g.Players[:i], g.Players[i+1:]...)
-			g.UpdatedAt = time.Now()
-			return true
+		if p.ID != playerID {
+			continue
+		}
+		if first < 0 {
+			first = i
+		}
+
+		if g.Status == Betting {
+			g.adjustBalance(i, g.Players[i].Bet)
+			g.Players[i].Bet = 0
+			g.refundSideBets(i)
 		}
 	}
-	return false
+	if first < 0 {
+		return Player{}, false
+	}
+	kicked := g.Players[first]
+
+	current := g.CurrentPlayerIndex
+	wasCurrent := false
+	removedBefore := 0
+	for i := len(g.Players) - 1; i >= 0; i-- {
+		if g.Players[i].ID != playerID {
+			continue
+		}
+		if i == current {
+			wasCurrent = g.Status == InProgress
+		} else if i < current {
+			removedBefore++
+		}
+		g.removePlayer(i)
+	}
+	g.record(Action{Type: ActionKick, PlayerID: playerID})
+
+	// Keep the turn pointing at the same seat
+	g.CurrentPlayerIndex = current - removedBefore
+
+	// Pass the turn on so the table isn't left waiting on the kicked player
+	if wasCurrent && len(g.Players) > 0 {
+		g.CurrentPlayerIndex = (g.CurrentPlayerIndex - 1 + len(g.Players)) % len(g.Players)
+		g.NextPlayer()
+	}
+
+	return kicked, true
 }
 
-// PlaceBet allows a player to place a bet
-func (g *BlackjackGame) PlaceBet(playerID string, amount int) bool {
-	if g.Status != Betting {
+// OpenBetting moves a waiting game into the betting phase once at least one
+// player is seated
+func (g *BlackjackGame) OpenBetting() bool {
+	if g.Status != Waiting || len(g.Players) == 0 {
 		return false
 	}
 
+	g.Status = Betting
+	g.PhaseDeadline = g.bettingDeadline(time.Now())
+	g.UpdatedAt = time.Now()
+	g.record(Action{Type: ActionOpenBetting})
+	return true
+}
+
+// PlaceBet allows a player to place a bet on their first seat, returning why
+// it was refused if it was
+func (g *BlackjackGame) PlaceBet(playerID string, amount int) error {
+	return g.PlaceSeatBet(playerID, 0, amount)
+}
+
+// PlaceSeatBet places a bet on one of the player's seats, returning why it
+// was refused if it was
+func (g *BlackjackGame) PlaceSeatBet(playerID string, seat, amount int) error {
+	if g.Status != Betting {
+		return ErrNotBetting
+	}
+
 	// Validate bet amount
-	if amount < g.MinBet || amount > g.MaxBet {
+	if amount < g.MinBet {
+		return ErrBetBelowMin
+	}
+	if amount > g.MaxBet {
+		return ErrBetAboveMax
+	}
+
+	i := g.seatIndex(playerID, seat)
+	if i < 0 {
+		return ErrNotSeated
+	}
+
+	// Check if player has enough balance
+	if g.Players[i].Balance < amount {
+		return ErrInsufficientBalance
+	}
+
+	// Place the bet
+	g.Players[i].Bet = amount
+	g.adjustBalance(i, -amount)
+	g.UpdatedAt = time.Now()
+	g.record(Action{Type: ActionBet, PlayerID: playerID, Seat: seat, Amount: amount})
+	return nil
+}
+
+// UndoBet retracts a player's bets on every seat, refunding them to their
+// balance. Bets can only be undone during the betting phase, before the
+// cards are dealt.
+func (g *BlackjackGame) UndoBet(playerID string) bool {
+	if g.Status != Betting {
 		return false
 	}
 
+	undone := false
 	for i, p := range g.Players {
-		if p.ID == playerID {
-			// Check if player has enough balance
-			if p.Balance < amount {
-				return false
-			}
-
-			// Place the bet
-			g.Players[i].Bet = amount
-			g.Players[i].Balance -= amount
-			g.UpdatedAt = time.Now()
-			return true
+		if p.ID != playerID || p.Bet == 0 {
+			continue
 		}
+
+		g.adjustBalance(i, p.Bet)
+		g.Players[i].Bet = 0
+
+		// Side bets ride on the main bet, so they come back too
+		g.refundSideBets(i)
+		undone = true
 	}
-	return false
+	if !undone {
+		return false
+	}
+
+	g.UpdatedAt = time.Now()
+	g.record(Action{Type: ActionUndoBet, PlayerID: playerID})
+	return true
 }
 
-// Start begins the game after all players have placed their bets
-func (g *BlackjackGame) Start() bool {
-	if g.Status != Betting || len(g.Players) == 0 {
+// SetReady marks whether a player is ready to be dealt in, on every seat
+// they hold
+func (g *BlackjackGame) SetReady(playerID string, ready bool) bool {
+	if g.Status != Waiting && g.Status != Betting {
+		return false
+	}
+
+	found := false
+	for i, p := range g.Players {
+		if p.ID == playerID {
+			g.Players[i].Ready = ready
+			found = true
+		}
+	}
+	if !found {
 		return false
 	}
 
-	// Check if all players have placed bets
+	g.UpdatedAt = time.Now()
+	if ready {
+		g.record(Action{Type: ActionReady, PlayerID: playerID})
+	} else {
+		g.record(Action{Type: ActionUnready, PlayerID: playerID})
+	}
+	return true
+}
+
+// Start begins the game after all players have placed their bets. It
+// returns the reason the round can't be dealt, if it can't.
+func (g *BlackjackGame) Start() error {
+	if g.Status != Betting {
+		return ErrNotBetting
+	}
+
+	// Check if all players have placed bets (and are ready, if required)
+	dealt := 0
 	for _, p := range g.Players {
+		if p.SitsOutRound() {
+			continue
+		}
+		dealt++
+
 		if p.Bet == 0 {
-			return false
+			return ErrMissingBet
+		}
+		if g.RequireReady && !p.Ready {
+			return ErrNotReady
 		}
 	}
 
-	// Deal initial cards
+	if dealt == 0 {
+		return ErrNoPlayers
+	}
+
+	// Refuse rounds that could pay out more than the table allows
+	if g.ExceedsPayoutCap() {
+		return ErrPayoutCap
+	}
+
+	// Hand out any house-funded bonus bets
+	g.grantBonusBets()
+
+	// Make sure there is a deck to deal from
+	if err := g.ensureDeck(); err != nil {
+		log.Printf("Unable to start game %s: %v", g.ID, err)
+		return err
+	}
+
+	// Deal initial cards and settle side bets on them
 	g.DealInitialCards()
+	g.settleSideBets()
 
 	// Set game status to in progress
 	g.Status = InProgress
+	g.PhaseDeadline = time.Time{}
 	g.UpdatedAt = time.Now()
+	g.record(Action{Type: ActionDeal})
 
-	// Set current player
-	g.CurrentPlayerIndex = 0
-	g.Players[0].IsActive = true
+	// With dealer peek, a dealer blackjack ends the round before anyone acts
+	if g.DealerPeek && g.DealerPeeks() {
+		return nil
+	}
 
-	return true
+	// Hand the turn to the first player who can act. Starting the search
+	// from the last seat makes NextPlayer begin at seat 0, and if everyone
+	// was dealt blackjack the dealer plays straight away.
+	g.CurrentPlayerIndex = len(g.Players) - 1
+	g.NextPlayer()
+
+	return nil
 }
 
 // DealInitialCards deals the initial cards to all players and the dealer
 func (g *BlackjackGame) DealInitialCards() {
 	// Deal two cards to each player
 	for i := range g.Players {
+		if g.Players[i].SitsOutRound() {
+			continue
+		}
+
 		// First card face up
-		card1, _ := g.Deck.DrawCard()
+		card1, _ := g.drawCard()
 		card1.Face = true
 		g.Players[i].Hand = append(g.Players[i].Hand, card1)
 
 		// Second card face up
-		card2, _ := g.Deck.DrawCard()
+		card2, _ := g.drawCard()
 		card2.Face = true
 		g.Players[i].Hand = append(g.Players[i].Hand, card2)
 
@@ -211,16 +653,16 @@ func (g *BlackjackGame) DealInitialCards() {
 	}
 
 	// Deal two cards to dealer, first face up, second face down
-	dealerCard1, _ := g.Deck.DrawCard()
+	dealerCard1, _ := g.drawCard()
 	dealerCard1.Face = true
 	g.Dealer.Hand = append(g.Dealer.Hand, dealerCard1)
 
-	dealerCard2, _ := g.Deck.DrawCard()
+	dealerCard2, _ := g.drawCard()
 	dealerCard2.Face = false // Dealer's second card is face down
 	g.Dealer.Hand = append(g.Dealer.Hand, dealerCard2)
 
 	// Calculate dealer's visible score (only count face-up cards)
-	g.Dealer.Score = dealerCard1.GetValue()
+	g.Dealer.Score = g.Dealer.VisibleScore(g.ValueOverrides)
 }
 
 // Hit gives the current player another card
@@ -229,35 +671,123 @@ func (g *BlackjackGame) Hit(playerID string) (Card, bool) {
 		return Card{}, false
 	}
 
+	if err := g.ensureDeck(); err != nil {
+		log.Printf("Unable to hit in game %s: %v", g.ID, err)
+		return Card{}, false
+	}
+
 	// Find player
 	for i, p := range g.Players {
 		if p.ID == playerID && p.IsActive && p.Status == PlayerActive {
 			// Draw a card
-			card, success := g.Deck.DrawCard()
+			card, success := g.drawCard()
 			if !success {
 				return Card{}, false
 			}
 
 			card.Face = true
+
+			// Split players hit their active hand
+			if p.IsSplit() {
+				g.addCardToActiveHand(i, card)
+				g.restartTurnClock()
+				g.UpdatedAt = time.Now()
+				g.record(Action{Type: ActionHit, PlayerID: playerID, Card: &card})
+				return card, true
+			}
+
 			g.Players[i].Hand = append(g.Players[i].Hand, card)
 
 			// Recalculate score
 			g.Players[i].Score = g.CalculateHandScore(g.Players[i].Hand)
 
-			// Check if busted
+			// Check if busted, or holding a Five Card Charlie
 			if g.Players[i].Score > 21 {
 				g.Players[i].Status = PlayerBusted
 				g.Players[i].IsActive = false
 				g.NextPlayer()
+			} else if g.isCharlie(g.Players[i].Hand) {
+				g.Players[i].Status = PlayerCharlie
+				g.Players[i].IsActive = false
+				g.NextPlayer()
 			}
 
+			g.restartTurnClock()
 			g.UpdatedAt = time.Now()
+			g.record(Action{Type: ActionHit, PlayerID: playerID, Card: &card})
 			return card, true
 		}
 	}
 	return Card{}, false
 }
 
+// DoubleDown doubles the current player's bet, deals them exactly one more
+// card and ends their turn
+func (g *BlackjackGame) DoubleDown(playerID string) (Card, bool) {
+	if g.Status != InProgress {
+		return Card{}, false
+	}
+
+	if err := g.ensureDeck(); err != nil {
+		log.Printf("Unable to double down in game %s: %v", g.ID, err)
+		return Card{}, false
+	}
+
+	// Find the player's seat whose turn it is
+	for i, p := range g.Players {
+		if p.ID != playerID || i != g.CurrentPlayerIndex {
+			continue
+		}
+
+		// Only allowed on the player's turn, on their first two cards, and
+		// when they can cover the extra bet
+		if !p.IsActive || p.Status != PlayerActive {
+			return Card{}, false
+		}
+		if p.IsSplit() {
+			card, ok := g.doubleActiveHand(i)
+			if ok {
+				g.record(Action{Type: ActionDouble, PlayerID: playerID, Card: &card})
+			}
+			return card, ok
+		}
+		if len(p.Hand) != 2 || p.Balance < p.Bet {
+			return Card{}, false
+		}
+
+		// Draw a card
+		card, success := g.drawCard()
+		if !success {
+			return Card{}, false
+		}
+
+		// Double the bet
+		g.adjustBalance(i, -p.Bet)
+		g.Players[i].Bet *= 2
+
+		card.Face = true
+		g.Players[i].Hand = append(g.Players[i].Hand, card)
+
+		// Recalculate score
+		g.Players[i].Score = g.CalculateHandScore(g.Players[i].Hand)
+
+		// The player gets no further cards either way
+		if g.Players[i].Score > 21 {
+			g.Players[i].Status = PlayerBusted
+		} else {
+			g.Players[i].Status = PlayerStood
+		}
+		g.Players[i].IsActive = false
+		g.NextPlayer()
+
+		g.restartTurnClock()
+		g.UpdatedAt = time.Now()
+		g.record(Action{Type: ActionDouble, PlayerID: playerID, Card: &card})
+		return card, true
+	}
+	return Card{}, false
+}
+
 // Stand ends the current player's turn
 func (g *BlackjackGame) Stand(playerID string) bool {
 	if g.Status != InProgress {
@@ -267,11 +797,22 @@ func (g *BlackjackGame) Stand(playerID string) bool {
 	// Find player
 	for i, p := range g.Players {
 		if p.ID == playerID && p.IsActive && p.Status == PlayerActive {
+			// Split players stand on their active hand
+			if p.IsSplit() {
+				g.finishActiveHand(i, PlayerStood)
+				g.restartTurnClock()
+				g.UpdatedAt = time.Now()
+				g.record(Action{Type: ActionStand, PlayerID: playerID})
+				return true
+			}
+
 			g.Players[i].Status = PlayerStood
 			g.Players[i].IsActive = false
 
 			g.NextPlayer()
+			g.restartTurnClock()
 			g.UpdatedAt = time.Now()
+			g.record(Action{Type: ActionStand, PlayerID: playerID})
 			return true
 		}
 	}
@@ -288,6 +829,8 @@ func (g *BlackjackGame) NextPlayer() {
 		if g.Players[nextIndex].Status == PlayerActive {
 			g.CurrentPlayerIndex = nextIndex
 			g.Players[nextIndex].IsActive = true
+			g.restartTurnClock()
+			g.emit(Event{Type: EventTurnStarted, PlayerID: g.Players[nextIndex].ID, PlayerIndex: nextIndex, Deadline: g.TurnDeadline})
 			return
 		}
 
@@ -301,6 +844,24 @@ func (g *BlackjackGame) NextPlayer() {
 	}
 }
 
+// ForceDealerTurn plays the dealer's turn for an in-progress game where no
+// player is left to act. It recovers games where turn advancement broke.
+func (g *BlackjackGame) ForceDealerTurn() bool {
+	if g.Status != InProgress {
+		return false
+	}
+
+	for _, p := range g.Players {
+		if p.Status == PlayerActive {
+			return false
+		}
+	}
+
+	g.DealerTurn()
+	g.record(Action{Type: ActionForceDealer})
+	return true
+}
+
 // DealerTurn plays the dealer's turn after all players have played
 func (g *BlackjackGame) DealerTurn() {
 	// Flip the dealer's face-down card
@@ -311,9 +872,16 @@ func (g *BlackjackGame) DealerTurn() {
 	// Calculate dealer's score with all cards
 	g.Dealer.Score = g.CalculateHandScore(g.Dealer.Hand)
 
-	// Dealer must draw until score is at least 17
-	for g.Dealer.Score < 17 {
-		card, success := g.Deck.DrawCard()
+	// Without a deck the dealer can't draw, so the round settles on the
+	// dealer's current hand rather than panicking
+	if err := g.ensureDeck(); err != nil {
+		log.Printf("Dealer unable to draw in game %s: %v", g.ID, err)
+	}
+
+	// Dealer must draw until score is at least 17, and on a soft 17 too if
+	// the table says so
+	for g.Deck != nil && g.dealerMustHit() {
+		card, success := g.drawCard()
 		if !success {
 			break
 		}
@@ -328,93 +896,227 @@ func (g *BlackjackGame) DealerTurn() {
 
 	// Game is completed
 	g.Status = Completed
+	g.TurnDeadline = time.Time{}
 	g.UpdatedAt = time.Now()
 }
 
+// DealerPeeks has the dealer check their hole card when the up card is a ten
+// or an ace. If the dealer has blackjack, the hole card is turned over and
+// the round settles at once: player blackjacks push and every other hand
+// loses. It returns true if the round ended.
+func (g *BlackjackGame) DealerPeeks() bool {
+	if g.Status != InProgress || len(g.Dealer.Hand) != 2 {
+		return false
+	}
+
+	up := g.Dealer.Hand[0]
+	if up.Rank != Ace && CardValue(up, g.ValueOverrides) != 10 {
+		return false
+	}
+	if g.CalculateHandScore(g.Dealer.Hand) != 21 {
+		return false
+	}
+
+	// Reveal the blackjack
+	for i := range g.Dealer.Hand {
+		g.Dealer.Hand[i].Face = true
+	}
+	g.Dealer.Score = 21
+
+	// Nobody gets to act; hands that were still live stand as dealt
+	for i := range g.Players {
+		g.Players[i].IsActive = false
+		if g.Players[i].Status == PlayerActive {
+			g.Players[i].Status = PlayerStood
+		}
+	}
+
+	g.DetermineWinners()
+	g.Status = Completed
+	g.TurnDeadline = time.Time{}
+	g.UpdatedAt = time.Now()
+	g.emit(Event{Type: EventDealerBlackjack})
+	return true
+}
+
+// isCharlie reports whether a hand that hasn't busted wins as a Five Card
+// Charlie
+func (g *BlackjackGame) isCharlie(hand []Card) bool {
+	return g.FiveCardCharlie && len(hand) >= 5 && g.CalculateHandScore(hand) <= 21
+}
+
+// dealerMustHit reports whether the dealer's hand calls for another card
+func (g *BlackjackGame) dealerMustHit() bool {
+	if g.Dealer.Score < 17 {
+		return true
+	}
+	return g.RuleSet.DealerHitsSoft17 && g.Dealer.Score == 17 && g.isSoft(g.Dealer.Hand)
+}
+
 // DetermineWinners determines winners and updates player balances
 func (g *BlackjackGame) DetermineWinners() {
+	for _, result := range g.SettleResults() {
+		// Side bets were paid when the cards were dealt
+		if result.paidOnDeal {
+			continue
+		}
+		g.adjustBalance(result.playerIndex, result.Winnings)
+	}
+}
+
+// HandResult returns the outcome of a settled hand ("win", "blackjack",
+// "push", "surrender" or "lose") and the total returned to the player,
+// including the original bet
+func (g *BlackjackGame) HandResult(hand Hand) (string, int) {
 	dealerScore := g.Dealer.Score
-	dealerBusted := dealerScore > 21
+	payouts := g.EffectivePayouts()
 
-	for i, player := range g.Players {
-		playerScore := player.Score
+	switch hand.Status {
+	case PlayerBusted:
+		// Player busted, they lose
+		return "lose", 0
 
-		switch player.Status {
-		case PlayerBusted:
-			// Player busted, they lose
-			continue
+	case PlayerSurrendered:
+		// Part of the bet comes back, half unless the table says otherwise
+		return "surrender", payouts.SurrenderRefund(hand.Bet)
 
-		case PlayerBlackjack:
-			// Player has blackjack, pays 3:2 unless dealer also has blackjack
-			if len(g.Dealer.Hand) == 2 && dealerScore == 21 {
-				// Push - both have blackjack
-				g.Players[i].Balance += player.Bet
-			} else {
-				// Player wins with blackjack
-				g.Players[i].Balance += player.Bet + int(float64(player.Bet)*1.5)
-			}
+	case PlayerCharlie:
+		// A Five Card Charlie wins whatever the dealer has
+		return "win", payouts.CharlieWinnings(hand.Bet)
 
-		default:
-			// Normal win/loss/push
-			if dealerBusted {
-				// Dealer busted, player wins
-				g.Players[i].Balance += player.Bet * 2
-			} else if playerScore > dealerScore {
-				// Player score higher than dealer
-				g.Players[i].Balance += player.Bet * 2
-			} else if playerScore == dealerScore {
-				// Push
-				g.Players[i].Balance += player.Bet
-			}
-			// Otherwise dealer wins, player already lost their bet
+	case PlayerBlackjack:
+		// Player has blackjack, paid at the blackjack ratio unless dealer also has blackjack
+		if len(g.Dealer.Hand) == 2 && dealerScore == 21 {
+			return "push", hand.Bet
+		}
+		return "blackjack", payouts.BlackjackWinnings(hand.Bet)
+	}
+
+	// Normal win/loss/push
+	if dealerScore > 21 || hand.Score > dealerScore {
+		return "win", payouts.WinWinnings(hand.Bet)
+	}
+	if hand.Score == dealerScore {
+		return "push", hand.Bet
+	}
+	return "lose", 0
+}
+
+// adjustBalance applies a change to a player's balance. Callers must check
+// that a debit is covered before calling; if a bug still drives the balance
+// negative it is clamped to zero and logged rather than hidden.
+func (g *BlackjackGame) adjustBalance(i int, delta int) {
+	balance := g.Players[i].Balance + delta
+
+	if balance < 0 {
+		log.Printf("ERROR: player %s balance went negative (%d) in game %s, clamping to 0",
+			g.Players[i].ID, balance, g.ID)
+		balance = 0
+	}
+
+	// Every seat the player holds draws on the same balance
+	for j := range g.Players {
+		if g.Players[j].ID == g.Players[i].ID {
+			g.Players[j].Balance = balance
 		}
 	}
 }
 
 // CalculateHandScore calculates the score of a hand, accounting for aces
 func (g *BlackjackGame) CalculateHandScore(hand []Card) int {
+	score, _ := g.handScore(hand)
+	return score
+}
+
+// isSoft reports whether the hand's score counts an ace as 11
+func (g *BlackjackGame) isSoft(hand []Card) bool {
+	_, soft := g.handScore(hand)
+	return soft
+}
+
+// handScore returns the score of a hand and whether it is soft, i.e. still
+// counts an ace as 11
+func (g *BlackjackGame) handScore(hand []Card) (int, bool) {
+	return scoreHand(hand, g.ValueOverrides)
+}
+
+// scoreHand scores a hand with the given card value overrides, returning
+// whether the score is soft
+func scoreHand(hand []Card, overrides map[Rank]int) (int, bool) {
 	score := 0
 	aces := 0
 
 	// First pass: calculate score treating aces as 11
 	for _, card := range hand {
-		if card.Rank == Ace {
+		value := CardValue(card, overrides)
+		if card.Rank == Ace && value == 11 {
 			aces++
 		}
-		score += card.GetValue()
+		score += value
 	}
 
 	// Second pass: convert aces from 11 to 1 as needed to avoid busting
+	// (aces overridden to a fixed value are left alone)
 	for aces > 0 && score > 21 {
 		score -= 10 // Convert one ace from 11 to 1 (11 - 10 = 1)
 		aces--
 	}
 
-	return score
+	return score, aces > 0
 }
 
 // PrepareForNextRound resets the game for a new round while keeping player balances
 func (g *BlackjackGame) PrepareForNextRound() {
-	// Create a new deck and shuffle
-	g.Deck = NewDeck()
-	g.Deck.Shuffle()
+	// Keep dealing from the same shoe until the cut card is reached,
+	// discarding the finished round's cards
+	if g.Deck == nil || g.Deck.NeedsReshuffle() {
+		g.newShoe()
+	} else {
+		g.Deck.DiscardCards(g.cardsInPlay()...)
+	}
 
 	// Reset dealer
 	g.Dealer.Hand = []Card{}
 	g.Dealer.Score = 0
 
-	// Reset players but keep their balances
+	// Reset players but keep their balances. Players who joined mid-round
+	// are dealt in from now on.
 	for i := range g.Players {
 		g.Players[i].Hand = []Card{}
+		g.Players[i].Hands = nil
+		g.Players[i].ActiveHand = 0
 		g.Players[i].Score = 0
 		g.Players[i].Status = PlayerActive
 		g.Players[i].Bet = 0
+		g.Players[i].BonusBet = 0
+		g.Players[i].SideBets = nil
 		g.Players[i].IsActive = false
+		g.Players[i].Ready = false
 	}
 
 	// Set game status to betting
 	g.Status = Betting
+	g.TurnDeadline = time.Time{}
+	g.PhaseDeadline = g.bettingDeadline(time.Now())
 	g.UpdatedAt = time.Now()
+	g.record(Action{Type: ActionNextRound})
+}
+
+// cardsInPlay returns every card currently dealt to the dealer or a player
+func (g *BlackjackGame) cardsInPlay() []Card {
+	cards := append([]Card{}, g.Dealer.Hand...)
+	for _, p := range g.Players {
+		for _, hand := range p.PlayedHands() {
+			cards = append(cards, hand.Cards...)
+		}
+	}
+	return cards
+}
+
+// GetPublicState returns the game state as anyone not seated at the table
+// may see it: no hole cards, no one's cards before showdown and no balances
+func (g *BlackjackGame) GetPublicState() map[string]interface{} {
+	return g.GetGameState("")
 }
 
 // GetGameState returns the current game state
@@ -422,36 +1124,114 @@ func (g *BlackjackGame) GetGameState(playerID string) map[string]interface{} {
 	gameState := map[string]interface{}{
 		"id":      g.ID,
 		"status":  g.Status,
-		"dealer":  g.Dealer,
+		"dealer":  Dealer{Hand: g.Dealer.SanitizedHand(), Score: g.Dealer.VisibleScore(g.ValueOverrides)},
 		"tableId": g.TableID,
 		"minBet":  g.MinBet,
 		"maxBet":  g.MaxBet,
+
+		"maxPlayers":            g.seats(),
+		"requireReady":          g.RequireReady,
+		"revealHandsAtShowdown": g.RevealHandsAtShowdown,
+		"ranked":                g.Ranked,
+		"practice":              g.Practice,
+		"bonusEnabled":          g.BonusEnabled,
+		"maxRoundPayout":        g.MaxRoundPayout,
+		"rules":                 g.RuleSet,
+		"dealerHitsSoft17":      g.DealerHitsSoft17,
+		"dealerPeek":            g.DealerPeek,
+		"fiveCardCharlie":       g.FiveCardCharlie,
+		"fairness":              g.FairnessInfo(),
+		"remainingCards":        g.remainingCards(),
+		"policy":                g.policyState(),
+		"turnTimeoutMs":         g.TurnTimeout.Milliseconds(),
+	}
+
+	if !g.TurnDeadline.IsZero() {
+		gameState["turnDeadline"] = g.TurnDeadline.Format(time.RFC3339Nano)
+	}
+
+	// Practice tables help players learn to count cards
+	if g.Practice {
+		gameState["counting"] = g.countingState()
 	}
 
+	showdown := g.Status == Completed && g.RevealHandsAtShowdown
+
 	// Include sanitized player data for all players
 	sanitizedPlayers := make([]map[string]interface{}, len(g.Players))
 	for i, player := range g.Players {
 		sanitizedPlayer := map[string]interface{}{
 			"id":       player.ID,
 			"name":     player.Name,
-			"score":    player.Score,
 			"status":   player.Status,
 			"bet":      player.Bet,
+			"bonusBet": player.BonusBet,
+			"sideBets": player.SideBets,
 			"isActive": player.IsActive,
+			"ready":    player.Ready,
+			"seat":     player.Seat,
 		}
 
-		// Only include sensitive data for the current player
+		// Only include sensitive data for the current player. Other players'
+		// cards stay face down unless the table reveals hands at showdown.
 		if player.ID == playerID {
 			sanitizedPlayer["hand"] = player.Hand
+			sanitizedPlayer["score"] = player.Score
 			sanitizedPlayer["balance"] = player.Balance
-		} else {
+		} else if showdown {
 			sanitizedPlayer["hand"] = player.Hand
+			sanitizedPlayer["score"] = player.Score
+		} else {
+			sanitizedPlayer["hand"] = hiddenHand(player.Hand)
+		}
+
+		// Split players also get every hand they hold
+		if player.IsSplit() {
+			sanitizedPlayer["activeHand"] = player.ActiveHand
+			if player.ID == playerID || showdown {
+				sanitizedPlayer["hands"] = player.Hands
+			} else {
+				hands := make([]Hand, len(player.Hands))
+				for h, hand := range player.Hands {
+					hands[h] = Hand{Cards: hiddenHand(hand.Cards), Status: hand.Status, Bet: hand.Bet}
+				}
+				sanitizedPlayer["hands"] = hands
+			}
 		}
 
 		sanitizedPlayers[i] = sanitizedPlayer
 	}
 
 	gameState["players"] = sanitizedPlayers
+	gameState["playerSeats"] = g.playerSeats()
 
 	return gameState
 }
+
+// remainingCards returns how many cards are left in the shoe
+func (g *BlackjackGame) remainingCards() int {
+	if g.Deck == nil {
+		return 0
+	}
+	return g.Deck.RemainingCards()
+}
+
+// playerSeats groups the seats by player, mapping each player's ID to the
+// indexes of their seats in Players
+func (g *BlackjackGame) playerSeats() map[string][]int {
+	seats := make(map[string][]int)
+	for i, p := range g.Players {
+		seats[p.ID] = append(seats[p.ID], i)
+	}
+	return seats
+}
+
+// hiddenHand returns face-down placeholders for a hand, revealing only how
+// many cards it holds
+func hiddenHand(hand []Card) []Card {
+	hidden := make([]Card, len(hand))
+	for i := range hidden {
+		hidden[i] = Card{Face: false}
+	}
+	return hidden
+}
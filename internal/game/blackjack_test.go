@@ -0,0 +1,809 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// cards parses card shorthands such as "AS" or "10H" into face-up cards
+func cards(t *testing.T, codes ...string) []Card {
+	t.Helper()
+
+	hand := make([]Card, len(codes))
+	for i, code := range codes {
+		card, err := ParseCard(code)
+		if err != nil {
+			t.Fatalf("ParseCard(%q): %v", code, err)
+		}
+		hand[i] = card
+	}
+	return hand
+}
+
+// stackDeck puts the cards on top of the game's shoe, to be drawn in order
+func stackDeck(t *testing.T, g *BlackjackGame, codes ...string) {
+	t.Helper()
+	g.Deck.Cards = append(cards(t, codes...), g.Deck.Cards...)
+}
+
+// newTestGame seats the players at a fresh table, each with a balance of 1000
+func newTestGame(playerIDs ...string) *BlackjackGame {
+	g := NewBlackjackGame("table-1", 10, 500, 1)
+	for _, id := range playerIDs {
+		g.AddPlayer(id, "Player "+id, 1000)
+	}
+	return g
+}
+
+// dealRound has every player bet 10 and deals them the stacked cards: two
+// for each player in seat order, then the dealer's up card and hole card,
+// then whatever the round draws next
+func dealRound(t *testing.T, g *BlackjackGame, codes ...string) {
+	t.Helper()
+
+	if g.Status == Waiting && !g.OpenBetting() {
+		t.Fatal("OpenBetting failed")
+	}
+	for _, p := range g.Players {
+		if err := g.PlaceSeatBet(p.ID, p.Seat, 10); err != nil {
+			t.Fatalf("PlaceSeatBet(%s): %v", p.ID, err)
+		}
+	}
+	stackDeck(t, g, codes...)
+	if err := g.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+}
+
+func TestCalculateHandScoreWithOverrides(t *testing.T) {
+	tests := []struct {
+		name      string
+		overrides map[Rank]int
+		hand      []string
+		want      int
+	}{
+		{"standard", nil, []string{"KH", "7S"}, 17},
+		{"soft ace", nil, []string{"AH", "6S"}, 17},
+		{"ace falls to one", nil, []string{"AH", "6S", "9D"}, 16},
+		{"kings count 5", map[Rank]int{King: 5}, []string{"KH", "7S"}, 12},
+		{"other ranks unchanged", map[Rank]int{King: 5}, []string{"QH", "7S"}, 17},
+		{"ace fixed at 1", map[Rank]int{Ace: 1}, []string{"AH", "KS"}, 11},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame()
+			g.ValueOverrides = tt.overrides
+			if got := g.CalculateHandScore(cards(t, tt.hand...)); got != tt.want {
+				t.Errorf("score = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValueOverridesApplyToDealing(t *testing.T) {
+	g := newTestGame("p1")
+	g.ValueOverrides = map[Rank]int{Seven: 1}
+	dealRound(t, g, "7H", "7S", "7D", "KC")
+
+	if g.Players[0].Score != 2 {
+		t.Errorf("player score = %d, want 2", g.Players[0].Score)
+	}
+	if g.Dealer.Score != 1 {
+		t.Errorf("dealer's visible score = %d, want 1", g.Dealer.Score)
+	}
+}
+
+func TestOpponentHandsRevealedOnlyAtShowdown(t *testing.T) {
+	tests := []struct {
+		name      string
+		reveal    bool
+		completed bool
+		want      bool
+	}{
+		{"in progress", true, false, false},
+		{"showdown", true, true, true},
+		{"showdown without reveal", false, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame("p1", "p2")
+			g.RevealHandsAtShowdown = tt.reveal
+			dealRound(t, g, "10H", "7S", "9D", "8C", "10S", "7D")
+			if tt.completed {
+				g.Stand("p1")
+				g.Stand("p2")
+			}
+
+			players := g.GetGameState("p1")["players"].([]map[string]interface{})
+			if hand := players[0]["hand"].([]Card); !hand[0].Face {
+				t.Error("p1 can't see their own hand")
+			}
+
+			opponent := players[1]
+			hand := opponent["hand"].([]Card)
+			_, hasScore := opponent["score"]
+			if shown := hand[0].Face && hand[0].Rank == Nine; shown != tt.want || hasScore != tt.want {
+				t.Errorf("opponent hand shown = %v, score shown = %v, want %v", shown, hasScore, tt.want)
+			}
+			if _, ok := opponent["balance"]; ok {
+				t.Error("opponent's balance was shown")
+			}
+		})
+	}
+}
+
+func TestForceDealerTurn(t *testing.T) {
+	g := newTestGame("p1")
+	dealRound(t, g, "10H", "8S", "10D", "7C")
+	if g.ForceDealerTurn() {
+		t.Fatal("forced the dealer while p1 could still act")
+	}
+
+	// Wedge the game as if turn advancement broke after p1 stood
+	g.Players[0].Status = PlayerStood
+	g.Players[0].IsActive = false
+
+	if !g.ForceDealerTurn() {
+		t.Fatal("ForceDealerTurn refused a wedged game")
+	}
+	if g.Status != Completed {
+		t.Errorf("status = %s, want %s", g.Status, Completed)
+	}
+	if g.Players[0].Balance != 1010 {
+		t.Errorf("balance = %d, want 1010 for 18 beating 17", g.Players[0].Balance)
+	}
+	if g.ForceDealerTurn() {
+		t.Error("forced the dealer on a completed game")
+	}
+}
+
+// withoutDeck round-trips the game through JSON without its deck, as if it
+// had been saved before decks were persisted
+func withoutDeck(t *testing.T, g *BlackjackGame) *BlackjackGame {
+	t.Helper()
+
+	g.Deck = nil
+	data, err := json.Marshal(g)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var loaded BlackjackGame
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if loaded.Deck != nil {
+		t.Fatal("loaded game has a deck")
+	}
+	return &loaded
+}
+
+func TestDecklessGameBetweenRounds(t *testing.T) {
+	g := newTestGame("p1")
+	g.OpenBetting()
+	g.PlaceBet("p1", 10)
+
+	loaded := withoutDeck(t, g)
+	if err := loaded.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if loaded.Deck == nil || len(loaded.Players[0].Hand) != 2 {
+		t.Error("the round wasn't dealt from a fresh shoe")
+	}
+}
+
+func TestDecklessGameInProgress(t *testing.T) {
+	g := newTestGame("p1")
+	dealRound(t, g, "10H", "6S", "10D", "7C")
+
+	loaded := withoutDeck(t, g)
+	if _, ok := loaded.Hit("p1"); ok {
+		t.Error("hit without a deck")
+	}
+	if _, ok := loaded.DoubleDown("p1"); ok {
+		t.Error("doubled without a deck")
+	}
+	if len(loaded.Players[0].Hand) != 2 {
+		t.Errorf("hand changed to %v", loaded.Players[0].Hand)
+	}
+
+	// Standing still settles the round on the dealer's hand
+	if !loaded.Stand("p1") {
+		t.Fatal("Stand failed")
+	}
+	if loaded.Status != Completed || loaded.Players[0].Balance != 990 {
+		t.Errorf("status %s with balance %d, want completed with 990", loaded.Status, loaded.Players[0].Balance)
+	}
+}
+
+func TestDoubleDown(t *testing.T) {
+	tests := []struct {
+		name        string
+		deal        []string
+		wantStatus  PlayerStatus
+		wantBalance int
+	}{
+		{"double and win", []string{"6H", "5S", "10D", "7C", "10H"}, PlayerStood, 1020},
+		{"double and push", []string{"6H", "5S", "10D", "7C", "6C"}, PlayerStood, 1000},
+		{"double and lose", []string{"6H", "5S", "10D", "7C", "2C"}, PlayerStood, 980},
+		{"double and bust", []string{"10H", "5S", "10D", "7C", "QC"}, PlayerBusted, 980},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame("p1")
+			dealRound(t, g, tt.deal...)
+
+			card, ok := g.DoubleDown("p1")
+			if !ok {
+				t.Fatal("DoubleDown failed")
+			}
+			p := g.Players[0]
+			if card != p.Hand[2] || len(p.Hand) != 3 {
+				t.Errorf("drew %v into hand %v, want exactly one card", card, p.Hand)
+			}
+			if p.Bet != 20 || p.Status != tt.wantStatus {
+				t.Errorf("bet %d with status %s, want 20 and %s", p.Bet, p.Status, tt.wantStatus)
+			}
+			if g.Status != Completed || p.Balance != tt.wantBalance {
+				t.Errorf("status %s with balance %d, want completed with %d", g.Status, p.Balance, tt.wantBalance)
+			}
+		})
+	}
+}
+
+func TestDoubleDownRefused(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(g *BlackjackGame)
+	}{
+		{"after hitting", func(g *BlackjackGame) { g.Hit("p1") }},
+		{"can't cover the bet", func(g *BlackjackGame) { g.Players[0].Balance = 5 }},
+		{"not their turn", func(g *BlackjackGame) { g.Stand("p1") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame("p1", "p2")
+			dealRound(t, g, "2H", "3S", "10H", "6S", "10D", "7C", "2C")
+			tt.setup(g)
+
+			bet, balance := g.Players[0].Bet, g.Players[0].Balance
+			if _, ok := g.DoubleDown("p1"); ok {
+				t.Fatal("DoubleDown succeeded")
+			}
+			if g.Players[0].Bet != bet || g.Players[0].Balance != balance {
+				t.Error("a refused double changed the bet")
+			}
+		})
+	}
+}
+
+func TestUndoBet(t *testing.T) {
+	g := newTestGame("p1", "p2")
+	g.OpenBetting()
+	if err := g.PlaceBet("p1", 50); err != nil {
+		t.Fatalf("PlaceBet: %v", err)
+	}
+	if err := g.PlaceSideBet("p1", SideBetPerfectPairs, 5); err != nil {
+		t.Fatalf("PlaceSideBet: %v", err)
+	}
+
+	if g.UndoBet("p2") {
+		t.Error("undid a bet p2 never placed")
+	}
+	if !g.UndoBet("p1") {
+		t.Fatal("UndoBet failed before the deal")
+	}
+	p := g.Players[0]
+	if p.Bet != 0 || p.Balance != 1000 || len(p.SideBets) != 0 {
+		t.Errorf("bet %d, balance %d, %d side bets after undoing, want everything refunded", p.Bet, p.Balance, len(p.SideBets))
+	}
+
+	// Once the cards are out the bet stands
+	g.PlaceBet("p1", 20)
+	g.PlaceBet("p2", 20)
+	stackDeck(t, g, "10H", "7S", "10D", "8C", "10S", "7D")
+	if err := g.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if g.UndoBet("p1") {
+		t.Error("undid a bet after the deal")
+	}
+	if p := g.Players[0]; p.Bet != 20 || p.Balance != 980 {
+		t.Errorf("bet %d with balance %d after the deal, want 20 and 980", p.Bet, p.Balance)
+	}
+}
+
+func TestIsSoft(t *testing.T) {
+	tests := []struct {
+		hand []string
+		want bool
+	}{
+		{[]string{"AH", "6S"}, true},
+		{[]string{"10H", "7S"}, false},
+		{[]string{"AH", "6S", "10D"}, false},
+		{[]string{"AH", "AS", "5D"}, true},
+	}
+
+	g := newTestGame()
+	for _, tt := range tests {
+		if got := g.isSoft(cards(t, tt.hand...)); got != tt.want {
+			t.Errorf("isSoft(%v) = %v, want %v", tt.hand, got, tt.want)
+		}
+	}
+}
+
+func TestDealerSoft17(t *testing.T) {
+	tests := []struct {
+		name      string
+		hitsSoft  bool
+		dealer    []string
+		wantHand  int
+		wantScore int
+	}{
+		{"soft 17 hits", true, []string{"6C", "AD"}, 3, 21},
+		{"soft 17 stands by default", false, []string{"6C", "AD"}, 2, 17},
+		{"hard 17 stands", true, []string{"10C", "7D"}, 2, 17},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame("p1")
+			g.DealerHitsSoft17 = tt.hitsSoft
+			dealRound(t, g, append([]string{"10H", "8S"}, append(tt.dealer, "4S")...)...)
+			g.Stand("p1")
+
+			if len(g.Dealer.Hand) != tt.wantHand || g.Dealer.Score != tt.wantScore {
+				t.Errorf("dealer finished with %d cards on %d, want %d on %d", len(g.Dealer.Hand), g.Dealer.Score, tt.wantHand, tt.wantScore)
+			}
+		})
+	}
+}
+
+func TestDealerPeek(t *testing.T) {
+	tests := []struct {
+		name         string
+		peek         bool
+		dealer       []string
+		wantStatus   GameStatus
+		wantBalances []int
+	}{
+		// p1 holds 19 and p2 a natural
+		{"blackjack under an ace", true, []string{"AD", "KD"}, Completed, []int{990, 1000}},
+		{"blackjack under a ten", true, []string{"QD", "AC"}, Completed, []int{990, 1000}},
+		{"no blackjack", true, []string{"AD", "7D"}, InProgress, []int{990, 990}},
+		{"peeking off", false, []string{"AD", "KD"}, InProgress, []int{990, 990}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame("p1", "p2")
+			g.DealerPeek = tt.peek
+			dealRound(t, g, append([]string{"10H", "9H", "AS", "KS"}, tt.dealer...)...)
+
+			if g.Status != tt.wantStatus {
+				t.Fatalf("status = %s, want %s", g.Status, tt.wantStatus)
+			}
+			for i, want := range tt.wantBalances {
+				if got := g.Players[i].Balance; got != want {
+					t.Errorf("%s's balance = %d, want %d", g.Players[i].ID, got, want)
+				}
+			}
+
+			peeked := false
+			for _, event := range g.DrainEvents() {
+				peeked = peeked || event.Type == EventDealerBlackjack
+			}
+			if peeked != (tt.wantStatus == Completed) {
+				t.Errorf("dealerBlackjack event sent = %v", peeked)
+			}
+			if !peeked && g.Dealer.Hand[1].Face {
+				t.Error("the hole card was turned over without a blackjack")
+			}
+		})
+	}
+}
+
+func TestHoleCardNeverSerialized(t *testing.T) {
+	g := newTestGame("p1")
+
+	// The queen of clubs is the only queen or club dealt
+	dealRound(t, g, "9H", "7D", "10S", "QC")
+	leaks := func(viewer string) bool {
+		data, err := json.Marshal(g.GetGameState(viewer))
+		if err != nil {
+			t.Fatalf("marshal state: %v", err)
+		}
+		return strings.Contains(string(data), string(Queen)) || strings.Contains(string(data), string(Clubs))
+	}
+
+	for _, viewer := range []string{"p1", ""} {
+		if leaks(viewer) {
+			t.Errorf("state for %q shows the hole card", viewer)
+		}
+	}
+	dealer := g.GetGameState("p1")["dealer"].(Dealer)
+	if dealer.Hand[1] != (Card{}) || dealer.Score != 10 {
+		t.Errorf("dealer shows %v scoring %d, want a blank hole card and the up card's 10", dealer.Hand, dealer.Score)
+	}
+
+	// Once the dealer plays it is turned over for everyone
+	g.Stand("p1")
+	if !leaks("p1") {
+		t.Error("the hole card wasn't revealed after the dealer's turn")
+	}
+}
+
+func TestAddPlayerAtCapacity(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxPlayers int
+		wantSeats  int
+	}{
+		{"configured", 2, 2},
+		{"default", DefaultMaxPlayers, DefaultMaxPlayers},
+		{"saved before limits", 0, DefaultMaxPlayers},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewBlackjackGame("table-1", 10, 500, 1)
+			g.MaxPlayers = tt.maxPlayers
+			for i := 0; i < tt.wantSeats; i++ {
+				if g.AddPlayer(fmt.Sprintf("p%d", i), "Player", 1000) == nil {
+					t.Fatalf("seat %d refused", i+1)
+				}
+			}
+
+			if !g.IsFull() {
+				t.Error("IsFull = false with every seat taken")
+			}
+			if p := g.AddPlayer("late", "Late", 1000); p != nil {
+				t.Errorf("a player took seat %d", tt.wantSeats+1)
+			}
+
+			// Players already seated can still rejoin
+			if p := g.AddPlayer("p0", "Player", 1000); p == nil || p.ID != "p0" {
+				t.Errorf("rejoining = %v, want p0's seat back", p)
+			}
+			if len(g.Players) != tt.wantSeats {
+				t.Errorf("%d players seated, want %d", len(g.Players), tt.wantSeats)
+			}
+		})
+	}
+}
+
+func TestJoinMidRound(t *testing.T) {
+	g := newTestGame("p1")
+	dealRound(t, g, "10H", "7D", "9S", "8C")
+
+	late := g.AddPlayer("p2", "Player p2", 1000)
+	if late == nil || late.Status != PlayerWaiting {
+		t.Fatalf("joined mid-round as %v, want %s", late, PlayerWaiting)
+	}
+	if len(late.Hand) != 0 || g.Players[g.CurrentPlayerIndex].ID != "p1" {
+		t.Error("the late player was dealt into the round in play")
+	}
+	if err := g.PlaceBet("p2", 10); err == nil {
+		t.Error("the late player bet on the round in play")
+	}
+
+	// p1's stand finishes the round without waiting on p2
+	g.Stand("p1")
+	if g.Status != Completed {
+		t.Fatalf("status = %s, want the round completed", g.Status)
+	}
+	if p2 := g.Players[1]; p2.Status != PlayerWaiting || p2.Balance != 1000 {
+		t.Errorf("p2 is %s with %d, want waiting with 1000 untouched", p2.Status, p2.Balance)
+	}
+
+	// The next round deals p2 in
+	g.PrepareForNextRound()
+	if g.Players[1].Status != PlayerActive {
+		t.Fatalf("p2 is %s after the round, want %s", g.Players[1].Status, PlayerActive)
+	}
+	dealRound(t, g, "10H", "7D", "9S", "9C", "10C", "7S")
+	if hand := g.Players[1].Hand; len(hand) != 2 || hand[0].String() != "9S" {
+		t.Errorf("p2 was dealt %v, want 9S 9C", hand)
+	}
+	g.Stand("p1")
+	g.Stand("p2")
+	if g.Status != Completed || g.Players[1].Balance != 1010 {
+		t.Errorf("status %s with p2 on %d, want p2's 18 to beat the dealer's 17", g.Status, g.Players[1].Balance)
+	}
+}
+
+func TestKickPlayer(t *testing.T) {
+	tests := []struct {
+		name        string
+		kick        string
+		wantCurrent string
+	}{
+		{"active player", "p1", "p2"},
+		{"waiting player", "p2", "p1"},
+		{"last player to act", "p3", "p1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame("p1", "p2", "p3")
+			dealRound(t, g, "10H", "6D", "9S", "7C", "10C", "5S", "10D", "7H", "2D")
+
+			kicked, ok := g.KickPlayer(tt.kick)
+			if !ok || kicked.ID != tt.kick || kicked.Balance != 990 {
+				t.Fatalf("KickPlayer = %v, %v, want %s with the bet forfeited", kicked, ok, tt.kick)
+			}
+			if g.HasPlayer(tt.kick) || len(g.Players) != 2 {
+				t.Errorf("players = %d, want %s gone", len(g.Players), tt.kick)
+			}
+			if current := g.Players[g.CurrentPlayerIndex].ID; g.Status != InProgress || current != tt.wantCurrent {
+				t.Fatalf("status %s on %s's turn, want %s's turn", g.Status, current, tt.wantCurrent)
+			}
+
+			// Play carries on to the end of the round
+			for g.Status == InProgress {
+				g.Stand(g.Players[g.CurrentPlayerIndex].ID)
+			}
+			if g.Status != Completed {
+				t.Errorf("status = %s, want the round completed", g.Status)
+			}
+		})
+	}
+}
+
+func TestKickPlayerRefundsBetBeforeDeal(t *testing.T) {
+	g := newTestGame("p1", "p2")
+	g.OpenBetting()
+	g.PlaceBet("p1", 50)
+
+	kicked, ok := g.KickPlayer("p1")
+	if !ok || kicked.Bet != 0 || kicked.Balance != 1000 {
+		t.Errorf("KickPlayer = %+v, %v, want the bet of 50 refunded", kicked, ok)
+	}
+	if _, ok := g.KickPlayer("stranger"); ok {
+		t.Error("kicked a player who wasn't seated")
+	}
+}
+
+func TestKickLastPlayerFinishesRound(t *testing.T) {
+	g := newTestGame("p1")
+	dealRound(t, g, "10H", "6D", "10D", "7H")
+
+	g.KickPlayer("p1")
+	if g.Status != Completed {
+		t.Errorf("status = %s, want the round completed with nobody left", g.Status)
+	}
+}
+
+func TestMidRoundGameSurvivesRestart(t *testing.T) {
+	g := newTestGame("p1", "p2")
+	dealRound(t, g, "10H", "2D", "9S", "7C", "10C", "6S", "5H", "4D", "8C")
+	g.Hit("p1")
+	g.DrainEvents()
+
+	data, err := json.Marshal(g)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var loaded BlackjackGame
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	if loaded.Deck == nil || len(loaded.Deck.Cards) != len(g.Deck.Cards) || loaded.Deck.Cards[0] != g.Deck.Cards[0] {
+		t.Fatal("the shoe wasn't restored card for card")
+	}
+	if len(loaded.Actions) != len(g.Actions) || loaded.Actions[len(loaded.Actions)-1].Type != ActionHit {
+		t.Errorf("restored %d actions, want the %d logged ending with the hit", len(loaded.Actions), len(g.Actions))
+	}
+	if loaded.Dealer.Hand[1].Face {
+		t.Error("the dealer's hole card came back face up")
+	}
+
+	// Both copies play the rest of the round the same way
+	for _, game := range []*BlackjackGame{g, &loaded} {
+		game.Stand("p1")
+		if card, ok := game.Hit("p2"); !ok || card.String() != "4D" {
+			t.Fatalf("p2 drew %v, %v, want 4D", card, ok)
+		}
+		game.Stand("p2")
+	}
+	if got, want := finalState(t, &loaded), finalState(t, g); got != want {
+		t.Errorf("restored game finished differently\n got: %s\nwant: %s", got, want)
+	}
+	if loaded.Status != Completed || loaded.Players[0].Balance != 1010 || loaded.Players[1].Balance != 1010 {
+		t.Errorf("status %s with balances %d and %d, want both paid when the dealer busts", loaded.Status, loaded.Players[0].Balance, loaded.Players[1].Balance)
+	}
+}
+
+func TestDealerVisibleScore(t *testing.T) {
+	tests := []struct {
+		name string
+		hand []string
+		hole bool // Whether the second card is still face down
+		want int
+	}{
+		{"ten up, hole card hidden", []string{"10S", "9C"}, true, 10},
+		{"ace up, hole card hidden", []string{"AS", "KC"}, true, 11},
+		{"hole card revealed", []string{"10S", "9C"}, false, 19},
+		{"soft hand revealed", []string{"AS", "6C"}, false, 17},
+		{"after drawing", []string{"10S", "2C", "5D"}, false, 17},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := Dealer{Hand: cards(t, tt.hand...)}
+			d.Hand[1].Face = !tt.hole
+			if got := d.VisibleScore(nil); got != tt.want {
+				t.Errorf("VisibleScore = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDealerScoreHiddenUntilReveal(t *testing.T) {
+	g := newTestGame("p1")
+	dealRound(t, g, "10H", "7D", "6S", "KC", "5H")
+
+	// Nobody is told the dealer has 16 while the hole card is down
+	dealer := g.GetGameState("p1")["dealer"].(Dealer)
+	if dealer.Score != 6 {
+		t.Errorf("dealer score while the hole card is down = %d, want 6", dealer.Score)
+	}
+
+	g.Stand("p1")
+	dealer = g.GetGameState("p1")["dealer"].(Dealer)
+	if dealer.Score != 21 || len(dealer.Hand) != 3 {
+		t.Errorf("dealer score after playing = %d with %d cards, want 21 with 3", dealer.Score, len(dealer.Hand))
+	}
+}
+
+func TestStartErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(g *BlackjackGame)
+		want  error
+	}{
+		{"not betting", func(g *BlackjackGame) { g.AddPlayer("p1", "Ann", 1000) }, ErrNotBetting},
+		{"no players", func(g *BlackjackGame) { g.Status = Betting }, ErrNoPlayers},
+		{"missing bet", func(g *BlackjackGame) {
+			g.AddPlayer("p1", "Ann", 1000)
+			g.OpenBetting()
+		}, ErrMissingBet},
+		{"not ready", func(g *BlackjackGame) {
+			g.RequireReady = true
+			g.AddPlayer("p1", "Ann", 1000)
+			g.OpenBetting()
+			g.PlaceBet("p1", 10)
+		}, ErrNotReady},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewBlackjackGame("table-1", 10, 500, 1)
+			tt.setup(g)
+			if err := g.Start(); !errors.Is(err, tt.want) {
+				t.Errorf("Start = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlaceSeatBetRefused(t *testing.T) {
+	tests := []struct {
+		name    string
+		open    bool
+		seat    int
+		balance int
+		amount  int
+		want    error
+	}{
+		{"betting not open", false, 0, 1000, 50, ErrNotBetting},
+		{"below the minimum", true, 0, 1000, 5, ErrBetBelowMin},
+		{"above the maximum", true, 0, 1000, 600, ErrBetAboveMax},
+		{"insufficient balance", true, 0, 40, 50, ErrInsufficientBalance},
+		{"seat not held", true, 1, 1000, 50, ErrNotSeated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame("p1")
+			g.Players[0].Balance = tt.balance
+			if tt.open {
+				g.OpenBetting()
+			}
+
+			if err := g.PlaceSeatBet("p1", tt.seat, tt.amount); !errors.Is(err, tt.want) {
+				t.Fatalf("PlaceSeatBet = %v, want %v", err, tt.want)
+			}
+			// A refused bet is turned away before anything is taken
+			if p := g.Players[0]; p.Balance != tt.balance || p.Bet != 0 {
+				t.Errorf("balance %d with a bet of %d, want %d untouched", p.Balance, p.Bet, tt.balance)
+			}
+		})
+	}
+}
+
+func TestPlayerWithTwoSeats(t *testing.T) {
+	g := newTestGame("p1", "p2")
+	if !g.AddSeat("p1") {
+		t.Fatal("AddSeat refused p1's second seat")
+	}
+	if g.AddSeat("stranger") {
+		t.Error("AddSeat gave a seat to someone not at the table")
+	}
+
+	// p1's seats bet independently from the one balance
+	g.OpenBetting()
+	bets := []struct {
+		playerID string
+		seat     int
+		amount   int
+	}{
+		{"p1", 0, 20},
+		{"p2", 0, 10},
+		{"p1", 1, 50},
+	}
+	for _, b := range bets {
+		if err := g.PlaceSeatBet(b.playerID, b.seat, b.amount); err != nil {
+			t.Fatalf("PlaceSeatBet(%s, %d): %v", b.playerID, b.seat, err)
+		}
+	}
+	if g.Players[0].Balance != 930 || g.Players[2].Balance != 930 {
+		t.Errorf("p1's seats hold balances %d and %d, want both at 930", g.Players[0].Balance, g.Players[2].Balance)
+	}
+
+	// p1 stands on 20 in the first seat and busts 16 in the second; the
+	// dealer stands on 18
+	stackDeck(t, g, "10H", "10S", "10D", "7C", "10C", "6D", "9H", "9S", "KD")
+	if err := g.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if got := g.GetGameState("p1")["playerSeats"]; !reflect.DeepEqual(got, map[string][]int{"p1": {0, 2}, "p2": {1}}) {
+		t.Errorf("playerSeats = %v, want p1 in seats 0 and 2", got)
+	}
+
+	g.Stand("p1")
+	g.Stand("p2")
+	if g.CurrentPlayerIndex != 2 {
+		t.Fatalf("turn at %d after the first two seats stood, want p1's second seat", g.CurrentPlayerIndex)
+	}
+	if _, ok := g.Hit("p1"); !ok {
+		t.Fatal("p1 couldn't hit their second seat")
+	}
+	if g.Status != Completed {
+		t.Fatalf("status = %s, want the round over", g.Status)
+	}
+
+	seats := []struct {
+		index  int
+		status PlayerStatus
+		score  int
+		bet    int
+	}{
+		{0, PlayerStood, 20, 20},
+		{1, PlayerStood, 17, 10},
+		{2, PlayerBusted, 26, 50},
+	}
+	for _, s := range seats {
+		p := g.Players[s.index]
+		if p.Status != s.status || p.Score != s.score || p.Bet != s.bet {
+			t.Errorf("seat %d (%s) %s on %d betting %d, want %s on %d betting %d",
+				s.index, p.ID, p.Status, p.Score, p.Bet, s.status, s.score, s.bet)
+		}
+	}
+
+	// The first seat's win of 40 lands on p1's one balance
+	if g.Players[0].Balance != 970 || g.Players[2].Balance != 970 {
+		t.Errorf("p1's seats hold balances %d and %d, want both at 970", g.Players[0].Balance, g.Players[2].Balance)
+	}
+	if g.Players[1].Balance != 990 {
+		t.Errorf("p2's balance = %d, want 990", g.Players[1].Balance)
+	}
+}
@@ -0,0 +1,43 @@
+package game
+
+// grantBonusBets gives each player on their first round at the table a free,
+// house-funded bonus bet when bonuses are enabled
+func (g *BlackjackGame) grantBonusBets() {
+	for i := range g.Players {
+		if g.Players[i].SitsOutRound() {
+			continue
+		}
+		// The bonus is per player, so extra seats don't earn another
+		if g.BonusEnabled && g.Players[i].RoundsPlayed == 0 && g.Players[i].Seat == 0 {
+			g.Players[i].BonusBet = g.bonusAmount()
+		}
+		g.Players[i].RoundsPlayed++
+	}
+}
+
+// bonusAmount returns the size of the free bonus bet, defaulting to the table minimum
+func (g *BlackjackGame) bonusAmount() int {
+	if g.BonusAmount > 0 {
+		return g.BonusAmount
+	}
+	return g.MinBet
+}
+
+// BonusWinnings returns what a player's bonus bet pays out once the round is
+// complete. The stake belongs to the house, so only the profit is paid and
+// nothing is returned on a push or loss. Split players are paid on their
+// first hand.
+func (g *BlackjackGame) BonusWinnings(p Player) int {
+	if p.BonusBet == 0 {
+		return 0
+	}
+
+	hand := p.PlayedHands()[0]
+	hand.Bet = p.BonusBet
+
+	result, winnings := g.HandResult(hand)
+	if result == "win" || result == "blackjack" {
+		return winnings - p.BonusBet
+	}
+	return 0
+}
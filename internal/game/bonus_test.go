@@ -0,0 +1,53 @@
+package game
+
+import "testing"
+
+func TestBonusBets(t *testing.T) {
+	tests := []struct {
+		name        string
+		deal        []string
+		wantBalance int
+	}{
+		{"win pays the bonus profit", []string{"10H", "QS", "10D", "8C"}, 1020},
+		{"push returns nothing", []string{"10H", "8S", "10D", "8C"}, 1000},
+		{"loss costs only the main bet", []string{"10H", "7S", "10D", "QC"}, 990},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame("p1")
+			g.BonusEnabled = true
+			dealRound(t, g, tt.deal...)
+
+			p := g.Players[0]
+			if p.BonusBet != g.MinBet {
+				t.Errorf("bonus bet = %d, want the table minimum %d", p.BonusBet, g.MinBet)
+			}
+			if p.Balance != 990 {
+				t.Errorf("balance after the deal = %d, want 990 with only the main bet taken", p.Balance)
+			}
+
+			g.Stand("p1")
+			if got := g.Players[0].Balance; got != tt.wantBalance {
+				t.Errorf("balance = %d, want %d", got, tt.wantBalance)
+			}
+		})
+	}
+}
+
+func TestBonusBetOnlyOnFirstRound(t *testing.T) {
+	g := newTestGame("p1")
+	g.BonusEnabled = true
+	g.BonusAmount = 25
+	dealRound(t, g, "10H", "7S", "10D", "QC")
+	g.Stand("p1")
+	if g.Players[0].BonusBet != 25 {
+		t.Fatalf("first round bonus = %d, want 25", g.Players[0].BonusBet)
+	}
+
+	g.PrepareForNextRound()
+	dealRound(t, g, "10H", "QS", "10D", "8C")
+	if g.Players[0].BonusBet != 0 {
+		t.Errorf("second round bonus = %d, want none", g.Players[0].BonusBet)
+	}
+}
@@ -1,5 +1,11 @@
 package game
 
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
 type Suit string
 type Rank string
 
@@ -60,3 +66,68 @@ func (c Card) GetValue() int {
 		return 0
 	}
 }
+
+// CardValue returns the value of the card, using the override for its rank
+// if one is set and the standard blackjack value otherwise
+func CardValue(card Card, overrides map[Rank]int) int {
+	if value, ok := overrides[card.Rank]; ok {
+		return value
+	}
+	return card.GetValue()
+}
+
+// ErrInvalidCard is returned when a card's shorthand can't be parsed
+var ErrInvalidCard = errors.New("invalid card")
+
+// HiddenCard is the shorthand for a face-down card
+const HiddenCard = "??"
+
+// rankCodes maps each rank to its shorthand
+var rankCodes = map[Rank]string{
+	Ace: "A", Two: "2", Three: "3", Four: "4", Five: "5", Six: "6", Seven: "7",
+	Eight: "8", Nine: "9", Ten: "10", Jack: "J", Queen: "Q", King: "K",
+}
+
+// suitCodes maps each suit to its shorthand
+var suitCodes = map[Suit]string{
+	Hearts: "H", Diamonds: "D", Clubs: "C", Spades: "S",
+}
+
+// String returns the card's shorthand, e.g. "AS", "10H" or "QD", or "??" for
+// a face-down card
+func (c Card) String() string {
+	if !c.Face {
+		return HiddenCard
+	}
+	return rankCodes[c.Rank] + suitCodes[c.Suit]
+}
+
+// ParseCard parses a card's shorthand, e.g. "AS", "10H" or "qd", into a
+// face-up card. "T" is accepted for ten. Face-down cards can't be parsed.
+func ParseCard(s string) (Card, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if len(s) < 2 {
+		return Card{}, fmt.Errorf("%w: %q", ErrInvalidCard, s)
+	}
+
+	rankCode, suitCode := s[:len(s)-1], s[len(s)-1:]
+	if rankCode == "T" {
+		rankCode = "10"
+	}
+
+	card := Card{Face: true}
+	for rank, code := range rankCodes {
+		if code == rankCode {
+			card.Rank = rank
+		}
+	}
+	for suit, code := range suitCodes {
+		if code == suitCode {
+			card.Suit = suit
+		}
+	}
+	if card.Rank == "" || card.Suit == "" {
+		return Card{}, fmt.Errorf("%w: %q", ErrInvalidCard, s)
+	}
+	return card, nil
+}
@@ -0,0 +1,46 @@
+package game
+
+// hiLoValue returns a card's Hi-Lo count: +1 for 2 to 6, 0 for 7 to 9 and -1
+// for tens and aces
+func hiLoValue(rank Rank) int {
+	switch rank {
+	case Two, Three, Four, Five, Six:
+		return 1
+	case Ace, Ten, Jack, Queen, King:
+		return -1
+	}
+	return 0
+}
+
+// RunningCount returns the Hi-Lo running count of every card seen since the
+// shoe was shuffled: the discard pile and the face-up cards in play. The
+// dealer's hole card only counts once it is turned over.
+func (g *BlackjackGame) RunningCount() int {
+	count := 0
+	if g.Deck != nil {
+		for _, card := range g.Deck.Discard {
+			count += hiLoValue(card.Rank)
+		}
+	}
+
+	for _, card := range g.cardsInPlay() {
+		if card.Face {
+			count += hiLoValue(card.Rank)
+		}
+	}
+	return count
+}
+
+// countingState returns the shoe's composition and running count for
+// counting practice
+func (g *BlackjackGame) countingState() map[string]interface{} {
+	composition := map[Rank]int{}
+	if g.Deck != nil {
+		composition = g.Deck.Composition()
+	}
+
+	return map[string]interface{}{
+		"composition":  composition,
+		"runningCount": g.RunningCount(),
+	}
+}
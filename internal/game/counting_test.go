@@ -0,0 +1,46 @@
+package game
+
+import "testing"
+
+func TestRunningCountAfterKnownDeals(t *testing.T) {
+	g := newTestGame("p1", "p2")
+	g.Practice = true
+
+	// p1: 5 (+1), K (-1); p2: 2 (+1), 3 (+1); dealer: 6 (+1) up, A hidden;
+	// then p1 draws 4 (+1), p2 draws 9 (0) and the dealer turns over the
+	// ace (-1) to stand on soft 17
+	dealRound(t, g, "5H", "KD", "2S", "3C", "6D", "AH", "4S", "9H")
+	steps := []struct {
+		name string
+		play func()
+		want int
+	}{
+		{"dealt, hole card hidden", func() {}, 3},
+		{"p1 hits", func() { g.Hit("p1"); g.Stand("p1") }, 4},
+		{"p2 hits", func() { g.Hit("p2") }, 4},
+		{"dealer plays", func() { g.Stand("p2") }, 3},
+		{"cards discarded", func() { g.PrepareForNextRound() }, 3},
+	}
+
+	for _, step := range steps {
+		step.play()
+		if got := g.RunningCount(); got != step.want {
+			t.Errorf("%s: running count = %d, want %d", step.name, got, step.want)
+		}
+		counting, ok := g.GetGameState("p1")["counting"].(map[string]interface{})
+		if !ok || counting["runningCount"] != step.want {
+			t.Errorf("%s: counting state = %v, want a running count of %d", step.name, counting, step.want)
+		}
+	}
+
+}
+
+func TestCountingOnlyOnPracticeTables(t *testing.T) {
+	for _, practice := range []bool{true, false} {
+		g := newTestGame("p1")
+		g.Practice = practice
+		if _, shown := g.GetGameState("p1")["counting"]; shown != practice {
+			t.Errorf("practice = %v: counting shown = %v", practice, shown)
+		}
+	}
+}
@@ -1,12 +1,27 @@
 package game
 
 import (
+	"errors"
 	"math/rand"
 	"time"
 )
 
+// ErrDeckMissing is returned when a round in progress has no deck, e.g. a
+// game loaded from storage that didn't persist it
+var ErrDeckMissing = errors.New("deck missing for game in progress")
+
+// Deck is a shoe of cards. It is saved with the game so a round in progress
+// carries on from the same cards after a restart.
 type Deck struct {
-	Cards []Card
+	Cards []Card `json:"cards"` // Still to be dealt, top card first
+
+	// The cut card: once fewer cards than this remain, the shoe is
+	// reshuffled before the next round
+	ReshuffleThreshold int `json:"reshuffleThreshold"`
+
+	// Cards from finished rounds, kept until the shoe is reshuffled
+	Discard []Card `json:"discard,omitempty"`
+	Decks   int    `json:"decks"`
 }
 
 // NewDeck creates a new standard 52-card deck
@@ -30,9 +45,46 @@ func NewDeck() *Deck {
 	return deck
 }
 
+// NewShoe creates a shuffled shoe of numDecks standard 52-card decks
+func NewShoe(numDecks int) *Deck {
+	shoe := newUnshuffledShoe(numDecks)
+	shoe.Shuffle()
+	return shoe
+}
+
+// newUnshuffledShoe combines numDecks standard decks in order
+func newUnshuffledShoe(numDecks int) *Deck {
+	if numDecks < 1 {
+		numDecks = 1
+	}
+
+	shoe := &Deck{Decks: numDecks}
+	for i := 0; i < numDecks; i++ {
+		shoe.Cards = append(shoe.Cards, NewDeck().Cards...)
+	}
+
+	// Place the cut card at 75% penetration
+	shoe.ReshuffleThreshold = len(shoe.Cards) / 4
+	return shoe
+}
+
+// NewSeededDeck creates a new 52-card deck shuffled with the given seed, so
+// the same seed always produces the same order
+func NewSeededDeck(seed int64) *Deck {
+	deck := NewDeck()
+	deck.ShuffleWithSeed(seed)
+	return deck
+}
+
 // Shuffle randomizes the order of cards in the deck
 func (d *Deck) Shuffle() {
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	d.ShuffleWithSeed(time.Now().UnixNano())
+}
+
+// ShuffleWithSeed randomizes the order of cards in the deck using the given
+// seed, so the same seed always produces the same order
+func (d *Deck) ShuffleWithSeed(seed int64) {
+	r := rand.New(rand.NewSource(seed))
 
 	// Fisher-Yates shuffle algorithm
 	for i := len(d.Cards) - 1; i > 0; i-- {
@@ -52,7 +104,70 @@ func (d *Deck) DrawCard() (Card, bool) {
 	return card, true
 }
 
+// Refill rebuilds and shuffles a shoe that has run out mid-round. Every card
+// of the shoe goes back in except those still in play, and the discard pile
+// is emptied.
+func (d *Deck) Refill(excludeInPlay []Card) {
+	d.refill(excludeInPlay)
+	d.Shuffle()
+}
+
+// refill rebuilds the shoe in order without the cards still in play
+func (d *Deck) refill(excludeInPlay []Card) {
+	type cardKey struct {
+		suit Suit
+		rank Rank
+	}
+
+	inPlay := make(map[cardKey]int)
+	for _, card := range excludeInPlay {
+		inPlay[cardKey{card.Suit, card.Rank}]++
+	}
+
+	fresh := newUnshuffledShoe(d.Decks)
+	d.Cards = d.Cards[:0]
+	for _, card := range fresh.Cards {
+		key := cardKey{card.Suit, card.Rank}
+		if inPlay[key] > 0 {
+			inPlay[key]--
+			continue
+		}
+		d.Cards = append(d.Cards, card)
+	}
+	d.Discard = nil
+}
+
 // RemainingCards returns the number of cards left in the deck
 func (d *Deck) RemainingCards() int {
 	return len(d.Cards)
 }
+
+// DealtCount returns how many cards have left the shoe since it was last
+// shuffled, whether still in play or already discarded
+func (d *Deck) DealtCount() int {
+	decks := d.Decks
+	if decks < 1 {
+		decks = 1
+	}
+	return decks*52 - len(d.Cards)
+}
+
+// DiscardCards moves cards from a finished round onto the discard pile
+func (d *Deck) DiscardCards(cards ...Card) {
+	d.Discard = append(d.Discard, cards...)
+}
+
+// NeedsReshuffle reports whether the cut card has been reached
+func (d *Deck) NeedsReshuffle() bool {
+	return d.RemainingCards() < d.ReshuffleThreshold || d.RemainingCards() == 0
+}
+
+// Composition returns how many cards of each rank are left in the deck.
+// It never exposes the order of the cards.
+func (d *Deck) Composition() map[Rank]int {
+	counts := make(map[Rank]int)
+	for _, card := range d.Cards {
+		counts[card.Rank]++
+	}
+	return counts
+}
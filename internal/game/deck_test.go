@@ -0,0 +1,318 @@
+package game
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDeckComposition(t *testing.T) {
+	tests := []struct {
+		name  string
+		decks int
+		drawn int
+	}{
+		{"full deck", 1, 0},
+		{"partially dealt deck", 1, 17},
+		{"partially dealt shoe", 6, 100},
+		{"empty deck", 1, 52},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shoe := NewShoe(tt.decks)
+
+			dealt := make(map[Rank]int)
+			for i := 0; i < tt.drawn; i++ {
+				card, ok := shoe.DrawCard()
+				if !ok {
+					t.Fatalf("shoe ran out after %d cards", i)
+				}
+				dealt[card.Rank]++
+			}
+
+			composition := shoe.Composition()
+			total := 0
+			for _, rank := range allRanks {
+				want := 4*tt.decks - dealt[rank]
+				if composition[rank] != want {
+					t.Errorf("%s: %d left, want %d", rank, composition[rank], want)
+				}
+				total += composition[rank]
+			}
+			if total != shoe.RemainingCards() {
+				t.Errorf("composition counts %d cards, shoe holds %d", total, shoe.RemainingCards())
+			}
+		})
+	}
+}
+
+func TestNewShoe(t *testing.T) {
+	for _, decks := range []int{1, 6, 8} {
+		shoe := NewShoe(decks)
+		if shoe.RemainingCards() != 52*decks {
+			t.Errorf("%d decks: %d cards, want %d", decks, shoe.RemainingCards(), 52*decks)
+		}
+
+		copies := make(map[Card]int)
+		for _, card := range shoe.Cards {
+			copies[card]++
+		}
+		for card, n := range copies {
+			if n != decks {
+				t.Errorf("%d decks: %d copies of %s, want %d", decks, n, card, decks)
+			}
+		}
+	}
+
+	aces := 0
+	for _, card := range NewShoe(8).Cards {
+		if card.Rank == Ace {
+			aces++
+		}
+	}
+	if aces != 32 {
+		t.Errorf("8-deck shoe holds %d aces, want 32", aces)
+	}
+}
+
+func TestGameKeepsShoeSize(t *testing.T) {
+	g := NewBlackjackGame("table-1", 10, 500, 8)
+	if g.Deck.RemainingCards() != 8*52 {
+		t.Fatalf("new game's shoe holds %d cards, want %d", g.Deck.RemainingCards(), 8*52)
+	}
+
+	g.AddPlayer("p1", "Ann", 1000)
+	dealRound(t, g)
+	g.Stand("p1")
+
+	// Run the shoe down past the cut card so the next round rebuilds it
+	g.Deck.Cards = g.Deck.Cards[:1]
+	g.PrepareForNextRound()
+	if g.Deck.Decks != 8 || g.Deck.RemainingCards() != 8*52 {
+		t.Errorf("rebuilt shoe has %d decks and %d cards, want 8 and %d", g.Deck.Decks, g.Deck.RemainingCards(), 8*52)
+	}
+}
+
+func TestNewSeededDeck(t *testing.T) {
+	a, b, other := NewSeededDeck(42), NewSeededDeck(42), NewSeededDeck(43)
+	if !reflect.DeepEqual(a.Cards, b.Cards) {
+		t.Error("the same seed shuffled two different decks")
+	}
+	if reflect.DeepEqual(a.Cards, other.Cards) {
+		t.Error("different seeds shuffled the same deck")
+	}
+	if reflect.DeepEqual(a.Cards, NewDeck().Cards) {
+		t.Error("the seeded deck wasn't shuffled")
+	}
+}
+
+func TestSeededRoundsRepeat(t *testing.T) {
+	play := func() *BlackjackGame {
+		g := newTestGame("p1")
+		g.Deck = NewSeededDeck(2024)
+		dealRound(t, g)
+		g.Stand("p1")
+		return g
+	}
+
+	a, b := play(), play()
+	if !reflect.DeepEqual(a.Dealer.Hand, b.Dealer.Hand) || !reflect.DeepEqual(a.Players[0].Hand, b.Players[0].Hand) {
+		t.Error("the same seed dealt two different rounds")
+	}
+	if a.Players[0].Balance != b.Players[0].Balance {
+		t.Errorf("the same seed settled at %d and %d", a.Players[0].Balance, b.Players[0].Balance)
+	}
+}
+
+func TestDealerBustsOnKnownDraws(t *testing.T) {
+	g := newTestGame("p1")
+	dealRound(t, g, "10H", "2S", "10D", "6C", "KS")
+	g.Stand("p1")
+
+	if g.Dealer.Score != 26 || len(g.Dealer.Hand) != 3 {
+		t.Errorf("dealer has %v scoring %d, want 10 6 K for 26", g.Dealer.Hand, g.Dealer.Score)
+	}
+	if g.Players[0].Balance != 1010 {
+		t.Errorf("balance = %d, want 1010 from the dealer's bust", g.Players[0].Balance)
+	}
+}
+
+func TestNeedsReshuffle(t *testing.T) {
+	tests := []struct {
+		name      string
+		remaining int
+		threshold int
+		want      bool
+	}{
+		{"above the cut card", 100, 78, false},
+		{"at the cut card", 78, 78, false},
+		{"past the cut card", 77, 78, true},
+		{"empty without a cut card", 0, 0, true},
+		{"cards left without a cut card", 5, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &Deck{Cards: make([]Card, tt.remaining), ReshuffleThreshold: tt.threshold}
+			if got := d.NeedsReshuffle(); got != tt.want {
+				t.Errorf("NeedsReshuffle = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrepareForNextRoundReshufflesAtCutCard(t *testing.T) {
+	g := NewBlackjackGame("table-1", 10, 500, 6)
+	g.AddPlayer("p1", "Ann", 1000)
+	dealRound(t, g)
+	g.Stand("p1")
+
+	// Above the cut card the next round deals on from the same shoe
+	remaining := g.Deck.RemainingCards()
+	g.PrepareForNextRound()
+	if g.Deck.RemainingCards() != remaining {
+		t.Fatalf("shoe holds %d cards after the round, want the same %d", g.Deck.RemainingCards(), remaining)
+	}
+	if state := g.GetGameState("p1"); state["remainingCards"] != remaining {
+		t.Errorf("state shows %v cards remaining, want %d", state["remainingCards"], remaining)
+	}
+
+	// Past it the shoe is rebuilt
+	dealRound(t, g)
+	g.Stand("p1")
+	g.Deck.Cards = g.Deck.Cards[:g.Deck.ReshuffleThreshold-1]
+	g.PrepareForNextRound()
+	if g.Deck.RemainingCards() != 6*52 {
+		t.Errorf("shoe holds %d cards after the cut card, want a fresh %d", g.Deck.RemainingCards(), 6*52)
+	}
+}
+
+func TestShoeTracksCardsAcrossRounds(t *testing.T) {
+	g := NewBlackjackGame("table-1", 10, 500, 2)
+	g.AddPlayer("p1", "Ann", 1000)
+	g.AddPlayer("p2", "Ben", 1000)
+
+	for round := 1; round <= 5; round++ {
+		dealRound(t, g)
+		for g.Status == InProgress {
+			g.Stand(g.Players[g.CurrentPlayerIndex].ID)
+		}
+
+		// Every card of the shoe is either still to come, in play or discarded
+		inPlay := g.cardsInPlay()
+		if got := g.Deck.DealtCount(); got != len(g.Deck.Discard)+len(inPlay) {
+			t.Fatalf("round %d: dealt %d cards, but %d are discarded and %d in play", round, got, len(g.Deck.Discard), len(inPlay))
+		}
+		seen := make(map[Rank]int)
+		for _, card := range append(g.Deck.Discard, inPlay...) {
+			seen[card.Rank]++
+		}
+		composition := g.Deck.Composition()
+		for _, rank := range allRanks {
+			if composition[rank]+seen[rank] != 8 {
+				t.Errorf("round %d: %d %ss left and %d seen, want 8 in all", round, composition[rank], rank, seen[rank])
+			}
+		}
+
+		// The running count covers the earlier rounds as well
+		want := 0
+		for _, card := range g.Deck.Discard {
+			want += hiLoValue(card.Rank)
+		}
+		for _, card := range inPlay {
+			want += hiLoValue(card.Rank)
+		}
+		if got := g.RunningCount(); got != want {
+			t.Errorf("round %d: running count = %d, want %d", round, got, want)
+		}
+
+		g.PrepareForNextRound()
+	}
+}
+
+func TestRefillLeavesOutCardsInPlay(t *testing.T) {
+	d := NewShoe(1)
+	d.Cards = nil
+	d.Discard = cards(t, "2C", "3C")
+
+	inPlay := cards(t, "AS", "KH", "AS")
+	d.Refill(inPlay)
+
+	if d.RemainingCards() != 50 || len(d.Discard) != 0 {
+		t.Fatalf("refilled deck holds %d cards with %d discarded, want 50 and none", d.RemainingCards(), len(d.Discard))
+	}
+	for _, card := range d.Cards {
+		if card == inPlay[0] || card == inPlay[1] {
+			t.Errorf("refilled deck holds %s, which is still in play", card)
+		}
+	}
+}
+
+func TestRoundCompletesWhenDeckRunsOut(t *testing.T) {
+	g := newTestGame("p1", "p2")
+
+	// Leave only the cards for the deal, so every hit and the dealer's
+	// draws come from a refilled deck
+	g.Deck.Cards = nil
+	dealRound(t, g, "2H", "3H", "2S", "3S", "4D", "2D")
+	if g.Deck.RemainingCards() != 0 {
+		t.Fatalf("%d cards left after the deal, want none", g.Deck.RemainingCards())
+	}
+	for g.Status == InProgress {
+		p := g.Players[g.CurrentPlayerIndex]
+		if p.Score < 17 {
+			if _, ok := g.Hit(p.ID); !ok {
+				t.Fatalf("%s's hit failed with %d cards left", p.ID, g.Deck.RemainingCards())
+			}
+			continue
+		}
+		g.Stand(p.ID)
+	}
+
+	if g.Status != Completed {
+		t.Fatalf("status = %s, want the round completed", g.Status)
+	}
+	if g.Dealer.Score < 17 {
+		t.Errorf("dealer stopped on %d, want at least 17", g.Dealer.Score)
+	}
+	if g.Deck.RemainingCards()+len(g.cardsInPlay()) != 52 {
+		t.Errorf("%d cards left and %d in play, want the 52 of the deck", g.Deck.RemainingCards(), len(g.cardsInPlay()))
+	}
+}
+
+func TestReshuffleEmitsEvent(t *testing.T) {
+	tests := []struct {
+		name  string
+		decks int
+	}{
+		{"single deck", 1},
+		{"shoe", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewBlackjackGame("table-1", 10, 500, tt.decks)
+			g.AddPlayer("p1", "Ann", 1000)
+			g.AddPlayer("p2", "Ben", 1000)
+			g.DrainEvents()
+
+			for round := 1; round <= 50; round++ {
+				playByTheBook(t, g, 1)
+				g.PrepareForNextRound()
+
+				for _, event := range g.DrainEvents() {
+					if event.Type != EventDeckReshuffled {
+						continue
+					}
+					// A shoe run out mid-round is refilled with only the cards
+					// not in play, so the count can fall short of a full shoe
+					if event.Remaining <= 0 || event.Remaining > tt.decks*52 {
+						t.Errorf("event reports %d cards left, want up to %d", event.Remaining, tt.decks*52)
+					}
+					return
+				}
+			}
+			t.Fatal("no deckReshuffled event in 50 rounds")
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package game
+
+import "time"
+
+// EventType identifies something that happened in a game which clients may
+// want to be told about directly
+type EventType string
+
+const (
+	EventTurnStarted     EventType = "turnStarted"     // A player became the active player
+	EventBettingClosed   EventType = "bettingClosed"   // Betting closed by itself, dealing the round or going back to waiting
+	EventDealerBlackjack EventType = "dealerBlackjack" // The dealer peeked and found blackjack, ending the round
+	EventDeckReshuffled  EventType = "deckReshuffled"  // The shoe was reshuffled, so any running count starts over
+)
+
+// Event is a notable change in a game, queued for the caller to publish
+type Event struct {
+	Type        EventType `json:"type"`
+	PlayerID    string    `json:"playerId,omitempty"`
+	PlayerIndex int       `json:"playerIndex"`
+	Deadline    time.Time `json:"deadline,omitempty"`  // When the turn times out, zero if it doesn't
+	Dealt       bool      `json:"dealt,omitempty"`     // Whether closing betting dealt the round
+	Remaining   int       `json:"remaining,omitempty"` // Cards left in the shoe after a reshuffle
+}
+
+// emit queues an event for the caller to publish
+func (g *BlackjackGame) emit(event Event) {
+	g.events = append(g.events, event)
+}
+
+// DrainEvents returns the events queued since the last call and clears them.
+// Events aren't persisted, so callers should drain them after each action.
+func (g *BlackjackGame) DrainEvents() []Event {
+	events := g.events
+	g.events = nil
+	return events
+}
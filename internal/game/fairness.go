@@ -0,0 +1,174 @@
+package game
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"strconv"
+)
+
+// FairnessMode controls how the shuffle seed is disclosed to players
+type FairnessMode string
+
+const (
+	FairnessOff           FairnessMode = "off"           // Seed is never disclosed
+	FairnessCommitReveal  FairnessMode = "commit-reveal" // Seed hash published when shuffled, seed revealed once the shoe is finished
+	FairnessDeterministic FairnessMode = "deterministic" // Shuffle uses a seed agreed with the client (practice only)
+)
+
+var (
+	// ErrUnknownFairnessMode is returned for an unrecognized fairness mode
+	ErrUnknownFairnessMode = errors.New("unknown fairness mode")
+
+	// ErrDeterministicRanked is returned when deterministic shuffles are requested on a ranked table
+	ErrDeterministicRanked = errors.New("deterministic shuffles are not allowed on ranked tables")
+)
+
+// SetFairness sets the table's fairness mode and reshuffles the deck
+// accordingly. The seed is only used in deterministic mode.
+func (g *BlackjackGame) SetFairness(mode FairnessMode, seed int64) error {
+	switch mode {
+	case "", FairnessOff:
+		mode = FairnessOff
+	case FairnessCommitReveal:
+	case FairnessDeterministic:
+		if g.Ranked {
+			return ErrDeterministicRanked
+		}
+		g.Seed = seed
+	default:
+		return ErrUnknownFairnessMode
+	}
+
+	g.FairnessMode = mode
+	g.record(Action{Type: ActionFairness, Mode: mode, Seed: seed})
+	g.newShoe()
+	return nil
+}
+
+// newShoe replaces the deck with a freshly shuffled one
+func (g *BlackjackGame) newShoe() {
+	g.Deck = newUnshuffledShoe(g.NumDecks)
+	g.shuffleShoe()
+}
+
+// refillShoe rebuilds a shoe that ran out mid-round from every card not in
+// play, shuffling it the same way as a new shoe
+func (g *BlackjackGame) refillShoe() {
+	g.Deck.refill(g.cardsInPlay())
+	g.shuffleShoe()
+}
+
+// shuffleShoe shuffles the deck, seeding the shuffle according to the
+// fairness mode. The seed always goes in the action log so the game can be
+// replayed, even when it isn't disclosed.
+func (g *BlackjackGame) shuffleShoe() {
+	var seed int64
+	switch g.FairnessMode {
+	case FairnessCommitReveal:
+		// The retired shoe's seed can now be revealed
+		if g.SeedHash != "" {
+			g.PreviousSeed = g.Seed
+			g.PreviousSeedHash = g.SeedHash
+		}
+		seed = g.nextReplaySeed(randomSeed())
+		g.Seed = seed
+		g.SeedHash = hashSeed(seed)
+
+	case FairnessDeterministic:
+		// Each new shoe moves on to the next seed so rounds don't repeat
+		seed = g.Seed
+		if g.SeedHash != "" {
+			seed++
+		}
+		seed = g.nextReplaySeed(seed)
+		g.Seed = seed
+		g.SeedHash = hashSeed(seed)
+
+	default:
+		seed = g.nextReplaySeed(randomSeed())
+		g.Seed = 0
+		g.SeedHash = ""
+	}
+
+	g.Deck.ShuffleWithSeed(seed)
+	g.record(Action{Type: ActionShuffle, Seed: seed})
+	g.emit(Event{Type: EventDeckReshuffled, Remaining: g.Deck.RemainingCards()})
+}
+
+// drawCard draws the next card, refilling the shoe from the cards not in play
+// if it has run out mid-round
+func (g *BlackjackGame) drawCard() (Card, bool) {
+	if card, ok := g.Deck.DrawCard(); ok {
+		return card, true
+	}
+
+	g.refillShoe()
+	return g.Deck.DrawCard()
+}
+
+// ensureDeck makes sure the game has a deck to draw from. A game between
+// rounds gets a fresh shoe, but one in progress can't be given a new deck
+// without changing the cards already dealt, so that is an error.
+func (g *BlackjackGame) ensureDeck() error {
+	if g.Deck != nil {
+		return nil
+	}
+
+	if g.Status == InProgress {
+		return ErrDeckMissing
+	}
+
+	g.newShoe()
+	return nil
+}
+
+// FairnessInfo returns the fairness details that may be disclosed to players
+func (g *BlackjackGame) FairnessInfo() map[string]interface{} {
+	mode := g.FairnessMode
+	if mode == "" {
+		mode = FairnessOff
+	}
+
+	info := map[string]interface{}{
+		"mode": mode,
+	}
+
+	switch mode {
+	case FairnessCommitReveal:
+		info["seedHash"] = g.SeedHash
+
+		// A shoe lasts several rounds, so its seed is only revealed once the
+		// round is over and the shoe won't be dealt from again
+		if g.Status == Completed && g.Deck != nil && g.Deck.NeedsReshuffle() {
+			info["seed"] = g.Seed
+		}
+		if g.PreviousSeedHash != "" {
+			info["previousSeed"] = g.PreviousSeed
+			info["previousSeedHash"] = g.PreviousSeedHash
+		}
+
+	case FairnessDeterministic:
+		info["seedHash"] = g.SeedHash
+		info["seed"] = g.Seed
+	}
+
+	return info
+}
+
+// randomSeed returns a cryptographically random shuffle seed
+func randomSeed() int64 {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic("unable to read random seed: " + err.Error())
+	}
+	return int64(binary.BigEndian.Uint64(b[:]))
+}
+
+// hashSeed returns the hex SHA-256 of the seed's decimal representation
+func hashSeed(seed int64) string {
+	sum := sha256.Sum256([]byte(strconv.FormatInt(seed, 10)))
+	return hex.EncodeToString(sum[:])
+}
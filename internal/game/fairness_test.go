@@ -0,0 +1,112 @@
+package game
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFairnessInfo(t *testing.T) {
+	tests := []struct {
+		name       string
+		mode       FairnessMode
+		finishShoe bool
+		want       []string // Fields disclosed besides the mode
+	}{
+		{"off", FairnessOff, false, nil},
+		{"commit-reveal mid-shoe", FairnessCommitReveal, false, []string{"seedHash"}},
+		{"commit-reveal finished shoe", FairnessCommitReveal, true, []string{"seedHash", "seed"}},
+		{"deterministic", FairnessDeterministic, false, []string{"seedHash", "seed"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame("p1")
+			g.Practice = true
+			if err := g.SetFairness(tt.mode, 42); err != nil {
+				t.Fatalf("SetFairness: %v", err)
+			}
+			if tt.finishShoe {
+				g.Status = Completed
+				g.Deck.Cards = g.Deck.Cards[:g.Deck.ReshuffleThreshold-1]
+			}
+
+			info := g.FairnessInfo()
+			if info["mode"] != tt.mode {
+				t.Errorf("mode = %v, want %s", info["mode"], tt.mode)
+			}
+			if len(info) != len(tt.want)+1 {
+				t.Errorf("disclosed %v, want only mode and %v", info, tt.want)
+			}
+			for _, field := range tt.want {
+				if _, ok := info[field]; !ok {
+					t.Errorf("%s wasn't disclosed", field)
+				}
+			}
+			if seed, ok := info["seed"]; ok && info["seedHash"] != hashSeed(seed.(int64)) {
+				t.Error("disclosed seed doesn't match its hash")
+			}
+		})
+	}
+}
+
+func TestFairnessCommitRevealRevealsPreviousShoe(t *testing.T) {
+	g := newTestGame("p1")
+	if err := g.SetFairness(FairnessCommitReveal, 0); err != nil {
+		t.Fatalf("SetFairness: %v", err)
+	}
+	seed, hash := g.Seed, g.SeedHash
+
+	g.newShoe()
+	info := g.FairnessInfo()
+	if info["previousSeed"] != seed || info["previousSeedHash"] != hash {
+		t.Errorf("previous shoe disclosed as %v / %v, want %d / %s", info["previousSeed"], info["previousSeedHash"], seed, hash)
+	}
+	if info["seedHash"] == hash {
+		t.Error("the new shoe has the old shoe's hash")
+	}
+}
+
+func TestSetFairnessDeterministic(t *testing.T) {
+	a, b := newTestGame(), newTestGame()
+	for _, g := range []*BlackjackGame{a, b} {
+		g.Practice = true
+		if err := g.SetFairness(FairnessDeterministic, 7); err != nil {
+			t.Fatalf("SetFairness: %v", err)
+		}
+	}
+	for i := range a.Deck.Cards {
+		if a.Deck.Cards[i] != b.Deck.Cards[i] {
+			t.Fatal("the same seed shuffled two different shoes")
+		}
+	}
+
+	if err := a.SetFairness("psychic", 0); !errors.Is(err, ErrUnknownFairnessMode) {
+		t.Errorf("unknown mode: err = %v, want %v", err, ErrUnknownFairnessMode)
+	}
+}
+
+func TestDeterministicRefusedOnRankedTables(t *testing.T) {
+	tests := []struct {
+		name     string
+		practice bool
+		ranked   bool
+		want     error
+	}{
+		{"practice", true, false, nil},
+		{"casual", false, false, nil},
+		{"ranked", false, true, ErrDeterministicRanked},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame("p1")
+			g.Practice, g.Ranked = tt.practice, tt.ranked
+			if err := g.SetFairness(FairnessDeterministic, 7); !errors.Is(err, tt.want) {
+				t.Fatalf("SetFairness = %v, want %v", err, tt.want)
+			}
+			if refused := tt.want != nil; refused && g.FairnessMode == FairnessDeterministic {
+				t.Error("the refused mode was applied")
+			}
+		})
+	}
+}
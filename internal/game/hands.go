@@ -0,0 +1,220 @@
+package game
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+var (
+	// ErrInvalidHandIndex is returned when a player has no hand at the given index
+	ErrInvalidHandIndex = errors.New("hand index out of range")
+
+	// ErrHandNotActive is returned when acting on a hand that isn't the one being played
+	ErrHandNotActive = errors.New("hand is not the active hand")
+)
+
+// Hand is one of a player's hands after splitting a pair
+type Hand struct {
+	Cards  []Card       `json:"cards"`
+	Score  int          `json:"score"`
+	Status PlayerStatus `json:"status"`
+	Bet    int          `json:"bet"`
+}
+
+// IsSplit reports whether the player has split their hand
+func (p Player) IsSplit() bool {
+	return len(p.Hands) > 0
+}
+
+// PlayedHands returns every hand the player holds. A player who hasn't split
+// has a single hand made from their Hand, Score, Status and Bet.
+func (p Player) PlayedHands() []Hand {
+	if p.IsSplit() {
+		return p.Hands
+	}
+	return []Hand{{Cards: p.Hand, Score: p.Score, Status: p.Status, Bet: p.Bet}}
+}
+
+// ValidateHandIndex checks that an action targets a hand the player holds
+// and that it is the hand currently being played. A player with several
+// seats is checked against the seat whose turn it is.
+func (g *BlackjackGame) ValidateHandIndex(playerID string, handIndex int) error {
+	i := g.actingSeat(playerID)
+	if i < 0 {
+		return ErrInvalidHandIndex
+	}
+
+	p := g.Players[i]
+	if handIndex < 0 || handIndex >= len(p.PlayedHands()) {
+		return ErrInvalidHandIndex
+	}
+	if handIndex != p.ActiveHand {
+		return ErrHandNotActive
+	}
+	return nil
+}
+
+// Split splits the current player's pair into two hands, each receiving one
+// new card, with a second bet equal to the first.
+//
+// Split hands are played in order: the player acts on hand 0 until it
+// stands or busts, then on hand 1, and only once every hand is finished does
+// the turn pass to the next player. While split, the player's Hand and Score
+// mirror the hand being played and ActiveHand holds its index. Tables whose
+// rules allow more than one split let a pair dealt to a split hand be split
+// again, up to MaxSplits times.
+func (g *BlackjackGame) Split(playerID string) bool {
+	if g.Status != InProgress {
+		return false
+	}
+
+	if err := g.ensureDeck(); err != nil {
+		log.Printf("Unable to split in game %s: %v", g.ID, err)
+		return false
+	}
+
+	for i, p := range g.Players {
+		if p.ID != playerID || i != g.CurrentPlayerIndex {
+			continue
+		}
+
+		// Only a matching pair can be split, on the player's turn, while the
+		// rules allow another split, and only if they can cover the new bet
+		if !p.IsActive || p.Status != PlayerActive {
+			return false
+		}
+		stake := p.Bet
+		if p.IsSplit() {
+			stake = p.Hands[p.ActiveHand].Bet
+		}
+		if len(p.Hand) != 2 || p.Hand[0].Rank != p.Hand[1].Rank || g.splitsLeft(p) == 0 || p.Balance < stake {
+			return false
+		}
+		card1, ok1 := g.drawCard()
+		card2, ok2 := g.drawCard()
+		if !ok1 || !ok2 {
+			return false
+		}
+		card1.Face = true
+		card2.Face = true
+
+		split := []Hand{
+			{Cards: []Card{p.Hand[0], card1}, Status: PlayerActive, Bet: stake},
+			{Cards: []Card{p.Hand[1], card2}, Status: PlayerActive, Bet: stake},
+		}
+		for h := range split {
+			split[h].Score = g.CalculateHandScore(split[h].Cards)
+		}
+
+		// Take the new bet
+		g.adjustBalance(i, -stake)
+		g.Players[i].Bet = p.Bet + stake
+
+		// A resplit pair's hand is replaced by the two new hands
+		hands := split
+		if p.IsSplit() {
+			hands = append([]Hand{}, p.Hands[:p.ActiveHand]...)
+			hands = append(hands, split...)
+			hands = append(hands, p.Hands[p.ActiveHand+1:]...)
+		} else {
+			g.Players[i].ActiveHand = 0
+		}
+		g.Players[i].Hands = hands
+		g.syncActiveHand(i)
+
+		g.restartTurnClock()
+		g.UpdatedAt = time.Now()
+		g.record(Action{Type: ActionSplit, PlayerID: playerID})
+		return true
+	}
+	return false
+}
+
+// addCardToActiveHand adds a card to a split player's active hand, moving on
+// if it busts or makes a Five Card Charlie
+func (g *BlackjackGame) addCardToActiveHand(i int, card Card) {
+	p := &g.Players[i]
+	hand := &p.Hands[p.ActiveHand]
+
+	hand.Cards = append(hand.Cards, card)
+	hand.Score = g.CalculateHandScore(hand.Cards)
+	g.syncActiveHand(i)
+
+	if hand.Score > 21 {
+		g.finishActiveHand(i, PlayerBusted)
+	} else if g.isCharlie(hand.Cards) {
+		g.finishActiveHand(i, PlayerCharlie)
+	}
+}
+
+// finishActiveHand ends play on a split player's active hand. The player
+// moves on to their next hand, or the turn passes on once every hand is done.
+func (g *BlackjackGame) finishActiveHand(i int, status PlayerStatus) {
+	p := &g.Players[i]
+	p.Hands[p.ActiveHand].Status = status
+
+	if p.ActiveHand+1 < len(p.Hands) {
+		p.ActiveHand++
+		g.syncActiveHand(i)
+		return
+	}
+
+	// Every hand has been played. The player only counts as busted if all
+	// of their hands busted.
+	p.Status = PlayerBusted
+	for _, hand := range p.Hands {
+		if hand.Status != PlayerBusted {
+			p.Status = PlayerStood
+			break
+		}
+	}
+	p.IsActive = false
+	g.NextPlayer()
+}
+
+// syncActiveHand mirrors a split player's active hand into their top-level
+// Hand and Score so clients that don't know about splits still see the hand
+// being played
+func (g *BlackjackGame) syncActiveHand(i int) {
+	p := &g.Players[i]
+	p.Hand = p.Hands[p.ActiveHand].Cards
+	p.Score = p.Hands[p.ActiveHand].Score
+}
+
+// doubleActiveHand doubles down on a split player's active hand
+func (g *BlackjackGame) doubleActiveHand(i int) (Card, bool) {
+	p := &g.Players[i]
+	hand := p.Hands[p.ActiveHand]
+
+	if !g.RuleSet.DoubleAfterSplit || len(hand.Cards) != 2 || p.Balance < hand.Bet {
+		return Card{}, false
+	}
+
+	card, success := g.drawCard()
+	if !success {
+		return Card{}, false
+	}
+	card.Face = true
+
+	// Double the hand's bet
+	g.adjustBalance(i, -hand.Bet)
+	p.Bet += hand.Bet
+	p.Hands[p.ActiveHand].Bet *= 2
+
+	h := &p.Hands[p.ActiveHand]
+	h.Cards = append(h.Cards, card)
+	h.Score = g.CalculateHandScore(h.Cards)
+	g.syncActiveHand(i)
+
+	// The hand gets no further cards either way
+	if h.Score > 21 {
+		g.finishActiveHand(i, PlayerBusted)
+	} else {
+		g.finishActiveHand(i, PlayerStood)
+	}
+
+	g.restartTurnClock()
+	g.UpdatedAt = time.Now()
+	return card, true
+}
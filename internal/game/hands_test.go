@@ -0,0 +1,143 @@
+package game
+
+import "testing"
+
+func TestSplit(t *testing.T) {
+	g := newTestGame("p1")
+	dealRound(t, g, "8H", "8S", "10D", "7C", "3H", "KS")
+
+	if !g.Split("p1") {
+		t.Fatal("Split failed")
+	}
+	p := g.Players[0]
+	if len(p.Hands) != 2 || p.ActiveHand != 0 {
+		t.Fatalf("%d hands with hand %d active, want 2 with hand 0 active", len(p.Hands), p.ActiveHand)
+	}
+	for h, want := range []int{11, 18} {
+		if p.Hands[h].Score != want || p.Hands[h].Bet != 10 || len(p.Hands[h].Cards) != 2 {
+			t.Errorf("hand %d = %+v, want two cards scoring %d for 10", h, p.Hands[h], want)
+		}
+	}
+	if p.Bet != 20 || p.Balance != 980 {
+		t.Errorf("bet %d with balance %d, want 20 and 980", p.Bet, p.Balance)
+	}
+	if p.Score != 11 || len(p.Hand) != 2 {
+		t.Errorf("top-level hand scores %d, want it to mirror hand 0", p.Score)
+	}
+}
+
+func TestSplitRefused(t *testing.T) {
+	tests := []struct {
+		name  string
+		deal  []string
+		setup func(g *BlackjackGame)
+	}{
+		{"not a pair", []string{"8H", "9S", "10D", "7C"}, nil},
+		{"ten and king", []string{"10H", "KS", "10D", "7C"}, nil},
+		{"can't cover the bet", []string{"8H", "8S", "10D", "7C"}, func(g *BlackjackGame) { g.Players[0].Balance = 5 }},
+		{"no splitting", []string{"8H", "8S", "10D", "7C"}, func(g *BlackjackGame) { g.MaxSplits = 0 }},
+		{"after hitting", []string{"2H", "2S", "10D", "7C", "2D"}, func(g *BlackjackGame) { g.Hit("p1") }},
+		{"no resplit", []string{"8H", "8S", "10D", "7C", "8D", "8C"}, func(g *BlackjackGame) { g.Split("p1") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame("p1")
+			dealRound(t, g, tt.deal...)
+			if tt.setup != nil {
+				tt.setup(g)
+			}
+
+			hands, balance := len(g.Players[0].PlayedHands()), g.Players[0].Balance
+			if g.Split("p1") {
+				t.Fatal("Split succeeded")
+			}
+			if len(g.Players[0].PlayedHands()) != hands || g.Players[0].Balance != balance {
+				t.Error("a refused split changed the hand")
+			}
+		})
+	}
+}
+
+func TestResplit(t *testing.T) {
+	g := newTestGame("p1")
+	g.MaxSplits = 2
+	dealRound(t, g, "8H", "8S", "10D", "7C", "8D", "2C", "3C", "4C")
+
+	if !g.Split("p1") || !g.Split("p1") {
+		t.Fatal("resplitting 8s failed")
+	}
+	p := g.Players[0]
+	if len(p.Hands) != 3 || p.Bet != 30 || p.Balance != 970 {
+		t.Fatalf("%d hands for %d with balance %d, want 3 for 30 with 970", len(p.Hands), p.Bet, p.Balance)
+	}
+	if g.Split("p1") {
+		t.Error("split a third time with MaxSplits 2")
+	}
+}
+
+func TestSplitSettlement(t *testing.T) {
+	tests := []struct {
+		name        string
+		deal        []string
+		play        func(g *BlackjackGame)
+		wantResults []string
+		wantBalance int
+	}{
+		{
+			"win and lose",
+			// Hands 8 10 and 8 5, dealer 10 7
+			[]string{"8H", "8S", "10D", "7C", "10H", "5S"},
+			func(g *BlackjackGame) { g.Stand("p1"); g.Stand("p1") },
+			[]string{"win", "lose"}, 1000,
+		},
+		{
+			"bust and push",
+			// Hands 8 4 10 and 8 9, dealer 10 7
+			[]string{"8H", "8S", "10D", "7C", "4H", "9S", "KC"},
+			func(g *BlackjackGame) { g.Hit("p1"); g.Stand("p1") },
+			[]string{"lose", "push"}, 990,
+		},
+		{
+			"double a split hand",
+			// Hands 8 3 10 doubled and 8 10, dealer 10 7
+			[]string{"8H", "8S", "10D", "7C", "3H", "10S", "10C"},
+			func(g *BlackjackGame) { g.DoubleDown("p1"); g.Stand("p1") },
+			[]string{"win", "win"}, 1030,
+		},
+		{
+			"both bust",
+			// Hands 8 6 10 and 8 7 9, dealer 10 7
+			[]string{"8H", "8S", "10D", "7C", "6H", "7S", "10C", "9C"},
+			func(g *BlackjackGame) { g.Hit("p1"); g.Hit("p1") },
+			[]string{"lose", "lose"}, 980,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame("p1")
+			dealRound(t, g, tt.deal...)
+			if !g.Split("p1") {
+				t.Fatal("Split failed")
+			}
+			tt.play(g)
+			if g.Status != Completed {
+				t.Fatalf("status = %s, want the round completed", g.Status)
+			}
+
+			results := g.SettleResults()
+			if len(results) != len(tt.wantResults) {
+				t.Fatalf("got %d results, want %d", len(results), len(tt.wantResults))
+			}
+			for h, want := range tt.wantResults {
+				if results[h].HandIndex != h || results[h].Outcome != want {
+					t.Errorf("hand %d: %s, want %s", results[h].HandIndex, results[h].Outcome, want)
+				}
+			}
+			if got := g.Players[0].Balance; got != tt.wantBalance {
+				t.Errorf("balance = %d, want %d", got, tt.wantBalance)
+			}
+		})
+	}
+}
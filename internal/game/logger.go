@@ -0,0 +1,14 @@
+package game
+
+import "log/slog"
+
+// logger receives the game package's debug output. It uses the default
+// logger, which drops debug messages, until SetLogger is called.
+var logger = slog.Default()
+
+// SetLogger sets the logger the game package writes debug output to
+func SetLogger(l *slog.Logger) {
+	if l != nil {
+		logger = l
+	}
+}
@@ -0,0 +1,29 @@
+package game
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+)
+
+func TestDebugOutputIsOptIn(t *testing.T) {
+	tests := []struct {
+		level    slog.Level
+		wantLogs bool
+	}{
+		{slog.LevelInfo, false},
+		{slog.LevelDebug, true},
+	}
+
+	defer SetLogger(logger)
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		SetLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: tt.level})))
+
+		g := newTestGame("p1")
+		g.AddPlayer("p2", "Ben", 1000)
+		if logged := buf.Len() > 0; logged != tt.wantLogs {
+			t.Errorf("at %s: logged %q, want output = %v", tt.level, buf.String(), tt.wantLogs)
+		}
+	}
+}
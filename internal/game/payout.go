@@ -0,0 +1,175 @@
+package game
+
+import (
+	"math"
+	"strings"
+)
+
+// PayoutTable holds every payout used when settling a round. Ratios are the
+// profit paid per unit bet, so 1.5 means 3:2; Surrender is the share of the
+// bet handed back instead.
+type PayoutTable struct {
+	Blackjack float64        `json:"blackjack"` // Natural blackjack
+	Win       float64        `json:"win"`       // Regular win
+	Charlie   float64        `json:"charlie"`   // Five Card Charlie, on tables that play it
+	Surrender float64        `json:"surrender"` // Share of the bet returned on a surrender
+	SideBets  SideBetPayouts `json:"sideBets"`  // Side bets, each as x to 1
+}
+
+// Named payout presets
+var (
+	VegasPayouts = PayoutTable{
+		Blackjack: 1.5,
+		Win:       1,
+		Charlie:   1,
+		Surrender: 0.5,
+		SideBets:  DefaultSideBetPayouts,
+	}
+	SixFivePayouts = PayoutTable{
+		Blackjack: 1.2,
+		Win:       1,
+		Charlie:   1,
+		Surrender: 0.5,
+		SideBets:  DefaultSideBetPayouts,
+	}
+)
+
+// DefaultPayouts is the payout table used when none is configured
+var DefaultPayouts = VegasPayouts
+
+// PayoutPreset returns the named payout preset ("vegas" or "sixFive")
+func PayoutPreset(name string) (PayoutTable, bool) {
+	switch strings.ToLower(name) {
+	case "vegas":
+		return VegasPayouts, true
+	case "sixfive", "6:5":
+		return SixFivePayouts, true
+	default:
+		return PayoutTable{}, false
+	}
+}
+
+// WithDefaults fills any unset payout from the given base table
+func (p PayoutTable) WithDefaults(base PayoutTable) PayoutTable {
+	fill := func(v *float64, def float64) {
+		if *v <= 0 {
+			*v = def
+		}
+	}
+	fill(&p.Blackjack, base.Blackjack)
+	fill(&p.Win, base.Win)
+	fill(&p.Charlie, base.Charlie)
+	fill(&p.Surrender, base.Surrender)
+	p.SideBets = p.SideBets.WithDefaults(base.SideBets)
+	return p
+}
+
+// BlackjackWinnings returns the total returned to a player for a winning
+// blackjack, including the original bet
+func (p PayoutTable) BlackjackWinnings(bet int) int {
+	return blackjackWinnings(bet, p.Blackjack)
+}
+
+// WinWinnings returns the total returned to a player for a regular win,
+// including the original bet
+func (p PayoutTable) WinWinnings(bet int) int {
+	return blackjackWinnings(bet, p.Win)
+}
+
+// CharlieWinnings returns the total returned to a player for a Five Card
+// Charlie, including the original bet
+func (p PayoutTable) CharlieWinnings(bet int) int {
+	return blackjackWinnings(bet, p.Charlie)
+}
+
+// SurrenderRefund returns the part of the bet handed back on a surrender,
+// rounded down
+func (p PayoutTable) SurrenderRefund(bet int) int {
+	return blackjackWinnings(bet, p.Surrender) - bet
+}
+
+// SideBetWinnings returns the total returned for a winning side bet paid at
+// ratio to 1, including the stake
+func (p PayoutTable) SideBetWinnings(stake, ratio int) int {
+	return blackjackWinnings(stake, float64(ratio))
+}
+
+// ratioScale is the precision payout ratios are rounded to before settling,
+// so 1.2 is exactly 6:5 rather than the nearest float
+const ratioScale = 1000
+
+// blackjackWinnings returns the total returned for a bet paid at ratio,
+// including the bet. It is the single place winnings are computed, using
+// integer math so a ratio that isn't exact in floating point can't shave a
+// chip off some bets. Fractional chips are rounded down.
+func blackjackWinnings(bet int, ratio float64) int {
+	scaled := int(math.Round(ratio * ratioScale))
+	return bet + bet*scaled/ratioScale
+}
+
+// EffectivePayouts returns the game's payout table, filling in defaults for
+// games saved before payouts were configurable. Games saved while side bet
+// payouts were kept apart still use theirs.
+func (g *BlackjackGame) EffectivePayouts() PayoutTable {
+	payouts := g.Payouts
+	payouts.SideBets = payouts.SideBets.WithDefaults(g.SideBetPayouts)
+	return payouts.WithDefaults(DefaultPayouts)
+}
+
+// MaxWinnings returns the most a bet on the main hand can return, including
+// the original bet
+func (p PayoutTable) MaxWinnings(bet int) int {
+	return max(p.BlackjackWinnings(bet), p.WinWinnings(bet), p.CharlieWinnings(bet))
+}
+
+// PotentialPayout returns the most the house could pay out if every bet on
+// the table won at the best multiplier, including first-round bonus bets and
+// side bets
+func (g *BlackjackGame) PotentialPayout() int {
+	payouts := g.EffectivePayouts()
+
+	total := 0
+	for _, p := range g.Players {
+		if p.SitsOutRound() {
+			continue
+		}
+
+		total += payouts.MaxWinnings(p.Bet)
+		for _, sb := range p.SideBets {
+			total += payouts.SideBetWinnings(sb.Amount, payouts.SideBets.best())
+		}
+		if g.BonusEnabled && p.RoundsPlayed == 0 {
+			// Bonus stakes are the house's, so only the profit is paid
+			total += payouts.MaxWinnings(g.bonusAmount()) - g.bonusAmount()
+		}
+	}
+	return total
+}
+
+// ExceedsPayoutCap reports whether the bets on the table could pay out more
+// than the table's MaxRoundPayout
+func (g *BlackjackGame) ExceedsPayoutCap() bool {
+	return g.MaxRoundPayout > 0 && g.PotentialPayout() > g.MaxRoundPayout
+}
+
+// RoundNet returns how much a player gained (positive) or lost (negative) in
+// a completed round, across every hand and any bonus bet
+func (g *BlackjackGame) RoundNet(p Player) int {
+	net := 0
+	for _, result := range g.SettleResults() {
+		if result.PlayerID == p.ID {
+			net += result.Net
+		}
+	}
+	return net
+}
+
+// HouseNet returns the house's result for a completed round, the inverse of
+// every player's net
+func (g *BlackjackGame) HouseNet() int {
+	net := 0
+	for _, result := range g.SettleResults() {
+		net -= result.Net
+	}
+	return net
+}
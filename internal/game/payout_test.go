@@ -0,0 +1,236 @@
+package game
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPayoutTableWinnings(t *testing.T) {
+	tests := []struct {
+		name     string
+		winnings func() int
+		want     int
+	}{
+		{"vegas blackjack", func() int { return VegasPayouts.BlackjackWinnings(10) }, 25},
+		{"six-five blackjack", func() int { return SixFivePayouts.BlackjackWinnings(10) }, 22},
+		{"six-five blackjack rounds down", func() int { return SixFivePayouts.BlackjackWinnings(7) }, 15},
+		{"win", func() int { return VegasPayouts.WinWinnings(10) }, 20},
+		{"charlie", func() int { return VegasPayouts.CharlieWinnings(10) }, 20},
+		{"charlie at 2:1", func() int { return PayoutTable{Charlie: 2}.CharlieWinnings(10) }, 30},
+		{"surrender", func() int { return VegasPayouts.SurrenderRefund(10) }, 5},
+		{"surrender rounds down", func() int { return VegasPayouts.SurrenderRefund(11) }, 5},
+		{"side bet", func() int { return VegasPayouts.SideBetWinnings(10, 25) }, 260},
+		{"max is the best of blackjack, win and charlie", func() int { return PayoutTable{Blackjack: 1.5, Win: 1, Charlie: 3}.MaxWinnings(10) }, 40},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.winnings(); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPayoutPreset(t *testing.T) {
+	tests := []struct {
+		name string
+		want PayoutTable
+		ok   bool
+	}{
+		{"vegas", VegasPayouts, true},
+		{"Vegas", VegasPayouts, true},
+		{"sixFive", SixFivePayouts, true},
+		{"6:5", SixFivePayouts, true},
+		{"atlantic", PayoutTable{}, false},
+		{"", PayoutTable{}, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := PayoutPreset(tt.name)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("PayoutPreset(%q) = %+v, %v, want %+v, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestPayoutTableWithDefaults(t *testing.T) {
+	got := PayoutTable{Blackjack: 2, SideBets: SideBetPayouts{Flush: 7}}.WithDefaults(VegasPayouts)
+
+	want := VegasPayouts
+	want.Blackjack = 2
+	want.SideBets.Flush = 7
+	if got != want {
+		t.Errorf("WithDefaults = %+v, want %+v", got, want)
+	}
+}
+
+func TestEffectivePayoutsKeepsSavedSideBetPayouts(t *testing.T) {
+	g := NewBlackjackGame("table", 10, 1000, 1)
+	g.Payouts = PayoutTable{}
+	g.SideBetPayouts = SideBetPayouts{PerfectPair: 30}
+
+	payouts := g.EffectivePayouts()
+	if payouts.SideBets.PerfectPair != 30 {
+		t.Errorf("perfect pair pays %d, want the saved 30", payouts.SideBets.PerfectPair)
+	}
+	if payouts.SideBets.Flush != DefaultSideBetPayouts.Flush || payouts.Blackjack != DefaultPayouts.Blackjack {
+		t.Errorf("unset payouts weren't defaulted: %+v", payouts)
+	}
+}
+
+func TestHandResult(t *testing.T) {
+	card := func(rank Rank) Card { return Card{Suit: Hearts, Rank: rank, Face: true} }
+	dealer20 := []Card{card(King), card(Queen)}
+	dealerBlackjack := []Card{card(Ace), card(King)}
+	dealerBust := []Card{card(King), card(Six), card(Nine)}
+
+	tests := []struct {
+		name         string
+		payouts      PayoutTable
+		dealer       []Card
+		hand         Hand
+		wantOutcome  string
+		wantWinnings int
+	}{
+		{"win", VegasPayouts, dealer20, Hand{Status: PlayerStood, Score: 21, Bet: 10}, "win", 20},
+		{"dealer bust", VegasPayouts, dealerBust, Hand{Status: PlayerStood, Score: 12, Bet: 10}, "win", 20},
+		{"push", VegasPayouts, dealer20, Hand{Status: PlayerStood, Score: 20, Bet: 10}, "push", 10},
+		{"lose", VegasPayouts, dealer20, Hand{Status: PlayerStood, Score: 18, Bet: 10}, "lose", 0},
+		{"bust", VegasPayouts, dealerBust, Hand{Status: PlayerBusted, Score: 25, Bet: 10}, "lose", 0},
+		{"blackjack 3:2", VegasPayouts, dealer20, Hand{Status: PlayerBlackjack, Score: 21, Bet: 10}, "blackjack", 25},
+		{"blackjack 6:5", SixFivePayouts, dealer20, Hand{Status: PlayerBlackjack, Score: 21, Bet: 10}, "blackjack", 22},
+		{"blackjack against blackjack", VegasPayouts, dealerBlackjack, Hand{Status: PlayerBlackjack, Score: 21, Bet: 10}, "push", 10},
+		{"surrender", VegasPayouts, dealer20, Hand{Status: PlayerSurrendered, Score: 16, Bet: 10}, "surrender", 5},
+		{"charlie beats 21", VegasPayouts, []Card{card(Ace), card(King)}, Hand{Status: PlayerCharlie, Score: 20, Bet: 10}, "win", 20},
+		{"charlie at 2:1", PayoutTable{Charlie: 2}, dealer20, Hand{Status: PlayerCharlie, Score: 19, Bet: 10}, "win", 30},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewBlackjackGame("table", 10, 1000, 1)
+			g.Payouts = tt.payouts
+			g.Dealer.Hand = tt.dealer
+			g.Dealer.Score = g.CalculateHandScore(tt.dealer)
+
+			outcome, winnings := g.HandResult(tt.hand)
+			if outcome != tt.wantOutcome || winnings != tt.wantWinnings {
+				t.Errorf("HandResult = %q, %d, want %q, %d", outcome, winnings, tt.wantOutcome, tt.wantWinnings)
+			}
+		})
+	}
+}
+
+func TestStartRespectsPayoutCap(t *testing.T) {
+	tests := []struct {
+		name string
+		cap  int
+		want error
+	}{
+		// Two bets of 100 could each pay 250 on a blackjack
+		{"no cap", 0, nil},
+		{"cap covers the bets", 500, nil},
+		{"bets exceed the cap", 499, ErrPayoutCap},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame("p1", "p2")
+			g.MaxRoundPayout = tt.cap
+			g.OpenBetting()
+			for _, p := range g.Players {
+				if err := g.PlaceBet(p.ID, 100); err != nil {
+					t.Fatalf("PlaceBet(%s): %v", p.ID, err)
+				}
+			}
+
+			if err := g.Start(); !errors.Is(err, tt.want) {
+				t.Fatalf("Start = %v, want %v", err, tt.want)
+			}
+			if tt.want != nil && g.Status != Betting {
+				t.Errorf("status = %s, want betting to carry on", g.Status)
+			}
+			if state := g.GetGameState("p1"); state["maxRoundPayout"] != tt.cap {
+				t.Errorf("state shows a cap of %v, want %d", state["maxRoundPayout"], tt.cap)
+			}
+		})
+	}
+}
+
+func TestHouseNetMirrorsPlayers(t *testing.T) {
+	g := newTestGame("p1", "p2")
+	g.Deck = NewSeededDeck(7)
+	// The first round's house-funded bonus bets count as well
+	g.BonusEnabled = true
+
+	house := 0
+	for round := 1; round <= 8; round++ {
+		dealRound(t, g)
+		for g.Status == InProgress {
+			p := g.Players[g.CurrentPlayerIndex]
+			if p.Score < 15 {
+				g.Hit(p.ID)
+			} else {
+				g.Stand(p.ID)
+			}
+		}
+
+		roundNet := 0
+		for _, p := range g.Players {
+			roundNet += g.RoundNet(p)
+		}
+		if g.HouseNet() != -roundNet {
+			t.Errorf("round %d: house net %d, players net %d", round, g.HouseNet(), roundNet)
+		}
+		house += g.HouseNet()
+		g.PrepareForNextRound()
+	}
+
+	players := 0
+	for _, p := range g.Players {
+		players += p.Balance - 1000
+	}
+	if house != -players {
+		t.Errorf("house made %d over the rounds, players %d, want them to mirror", house, players)
+	}
+}
+
+func TestBlackjackPayoutRatios(t *testing.T) {
+	tests := []struct {
+		name     string
+		payouts  PayoutTable
+		bet      int
+		winnings int
+	}{
+		{"3:2 on 10", VegasPayouts, 10, 25},
+		{"3:2 on 15", VegasPayouts, 15, 37},
+		{"6:5 on 10", SixFivePayouts, 10, 22},
+		{"6:5 on 15", SixFivePayouts, 15, 33},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := blackjackWinnings(tt.bet, tt.payouts.Blackjack); got != tt.winnings {
+				t.Errorf("blackjackWinnings = %d, want %d", got, tt.winnings)
+			}
+
+			// A natural is paid the same when the round settles
+			g := newTestGame("p1")
+			g.Payouts = tt.payouts
+			g.OpenBetting()
+			if err := g.PlaceBet("p1", tt.bet); err != nil {
+				t.Fatalf("PlaceBet: %v", err)
+			}
+			stackDeck(t, g, "AH", "KS", "9D", "8C")
+			if err := g.Start(); err != nil {
+				t.Fatalf("Start: %v", err)
+			}
+			if g.Status != Completed {
+				t.Fatalf("status = %s, want the natural to settle the round", g.Status)
+			}
+			if got := g.Players[0].Balance; got != 1000-tt.bet+tt.winnings {
+				t.Errorf("balance = %d, want %d", got, 1000-tt.bet+tt.winnings)
+			}
+		})
+	}
+}
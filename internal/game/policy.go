@@ -0,0 +1,160 @@
+package game
+
+import "time"
+
+// AutoManaged reports whether the table opens betting and deals by itself
+// according to its policy rather than waiting for explicit requests
+func (g *BlackjackGame) AutoManaged() bool {
+	return g.AutoStartMinPlayers > 0
+}
+
+// HasBettingClock reports whether betting closes by itself, either under the
+// table's auto-start policy or a BettingTimeout
+func (g *BlackjackGame) HasBettingClock() bool {
+	return g.AutoManaged() || g.BettingTimeout > 0
+}
+
+// AdvancePolicy moves a table on to its next phase once it is due, returning
+// true if the game changed. It is driven by a periodic sweep:
+//
+//   - Waiting: an auto-managed table opens betting once AutoStartMinPlayers
+//     are seated
+//   - Betting: with StartWhenReady the round is dealt as soon as every seated
+//     player has bet (and is ready, if required). Otherwise it is dealt when
+//     the betting window (BettingWindowMs, or BettingTimeout on tables without
+//     an auto-start policy) closes, and players who haven't bet sit the round
+//     out. If nobody, or fewer than AutoStartMinPlayers, has bet by then, bets
+//     are refunded and the table goes back to waiting.
+//
+// Without a betting window the round is only dealt early or by request.
+func (g *BlackjackGame) AdvancePolicy(now time.Time) bool {
+	switch g.Status {
+	case Waiting:
+		if !g.AutoManaged() || len(g.Players) < g.AutoStartMinPlayers || !g.OpenBetting() {
+			return false
+		}
+		g.PhaseDeadline = g.bettingDeadline(now)
+		return true
+
+	case Betting:
+		if !g.HasBettingClock() {
+			return false
+		}
+
+		// Betting opened before the table had a deadline to keep
+		if g.PhaseDeadline.IsZero() && g.bettingWindow() > 0 {
+			g.PhaseDeadline = g.bettingDeadline(now)
+			return true
+		}
+
+		bettors := g.countBettors()
+		if g.StartWhenReady && bettors == len(g.Players) && bettors >= g.AutoStartMinPlayers {
+			return g.startAutoRound()
+		}
+
+		if g.PhaseDeadline.IsZero() || now.Before(g.PhaseDeadline) {
+			return false
+		}
+		if bettors < g.AutoStartMinPlayers || bettors == 0 {
+			g.returnToWaiting()
+			return true
+		}
+
+		g.sitOutNonBettors()
+		return g.startAutoRound()
+	}
+
+	return false
+}
+
+// bettingWindow returns how long betting stays open, zero if it doesn't
+// close by itself
+func (g *BlackjackGame) bettingWindow() time.Duration {
+	if g.AutoManaged() && g.BettingWindowMs > 0 {
+		return time.Duration(g.BettingWindowMs) * time.Millisecond
+	}
+	return g.BettingTimeout
+}
+
+// bettingDeadline returns when a betting window opened now closes, or the
+// zero time if the table has no window
+func (g *BlackjackGame) bettingDeadline(now time.Time) time.Time {
+	if g.bettingWindow() <= 0 {
+		return time.Time{}
+	}
+	return now.Add(g.bettingWindow())
+}
+
+// countBettors returns how many players have bet and are ready to be dealt
+func (g *BlackjackGame) countBettors() int {
+	count := 0
+	for _, p := range g.Players {
+		if p.Bet > 0 && (!g.RequireReady || p.Ready) {
+			count++
+		}
+	}
+	return count
+}
+
+// sitOutNonBettors marks players who haven't bet (or aren't ready) as sitting
+// out the round
+func (g *BlackjackGame) sitOutNonBettors() {
+	for i, p := range g.Players {
+		if p.Bet == 0 || (g.RequireReady && !p.Ready) {
+			g.sitOut(i)
+		}
+	}
+}
+
+// sitOut has the player in seat i sit the round out
+func (g *BlackjackGame) sitOut(i int) {
+	g.Players[i].Status = PlayerSittingOut
+	g.record(Action{Type: ActionSitOut, PlayerID: g.Players[i].ID, Seat: g.Players[i].Seat})
+}
+
+// startAutoRound deals the round, falling back to waiting if it can't be
+// dealt so the table doesn't stay stuck on an expired deadline
+func (g *BlackjackGame) startAutoRound() bool {
+	if g.Start() != nil {
+		g.returnToWaiting()
+		return true
+	}
+
+	g.emit(Event{Type: EventBettingClosed, Dealt: true})
+	return true
+}
+
+// returnToWaiting refunds any bets and takes the table back to waiting for
+// players
+func (g *BlackjackGame) returnToWaiting() {
+	for i, p := range g.Players {
+		if p.Bet > 0 {
+			g.adjustBalance(i, p.Bet)
+		}
+		g.refundSideBets(i)
+		g.Players[i].Bet = 0
+		g.Players[i].Ready = false
+		g.Players[i].Status = PlayerActive
+	}
+
+	g.Status = Waiting
+	g.PhaseDeadline = time.Time{}
+	g.UpdatedAt = time.Now()
+	g.record(Action{Type: ActionReturnToWaiting})
+	g.emit(Event{Type: EventBettingClosed})
+}
+
+// policyState returns the table policy and the current phase deadline, if any
+func (g *BlackjackGame) policyState() map[string]interface{} {
+	state := map[string]interface{}{
+		"autoStartMinPlayers": g.AutoStartMinPlayers,
+		"bettingWindowMs":     g.BettingWindowMs,
+		"startWhenReady":      g.StartWhenReady,
+		"bettingTimeoutMs":    g.BettingTimeout.Milliseconds(),
+		"phase":               g.Status,
+	}
+	if !g.PhaseDeadline.IsZero() {
+		state["deadline"] = g.PhaseDeadline.Format(time.RFC3339Nano)
+	}
+	return state
+}
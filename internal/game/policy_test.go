@@ -0,0 +1,110 @@
+package game
+
+import (
+	"testing"
+	"time"
+)
+
+// newPolicyGame seats the players at a table that opens betting by itself
+// once two are seated, with a ten second betting window. Nobody is dealt a
+// blackjack, so a dealt round stays in progress.
+func newPolicyGame(t *testing.T, playerIDs ...string) *BlackjackGame {
+	t.Helper()
+
+	g := newTestGame(playerIDs...)
+	g.AutoStartMinPlayers = 2
+	g.BettingWindowMs = 10000
+	stackDeck(t, g, "10H", "6D", "10S", "7C", "10C", "5S", "9D", "8H")
+	return g
+}
+
+func TestAdvancePolicyDealsWhenWindowCloses(t *testing.T) {
+	now := time.Now()
+	g := newPolicyGame(t, "p1", "p2", "p3")
+
+	if !g.AdvancePolicy(now) || g.Status != Betting {
+		t.Fatalf("status = %s, want betting opened for the seated players", g.Status)
+	}
+	policy := g.GetGameState("p1")["policy"].(map[string]interface{})
+	if policy["phase"] != Betting || policy["deadline"] != now.Add(10*time.Second).Format(time.RFC3339Nano) {
+		t.Errorf("policy state = %v, want betting with the window's deadline", policy)
+	}
+
+	g.PlaceBet("p1", 10)
+	g.PlaceBet("p2", 10)
+	if g.AdvancePolicy(now.Add(5 * time.Second)) {
+		t.Fatal("the round was dealt before the window closed")
+	}
+
+	// p3 didn't bet in time, so sits the round out
+	if !g.AdvancePolicy(now.Add(11*time.Second)) || g.Status != InProgress {
+		t.Fatalf("status = %s, want the round dealt once the window closed", g.Status)
+	}
+	for _, p := range g.Players {
+		if sitting := p.Status == PlayerSittingOut; sitting != (p.ID == "p3") {
+			t.Errorf("%s sitting out = %v", p.ID, sitting)
+		}
+	}
+}
+
+func TestAdvancePolicyStartsWhenReady(t *testing.T) {
+	now := time.Now()
+	g := newPolicyGame(t, "p1", "p2")
+	g.StartWhenReady = true
+	g.AdvancePolicy(now)
+
+	g.PlaceBet("p1", 10)
+	if g.AdvancePolicy(now.Add(time.Second)) {
+		t.Fatal("the round was dealt before everyone had bet")
+	}
+	g.PlaceBet("p2", 10)
+	if !g.AdvancePolicy(now.Add(time.Second)) || g.Status != InProgress {
+		t.Errorf("status = %s, want the round dealt as soon as everyone bet", g.Status)
+	}
+}
+
+func TestAdvancePolicyEmptyWindow(t *testing.T) {
+	tests := []struct {
+		name    string
+		bettors []string
+	}{
+		{"nobody bet", nil},
+		{"too few bet", []string{"p1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			g := newPolicyGame(t, "p1", "p2")
+			g.AdvancePolicy(now)
+			for _, id := range tt.bettors {
+				g.PlaceBet(id, 10)
+			}
+
+			if !g.AdvancePolicy(now.Add(11*time.Second)) || g.Status != Waiting {
+				t.Fatalf("status = %s, want the table back to waiting", g.Status)
+			}
+			if !g.PhaseDeadline.IsZero() {
+				t.Errorf("deadline = %v, want it cleared", g.PhaseDeadline)
+			}
+			for _, p := range g.Players {
+				if p.Bet != 0 || p.Balance != 1000 {
+					t.Errorf("%s has a bet of %d and %d, want the bet refunded to 1000", p.ID, p.Bet, p.Balance)
+				}
+			}
+		})
+	}
+}
+
+func TestAdvancePolicyWaitsForPlayers(t *testing.T) {
+	g := newPolicyGame(t, "p1")
+	if g.AdvancePolicy(time.Now()) || g.Status != Waiting {
+		t.Errorf("status = %s, want the table waiting for a second player", g.Status)
+	}
+
+	// Tables without a policy are left alone
+	manual := newTestGame("p1", "p2")
+	if manual.AdvancePolicy(time.Now()) {
+		t.Error("a manual table opened betting by itself")
+	}
+}
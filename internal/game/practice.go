@@ -0,0 +1,33 @@
+package game
+
+import "time"
+
+// UndoLastAction takes back the last hit on a single-player practice table,
+// putting the card back on top of the shoe. The game is rebuilt from its
+// action log without the hit, so anything the hit set off, such as a bust
+// ending the round, is undone with it. It returns false if the last action
+// wasn't a hit or the table doesn't allow undoing.
+func (g *BlackjackGame) UndoLastAction() bool {
+	if !g.Practice || len(g.Players) != 1 {
+		return false
+	}
+
+	last := len(g.Actions) - 1
+	if last < 1 || g.Actions[last].Type != ActionHit {
+		return false
+	}
+
+	r, err := g.replayLog(g.Actions[:last])
+	if err != nil {
+		return false
+	}
+
+	// Keep anything still waiting to be published
+	events := g.events
+	*g = *r
+	g.events = events
+
+	g.restartTurnClock()
+	g.UpdatedAt = time.Now()
+	return true
+}
@@ -0,0 +1,118 @@
+package game
+
+import (
+	"reflect"
+	"testing"
+)
+
+// hitUntilBust deals rounds at a lone player's table, hitting every hand
+// until a hit busts it. It returns the player and the number of cards left
+// in the shoe as they were just before that hit.
+func hitUntilBust(t *testing.T, g *BlackjackGame) (Player, int) {
+	t.Helper()
+
+	for round := 0; round < 50; round++ {
+		if g.Status == Completed {
+			g.PrepareForNextRound()
+		}
+		dealRound(t, g)
+
+		for g.Status == InProgress {
+			before := g.Players[0]
+			before.Hand = append([]Card(nil), before.Hand...)
+			remaining := len(g.Deck.Cards)
+
+			if _, ok := g.Hit("p1"); !ok {
+				t.Fatal("Hit failed")
+			}
+			if g.Players[0].Status == PlayerBusted {
+				return before, remaining
+			}
+		}
+	}
+	t.Fatal("no hit busted in 50 rounds")
+	return Player{}, 0
+}
+
+func TestUndoHitThatBusted(t *testing.T) {
+	g := newTestGame("p1")
+	g.Practice = true
+	want, remaining := hitUntilBust(t, g)
+	bustCard := g.Players[0].Hand[len(g.Players[0].Hand)-1]
+	if g.Status != Completed {
+		t.Fatalf("status = %s after the bust, want the round over", g.Status)
+	}
+
+	if !g.UndoLastAction() {
+		t.Fatal("UndoLastAction refused the hit")
+	}
+
+	p := g.Players[0]
+	if g.Status != InProgress || p.Status != PlayerActive {
+		t.Errorf("status %s with p1 %s, want the round back in progress", g.Status, p.Status)
+	}
+	if !reflect.DeepEqual(p.Hand, want.Hand) || p.Score != want.Score {
+		t.Errorf("hand %v scoring %d, want %v scoring %d", p.Hand, p.Score, want.Hand, want.Score)
+	}
+	if p.Balance != want.Balance || p.Bet != want.Bet {
+		t.Errorf("balance %d with a bet of %d, want %d and %d", p.Balance, p.Bet, want.Balance, want.Bet)
+	}
+	if g.Dealer.Hand[1].Face {
+		t.Error("the dealer's hole card is still showing")
+	}
+
+	// The card went back on top of the shoe, so hitting again draws it
+	if len(g.Deck.Cards) != remaining {
+		t.Errorf("%d cards in the shoe, want %d", len(g.Deck.Cards), remaining)
+	}
+	if card, ok := g.Hit("p1"); !ok || card.Rank != bustCard.Rank || card.Suit != bustCard.Suit {
+		t.Errorf("hit again drew %v, want %v back", card, bustCard)
+	}
+}
+
+func TestUndoLastActionRefused(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(t *testing.T) *BlackjackGame
+	}{
+		{"not a practice table", func(t *testing.T) *BlackjackGame {
+			g := newTestGame("p1")
+			hitUntilBust(t, g)
+			return g
+		}},
+		{"more than one player", func(t *testing.T) *BlackjackGame {
+			g := newTestGame("p1", "p2")
+			g.Practice = true
+			dealRound(t, g)
+			if g.Status == InProgress {
+				g.Hit(g.Players[g.CurrentPlayerIndex].ID)
+			}
+			return g
+		}},
+		{"last action wasn't a hit", func(t *testing.T) *BlackjackGame {
+			g := newTestGame("p1")
+			g.Practice = true
+			hitUntilBust(t, g)
+			g.PrepareForNextRound()
+			return g
+		}},
+		{"nothing played", func(t *testing.T) *BlackjackGame {
+			g := newTestGame("p1")
+			g.Practice = true
+			return g
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := tt.setup(t)
+			actions := len(g.Actions)
+			if g.UndoLastAction() {
+				t.Error("UndoLastAction = true, want it refused")
+			}
+			if len(g.Actions) != actions {
+				t.Error("a refused undo changed the action log")
+			}
+		})
+	}
+}
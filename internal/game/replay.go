@@ -0,0 +1,249 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ActionType identifies an entry in a game's action log
+type ActionType string
+
+const (
+	ActionCreate          ActionType = "create"          // The game was created
+	ActionShuffle         ActionType = "shuffle"         // A shoe was shuffled with Seed
+	ActionFairness        ActionType = "fairness"        // The fairness mode was set
+	ActionJoin            ActionType = "join"            // A player sat down with Amount as their balance
+	ActionAddSeat         ActionType = "addSeat"         // A player took another seat
+	ActionBalance         ActionType = "balance"         // A player's balance was replaced with Amount
+	ActionLeave           ActionType = "leave"           // A player left the table
+	ActionKick            ActionType = "kick"            // A player was removed from the table
+	ActionOpenBetting     ActionType = "openBetting"     // Betting opened
+	ActionBet             ActionType = "bet"             // A player bet Amount on Seat
+	ActionUndoBet         ActionType = "undoBet"         // A player took back their bet
+	ActionSideBet         ActionType = "sideBet"         // A player placed a side bet of Amount
+	ActionReady           ActionType = "ready"           // A player confirmed they're ready
+	ActionUnready         ActionType = "unready"         // A player retracted their readiness
+	ActionSitOut          ActionType = "sitOut"          // A player's Seat sits the round out, having not bet in time
+	ActionReturnToWaiting ActionType = "returnToWaiting" // Betting closed without a deal
+	ActionDeal            ActionType = "deal"            // The round was dealt
+	ActionHit             ActionType = "hit"             // A player drew Card
+	ActionStand           ActionType = "stand"           // A player stood
+	ActionDouble          ActionType = "double"          // A player doubled down and drew Card
+	ActionSplit           ActionType = "split"           // A player split their pair
+	ActionSurrender       ActionType = "surrender"       // A player surrendered their hand
+	ActionForceDealer     ActionType = "forceDealer"     // The dealer's turn was forced
+	ActionNextRound       ActionType = "nextRound"       // The table moved on to the next round
+)
+
+// Replay errors
+var (
+	ErrReplayNoCreate = errors.New("action log doesn't start with the game's creation")
+	ErrReplayDiverged = errors.New("replay diverged from the recorded game")
+)
+
+// Action is an entry in a game's action log. Together with the shuffle
+// seeds it records, the log is enough to rebuild the game move by move.
+type Action struct {
+	Type      ActionType   `json:"type"`
+	PlayerID  string       `json:"playerId,omitempty"`
+	Name      string       `json:"name,omitempty"`
+	Seat      int          `json:"seat,omitempty"`
+	Amount    int          `json:"amount,omitempty"`
+	SideBet   SideBetType  `json:"sideBet,omitempty"`
+	Card      *Card        `json:"card,omitempty"`
+	Seed      int64        `json:"seed,omitempty"`
+	Mode      FairnessMode `json:"mode,omitempty"`
+	Setup     *TableSetup  `json:"setup,omitempty"`
+	Timestamp time.Time    `json:"timestamp"`
+}
+
+// TableSetup is what a game was created with
+type TableSetup struct {
+	GameID   string `json:"gameId"`
+	TableID  string `json:"tableId"`
+	MinBet   int    `json:"minBet"`
+	MaxBet   int    `json:"maxBet"`
+	NumDecks int    `json:"numDecks"`
+}
+
+// record appends an action to the game's log
+func (g *BlackjackGame) record(action Action) {
+	action.Timestamp = time.Now()
+	g.Actions = append(g.Actions, action)
+}
+
+// nextReplaySeed returns the recorded seed to shuffle with while replaying,
+// or seed otherwise
+func (g *BlackjackGame) nextReplaySeed(seed int64) int64 {
+	if len(g.replaySeeds) == 0 {
+		return seed
+	}
+	seed = g.replaySeeds[0]
+	g.replaySeeds = g.replaySeeds[1:]
+	return seed
+}
+
+// ReplayGame rebuilds a game from its action log, starting from a shoe
+// shuffled with seed. Later shoes are shuffled with the seeds the log
+// recorded. The game is replayed under the standard house rules; use
+// BlackjackGame.Replay to replay a table with its own rules.
+func ReplayGame(seed int64, actions []Action) (*BlackjackGame, error) {
+	if len(actions) == 0 || actions[0].Type != ActionCreate || actions[0].Setup == nil {
+		return nil, ErrReplayNoCreate
+	}
+	return replay(newReplayGame(actions[0]), seed, actions)
+}
+
+// Replay rebuilds the game from its own action log under the same table
+// rules, so the result can be compared with the game as it stands
+func (g *BlackjackGame) Replay() (*BlackjackGame, error) {
+	return g.replayLog(g.Actions)
+}
+
+// replayLog rebuilds the game from actions under the same table rules
+func (g *BlackjackGame) replayLog(actions []Action) (*BlackjackGame, error) {
+	if len(actions) == 0 || actions[0].Type != ActionCreate || actions[0].Setup == nil {
+		return nil, ErrReplayNoCreate
+	}
+
+	var seed int64
+	for _, a := range actions {
+		if a.Type == ActionShuffle {
+			seed = a.Seed
+			break
+		}
+	}
+
+	r := newReplayGame(actions[0])
+	copyRules(r, g)
+	return replay(r, seed, actions)
+}
+
+// newReplayGame creates an empty game matching a create action
+func newReplayGame(create Action) *BlackjackGame {
+	setup := create.Setup
+	g := NewBlackjackGame(setup.TableID, setup.MinBet, setup.MaxBet, setup.NumDecks)
+	g.ID = setup.GameID
+	g.CreatedAt = create.Timestamp
+	return g
+}
+
+// copyRules copies the table rules from src to dst
+func copyRules(dst, src *BlackjackGame) {
+	dst.MaxPlayers = src.MaxPlayers
+	dst.RuleSet = src.RuleSet
+	dst.SideBetPayouts = src.SideBetPayouts
+	dst.RequireReady = src.RequireReady
+	dst.ShowComposition = src.ShowComposition
+	dst.ValueOverrides = src.ValueOverrides
+	dst.RevealHandsAtShowdown = src.RevealHandsAtShowdown
+	dst.Ranked = src.Ranked
+	dst.Practice = src.Practice
+	dst.BonusEnabled = src.BonusEnabled
+	dst.BonusAmount = src.BonusAmount
+	dst.DealerPeek = src.DealerPeek
+	dst.FiveCardCharlie = src.FiveCardCharlie
+	dst.MaxRoundPayout = src.MaxRoundPayout
+	dst.AutoStartMinPlayers = src.AutoStartMinPlayers
+	dst.BettingWindowMs = src.BettingWindowMs
+	dst.StartWhenReady = src.StartWhenReady
+	dst.TurnTimeout = src.TurnTimeout
+	dst.BettingTimeout = src.BettingTimeout
+}
+
+// replay plays the logged actions onto a freshly created game
+func replay(g *BlackjackGame, seed int64, actions []Action) (*BlackjackGame, error) {
+	// The first shoe uses the given seed and later ones the recorded seeds,
+	// in the order they were shuffled
+	g.replaySeeds = []int64{seed}
+	firstShuffle := true
+	for _, a := range actions {
+		if a.Type != ActionShuffle {
+			continue
+		}
+		if firstShuffle {
+			firstShuffle = false
+			continue
+		}
+		g.replaySeeds = append(g.replaySeeds, a.Seed)
+	}
+
+	g.Actions = actions[:1:1]
+	g.newShoe()
+
+	for i, a := range actions[1:] {
+		if !g.apply(a) {
+			return nil, fmt.Errorf("action %d (%s): %w", i+1, a.Type, ErrReplayDiverged)
+		}
+	}
+
+	g.replaySeeds = nil
+	g.DrainEvents()
+	return g, nil
+}
+
+// apply replays one logged action, returning false if it can't be applied
+// or drew a different card than the one recorded
+func (g *BlackjackGame) apply(a Action) bool {
+	sameCard := func(card Card, ok bool) bool {
+		return ok && (a.Card == nil || (card.Suit == a.Card.Suit && card.Rank == a.Card.Rank))
+	}
+
+	switch a.Type {
+	case ActionShuffle:
+		// Shuffles happen by themselves as the game is replayed
+		return true
+	case ActionFairness:
+		return g.SetFairness(a.Mode, a.Seed) == nil
+	case ActionJoin:
+		return g.AddPlayer(a.PlayerID, a.Name, a.Amount) != nil
+	case ActionAddSeat:
+		return g.AddSeat(a.PlayerID)
+	case ActionBalance:
+		return g.SetBalance(a.PlayerID, a.Amount)
+	case ActionLeave:
+		return g.RemovePlayer(a.PlayerID)
+	case ActionKick:
+		_, ok := g.KickPlayer(a.PlayerID)
+		return ok
+	case ActionOpenBetting:
+		return g.OpenBetting()
+	case ActionBet:
+		return g.PlaceSeatBet(a.PlayerID, a.Seat, a.Amount) == nil
+	case ActionUndoBet:
+		return g.UndoBet(a.PlayerID)
+	case ActionSideBet:
+		return g.PlaceSideBet(a.PlayerID, a.SideBet, a.Amount) == nil
+	case ActionReady, ActionUnready:
+		return g.SetReady(a.PlayerID, a.Type == ActionReady)
+	case ActionSitOut:
+		i := g.seatIndex(a.PlayerID, a.Seat)
+		if i < 0 {
+			return false
+		}
+		g.sitOut(i)
+		return true
+	case ActionReturnToWaiting:
+		g.returnToWaiting()
+		return true
+	case ActionDeal:
+		return g.Start() == nil
+	case ActionHit:
+		return sameCard(g.Hit(a.PlayerID))
+	case ActionStand:
+		return g.Stand(a.PlayerID)
+	case ActionDouble:
+		return sameCard(g.DoubleDown(a.PlayerID))
+	case ActionSplit:
+		return g.Split(a.PlayerID)
+	case ActionSurrender:
+		return g.Surrender(a.PlayerID)
+	case ActionForceDealer:
+		return g.ForceDealerTurn()
+	case ActionNextRound:
+		g.PrepareForNextRound()
+		return true
+	}
+	return false
+}
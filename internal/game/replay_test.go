@@ -0,0 +1,195 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// playByTheBook plays rounds at the table, every seat betting 10 and playing
+// each hand by basic strategy
+func playByTheBook(t *testing.T, g *BlackjackGame, rounds int) {
+	t.Helper()
+
+	for round := 0; round < rounds; round++ {
+		if g.Status == Completed {
+			g.PrepareForNextRound()
+		}
+		if g.Status == Waiting && !g.OpenBetting() {
+			t.Fatalf("round %d: OpenBetting failed", round+1)
+		}
+		for _, p := range g.Players {
+			if err := g.PlaceSeatBet(p.ID, p.Seat, 10); err != nil {
+				t.Fatalf("round %d: PlaceSeatBet(%s): %v", round+1, p.ID, err)
+			}
+		}
+		if err := g.Start(); err != nil {
+			t.Fatalf("round %d: Start: %v", round+1, err)
+		}
+
+		for g.Status == InProgress {
+			id := g.Players[g.CurrentPlayerIndex].ID
+			play, ok := g.Advice(id)
+			if !ok {
+				t.Fatalf("round %d: no advice for %s on their turn", round+1, id)
+			}
+
+			switch play {
+			case PlayHit:
+				_, ok = g.Hit(id)
+			case PlayStand:
+				ok = g.Stand(id)
+			case PlayDouble:
+				_, ok = g.DoubleDown(id)
+			case PlaySplit:
+				ok = g.Split(id)
+			case PlaySurrender:
+				ok = g.Surrender(id)
+			}
+			if !ok {
+				t.Fatalf("round %d: %s couldn't %s", round+1, id, play)
+			}
+		}
+	}
+}
+
+// finalState is what a replay has to reproduce: the table, every hand and
+// balance, and the cards left in the shoe
+func finalState(t *testing.T, g *BlackjackGame) string {
+	t.Helper()
+
+	data, err := json.Marshal(struct {
+		Status             GameStatus
+		Players            []Player
+		Dealer             Dealer
+		Deck               *Deck
+		CurrentPlayerIndex int
+		Seed               int64
+	}{g.Status, g.Players, g.Dealer, g.Deck, g.CurrentPlayerIndex, g.Seed})
+	if err != nil {
+		t.Fatalf("marshal state: %v", err)
+	}
+	return string(data)
+}
+
+// firstSeed returns the seed the game's first shoe was shuffled with
+func firstSeed(g *BlackjackGame) int64 {
+	for _, a := range g.Actions {
+		if a.Type == ActionShuffle {
+			return a.Seed
+		}
+	}
+	return 0
+}
+
+func TestReplayReproducesGame(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(g *BlackjackGame)
+	}{
+		{"house rules", func(g *BlackjackGame) {}},
+		{"surrender and soft 17", func(g *BlackjackGame) {
+			g.SurrenderAllowed = true
+			g.DealerHitsSoft17 = true
+			g.MaxSplits = 3
+		}},
+		{"peek and side bets", func(g *BlackjackGame) {
+			g.DealerPeek = true
+			g.BonusEnabled = true
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame("p1", "p2", "p3")
+			tt.setup(g)
+
+			// Enough rounds to run through the shoe, with the table
+			// changing between them
+			playByTheBook(t, g, 10)
+			g.PrepareForNextRound()
+			g.KickPlayer("p2")
+			g.AddPlayer("p4", "Player p4", 500)
+			playByTheBook(t, g, 15)
+
+			replayed, err := g.Replay()
+			if err != nil {
+				t.Fatalf("Replay: %v", err)
+			}
+			if got, want := finalState(t, replayed), finalState(t, g); got != want {
+				t.Errorf("replayed state differs\n got: %s\nwant: %s", got, want)
+			}
+			if len(replayed.Actions) != len(g.Actions) {
+				t.Errorf("replay logged %d actions, want %d", len(replayed.Actions), len(g.Actions))
+			}
+		})
+	}
+}
+
+func TestReplayGame(t *testing.T) {
+	g := newTestGame("p1", "p2")
+	playByTheBook(t, g, 20)
+
+	replayed, err := ReplayGame(firstSeed(g), g.Actions)
+	if err != nil {
+		t.Fatalf("ReplayGame: %v", err)
+	}
+	if got, want := finalState(t, replayed), finalState(t, g); got != want {
+		t.Errorf("replayed state differs\n got: %s\nwant: %s", got, want)
+	}
+
+	// The same log survives being saved and loaded
+	data, _ := json.Marshal(g)
+	var loaded BlackjackGame
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if replayed, err := ReplayGame(firstSeed(g), loaded.Actions); err != nil || finalState(t, replayed) != finalState(t, g) {
+		t.Errorf("replaying the saved log = %v, want the same final state", err)
+	}
+}
+
+func TestReplayGameErrors(t *testing.T) {
+	// Play until a card has been drawn
+	g := newTestGame("p1")
+	drawn := -1
+	for round := 0; drawn < 0; round++ {
+		if round == 50 {
+			t.Fatal("no card drawn in 50 rounds")
+		}
+		playByTheBook(t, g, 1)
+		for i, a := range g.Actions {
+			if a.Type == ActionHit || a.Type == ActionDouble {
+				drawn = i
+			}
+		}
+	}
+
+	// A draw of a different card than the one logged gives the game away
+	tampered := append([]Action(nil), g.Actions...)
+	card := *tampered[drawn].Card
+	card.Rank, card.Suit = Ace, Spades
+	if *tampered[drawn].Card == card {
+		card.Suit = Hearts
+	}
+	tampered[drawn].Card = &card
+
+	tests := []struct {
+		name    string
+		actions []Action
+		want    error
+	}{
+		{"empty log", nil, ErrReplayNoCreate},
+		{"missing creation", g.Actions[1:], ErrReplayNoCreate},
+		{"tampered card", tampered, ErrReplayDiverged},
+		{"unknown action", append(g.Actions[:len(g.Actions):len(g.Actions)], Action{Type: "cheat"}), ErrReplayDiverged},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReplayGame(firstSeed(g), tt.actions); !errors.Is(err, tt.want) {
+				t.Errorf("ReplayGame = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package game
+
+// allRanks lists every rank in deck order
+var allRanks = []Rank{Ace, Two, Three, Four, Five, Six, Seven, Eight, Nine, Ten, Jack, Queen, King}
+
+// BustProbability returns the probability that drawing one more card busts
+// the hand, given how many cards of each rank are still unseen
+func (g *BlackjackGame) BustProbability(hand []Card, unseen map[Rank]int) float64 {
+	total := 0
+	busting := 0
+
+	next := make([]Card, len(hand)+1)
+	copy(next, hand)
+
+	for _, rank := range allRanks {
+		count := unseen[rank]
+		if count <= 0 {
+			continue
+		}
+		total += count
+
+		next[len(hand)] = Card{Rank: rank, Face: true}
+		if g.CalculateHandScore(next) > 21 {
+			busting += count
+		}
+	}
+
+	if total == 0 {
+		return 0
+	}
+	return float64(busting) / float64(total)
+}
+
+// UnseenRankCounts returns the cards a player can't see: the rest of the
+// deck, the dealer's face-down cards and any hidden opponent hands
+func (g *BlackjackGame) UnseenRankCounts(playerID string) map[Rank]int {
+	unseen := map[Rank]int{}
+	if g.Deck != nil {
+		unseen = g.Deck.Composition()
+	}
+
+	for _, card := range g.Dealer.Hand {
+		if !card.Face {
+			unseen[card.Rank]++
+		}
+	}
+
+	// Opponent hands are only visible at showdown
+	if !(g.Status == Completed && g.RevealHandsAtShowdown) {
+		for _, p := range g.Players {
+			if p.ID == playerID {
+				continue
+			}
+			for _, hand := range p.PlayedHands() {
+				for _, card := range hand.Cards {
+					unseen[card.Rank]++
+				}
+			}
+		}
+	}
+
+	return unseen
+}
+
+// BustRisk returns a player's current score and the probability that their
+// next hit busts them, for the seat whose turn it is if they hold several.
+// It returns false if the player isn't in the game.
+func (g *BlackjackGame) BustRisk(playerID string) (int, float64, bool) {
+	i := g.actingSeat(playerID)
+	if i < 0 {
+		return 0, 0, false
+	}
+
+	p := g.Players[i]
+	return p.Score, g.BustProbability(p.Hand, g.UnseenRankCounts(playerID)), true
+}
@@ -0,0 +1,54 @@
+package game
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBustProbability(t *testing.T) {
+	tests := []struct {
+		name   string
+		hand   []string
+		unseen map[Rank]int
+		want   float64
+	}{
+		{"nothing unseen", []string{"10H", "6S"}, map[Rank]int{}, 0},
+		{"only tens left on 16", []string{"10H", "6S"}, map[Rank]int{King: 4, Ten: 2}, 1},
+		{"only small cards left on 16", []string{"10H", "6S"}, map[Rank]int{Two: 3, Five: 1}, 0},
+		{"half busting on 16", []string{"10H", "6S"}, map[Rank]int{Five: 2, Queen: 2}, 0.5},
+		{"ace never busts a soft hand", []string{"AH", "5S"}, map[Rank]int{King: 4}, 0},
+		{"ace counts one on 20", []string{"10H", "QS"}, map[Rank]int{Ace: 1, Two: 1}, 0.5},
+		{"full deck on 12", []string{"10H", "2S"}, NewDeck().Composition(), 16.0 / 52},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame()
+			got := g.BustProbability(cards(t, tt.hand...), tt.unseen)
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("BustProbability = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnseenRankCounts(t *testing.T) {
+	g := newTestGame("p1", "p2")
+	g.Deck.Cards = cards(t, "9H")
+	dealRound(t, g, "10H", "5S", "6D", "KC", "QC", "2D")
+
+	// p1 can't see p2's cards, the hole card or the remaining 9
+	unseen := g.UnseenRankCounts("p1")
+	want := map[Rank]int{Six: 1, King: 1, Two: 1, Nine: 1}
+	for _, rank := range allRanks {
+		if unseen[rank] != want[rank] {
+			t.Errorf("%s: %d unseen, want %d", rank, unseen[rank], want[rank])
+		}
+	}
+
+	// 15 busts on the king and nine, but not on the six or two
+	score, risk, ok := g.BustRisk("p1")
+	if !ok || score != 15 || risk != 0.5 {
+		t.Errorf("BustRisk = %d, %v, %v, want 15, 0.5, true", score, risk, ok)
+	}
+}
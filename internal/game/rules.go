@@ -0,0 +1,86 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultMaxSplits is how many times a player may split in a round unless
+// the table says otherwise
+const DefaultMaxSplits = 1
+
+// ErrInvalidRules is returned for a rule set that can't be played
+var ErrInvalidRules = errors.New("invalid house rules")
+
+// RuleSet holds a table's house rules
+type RuleSet struct {
+	DealerHitsSoft17 bool        `json:"dealerHitsSoft17"` // Dealer draws on a soft 17 instead of standing
+	Payouts          PayoutTable `json:"payouts"`          // Unset ratios use DefaultPayouts
+	NumDecks         int         `json:"numDecks"`         // Decks in the shoe
+	SurrenderAllowed bool        `json:"surrender"`        // Players may give up their first two cards for half their bet back
+	DoubleAfterSplit bool        `json:"doubleAfterSplit"` // Split hands may be doubled
+	MaxSplits        int         `json:"maxSplits"`        // How many times a player may split in a round (0 = no splitting)
+}
+
+// DefaultRuleSet is the rules every table plays by unless configured otherwise
+var DefaultRuleSet = RuleSet{
+	Payouts:          DefaultPayouts,
+	NumDecks:         DefaultNumDecks,
+	DoubleAfterSplit: true,
+	MaxSplits:        DefaultMaxSplits,
+}
+
+// Validate checks that the rules can be played
+func (r RuleSet) Validate() error {
+	if r.MaxSplits < 0 {
+		return fmt.Errorf("%w: maxSplits must not be negative", ErrInvalidRules)
+	}
+	return nil
+}
+
+// Rules returns the table's house rules
+func (g *BlackjackGame) Rules() RuleSet {
+	return g.RuleSet
+}
+
+// Surrender gives up the current player's hand for half their bet back. It
+// is only allowed on tables with the Surrender rule, on the player's first
+// two cards and before they split.
+func (g *BlackjackGame) Surrender(playerID string) bool {
+	if g.Status != InProgress || !g.SurrenderAllowed {
+		return false
+	}
+
+	for i, p := range g.Players {
+		if p.ID != playerID || i != g.CurrentPlayerIndex {
+			continue
+		}
+
+		if !p.IsActive || p.Status != PlayerActive {
+			return false
+		}
+		if p.IsSplit() || len(p.Hand) != 2 {
+			return false
+		}
+
+		g.Players[i].Status = PlayerSurrendered
+		g.Players[i].IsActive = false
+		g.NextPlayer()
+
+		g.restartTurnClock()
+		g.UpdatedAt = time.Now()
+		g.record(Action{Type: ActionSurrender, PlayerID: playerID})
+		return true
+	}
+	return false
+}
+
+// splitsLeft returns how many more times a player may split this round
+func (g *BlackjackGame) splitsLeft(p Player) int {
+	used := 0
+	if p.IsSplit() {
+		used = len(p.Hands) - 1
+	}
+	return max(g.RuleSet.MaxSplits-used, 0)
+}
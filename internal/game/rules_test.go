@@ -0,0 +1,138 @@
+package game
+
+import (
+	"errors"
+	"testing"
+)
+
+// newRulesGame seats the players at a table playing by the rules
+func newRulesGame(rules RuleSet, playerIDs ...string) *BlackjackGame {
+	g := newTestGame(playerIDs...)
+	g.RuleSet = rules
+	return g
+}
+
+func TestSurrenderSettlement(t *testing.T) {
+	rules := DefaultRuleSet
+	rules.SurrenderAllowed = true
+
+	tests := []struct {
+		name   string
+		dealer []string // Dealer's two cards and any draws
+		want   int
+	}{
+		{"dealer stands", []string{"10C", "7S"}, 995},
+		{"dealer busts", []string{"10C", "6S", "KH"}, 995},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newRulesGame(rules, "p1", "p2")
+			dealRound(t, g, append([]string{"10H", "6D", "10S", "9C"}, tt.dealer...)...)
+
+			if !g.Surrender("p1") {
+				t.Fatal("Surrender refused on 16 with surrender allowed")
+			}
+			if g.Players[0].Status != PlayerSurrendered || g.Players[g.CurrentPlayerIndex].ID != "p2" {
+				t.Fatal("the turn didn't pass on after the surrender")
+			}
+			g.Stand("p2")
+
+			// Half the bet comes back whatever the dealer does
+			if g.Status != Completed || g.Players[0].Balance != tt.want {
+				t.Errorf("status %s with p1 on %d, want the round settled at %d", g.Status, g.Players[0].Balance, tt.want)
+			}
+		})
+	}
+}
+
+func TestNonDefaultRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules func(r *RuleSet)
+		deal  []string // p1's cards, the dealer's and any draws
+		play  func(g *BlackjackGame) bool
+		want  bool
+	}{
+		{"surrender off by default", func(r *RuleSet) {}, []string{"10H", "6D", "10C", "7S"},
+			func(g *BlackjackGame) bool { return g.Surrender("p1") }, false},
+		{"no surrender after hitting", func(r *RuleSet) { r.SurrenderAllowed = true }, []string{"10H", "2D", "10C", "7S", "4C"},
+			func(g *BlackjackGame) bool { g.Hit("p1"); return g.Surrender("p1") }, false},
+		{"no splitting", func(r *RuleSet) { r.MaxSplits = 0 }, []string{"8H", "8D", "10C", "7S"},
+			func(g *BlackjackGame) bool { return g.Split("p1") }, false},
+		{"double after split", func(r *RuleSet) {}, []string{"8H", "8D", "10C", "7S", "3C", "9H"},
+			func(g *BlackjackGame) bool { g.Split("p1"); _, ok := g.DoubleDown("p1"); return ok }, true},
+		{"no double after split", func(r *RuleSet) { r.DoubleAfterSplit = false }, []string{"8H", "8D", "10C", "7S", "3C", "9H"},
+			func(g *BlackjackGame) bool { g.Split("p1"); _, ok := g.DoubleDown("p1"); return ok }, false},
+		{"dealer stands on soft 17", func(r *RuleSet) {}, []string{"10H", "8D", "AC", "6S", "5H"},
+			func(g *BlackjackGame) bool { g.Stand("p1"); return len(g.Dealer.Hand) > 2 }, false},
+		{"dealer hits soft 17", func(r *RuleSet) { r.DealerHitsSoft17 = true }, []string{"10H", "8D", "AC", "6S", "5H"},
+			func(g *BlackjackGame) bool { g.Stand("p1"); return len(g.Dealer.Hand) > 2 }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := DefaultRuleSet
+			tt.rules(&rules)
+			g := newRulesGame(rules, "p1")
+			dealRound(t, g, tt.deal...)
+
+			if got := tt.play(g); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleSetValidate(t *testing.T) {
+	rules := DefaultRuleSet
+	if err := rules.Validate(); err != nil {
+		t.Errorf("default rules: %v", err)
+	}
+	rules.MaxSplits = -1
+	if err := rules.Validate(); !errors.Is(err, ErrInvalidRules) {
+		t.Errorf("negative maxSplits = %v, want %v", err, ErrInvalidRules)
+	}
+}
+
+func TestFiveCardCharlie(t *testing.T) {
+	tests := []struct {
+		name    string
+		charlie bool
+		hits    []string
+		want    PlayerStatus
+		balance int
+	}{
+		// The dealer's 20 would beat every hand here but a Charlie
+		{"five cards under 21", true, []string{"2D", "3C", "4H"}, PlayerCharlie, 1010},
+		{"five cards making 21", true, []string{"4D", "5C", "7H"}, PlayerCharlie, 1010},
+		{"fifth card busts", true, []string{"10D", "4C", "KH"}, PlayerBusted, 990},
+		{"rule turned off", false, []string{"2D", "3C", "4H"}, PlayerStood, 990},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame("p1")
+			g.FiveCardCharlie = tt.charlie
+			dealRound(t, g, append([]string{"2H", "3S", "10S", "QC"}, tt.hits...)...)
+
+			for _, code := range tt.hits {
+				if _, ok := g.Hit("p1"); !ok {
+					t.Fatalf("hit for %s failed", code)
+				}
+			}
+			// Without the rule five cards don't end the hand
+			if g.Status == InProgress {
+				g.Stand("p1")
+			}
+
+			p := g.Players[0]
+			if g.Status != Completed || p.Status != tt.want {
+				t.Fatalf("round %s with p1 %s, want it over with p1 %s", g.Status, p.Status, tt.want)
+			}
+			if p.Balance != tt.balance {
+				t.Errorf("balance = %d, want %d", p.Balance, tt.balance)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package game
+
+// PlayerResult is the settled outcome of one of a player's hands, or of their
+// bonus bet
+type PlayerResult struct {
+	PlayerID    string `json:"playerId"`
+	Seat        int    `json:"seat"` // Which of the player's seats the hand was played on
+	HandIndex   int    `json:"handIndex"`
+	Outcome     string `json:"outcome"` // "win", "blackjack", "push", "lose", "bonus" or the side bet type
+	Bet         int    `json:"bet"`
+	Winnings    int    `json:"winnings"` // Paid back to the player, including any returned stake
+	Net         int    `json:"net"`      // Change to the player's balance over the round
+	PlayerScore int    `json:"playerScore"`
+
+	playerIndex int  // Index of the seat in Players, for paying out
+	paidOnDeal  bool // Side bets are paid as soon as the cards are dealt
+}
+
+// SettleResults returns the result of every hand played in a completed round,
+// plus a result for each bonus and side bet. It is the single source for both paying
+// players and recording results, so the two can't disagree.
+func (g *BlackjackGame) SettleResults() []PlayerResult {
+	var results []PlayerResult
+	for i, player := range g.Players {
+		if player.SitsOutRound() {
+			continue
+		}
+
+		// Each hand is paid on its own; losing hands return nothing since
+		// the bet was already taken
+		hands := player.PlayedHands()
+		for h, hand := range hands {
+			outcome, winnings := g.HandResult(hand)
+			results = append(results, PlayerResult{
+				PlayerID:    player.ID,
+				Seat:        player.Seat,
+				HandIndex:   h,
+				Outcome:     outcome,
+				Bet:         hand.Bet,
+				Winnings:    winnings,
+				Net:         winnings - hand.Bet,
+				PlayerScore: hand.Score,
+				playerIndex: i,
+			})
+		}
+
+		// House-funded bonus bets are settled against the hand they were
+		// paid on and never return a stake
+		if player.BonusBet > 0 {
+			winnings := g.BonusWinnings(player)
+			results = append(results, PlayerResult{
+				PlayerID:    player.ID,
+				Seat:        player.Seat,
+				HandIndex:   0,
+				Outcome:     "bonus",
+				Bet:         player.BonusBet,
+				Winnings:    winnings,
+				Net:         winnings,
+				PlayerScore: hands[0].Score,
+				playerIndex: i,
+			})
+		}
+
+		// Side bets were settled on the deal but still count towards the
+		// round's results
+		for _, sb := range player.SideBets {
+			if !sb.Settled {
+				continue
+			}
+			results = append(results, PlayerResult{
+				PlayerID:    player.ID,
+				Seat:        player.Seat,
+				HandIndex:   0,
+				Outcome:     string(sb.Type),
+				Bet:         sb.Amount,
+				Winnings:    sb.Winnings,
+				Net:         sb.Winnings - sb.Amount,
+				PlayerScore: hands[0].Score,
+				playerIndex: i,
+				paidOnDeal:  true,
+			})
+		}
+	}
+	return results
+}
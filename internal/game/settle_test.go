@@ -0,0 +1,54 @@
+package game
+
+import "testing"
+
+func TestSettleResultsMatchBalances(t *testing.T) {
+	g := newTestGame("p1", "p2", "p3", "p4")
+	g.OpenBetting()
+	for _, p := range g.Players {
+		if err := g.PlaceBet(p.ID, 10); err != nil {
+			t.Fatalf("PlaceBet(%s): %v", p.ID, err)
+		}
+	}
+	if err := g.PlaceSideBet("p2", SideBetPerfectPairs, 5); err != nil {
+		t.Fatalf("PlaceSideBet: %v", err)
+	}
+
+	// 19 wins, a natural, 18 pushes and 16 loses against the dealer's 18
+	stackDeck(t, g, "10H", "9H", "AS", "KS", "10D", "8D", "10C", "6C", "10S", "8S")
+	if err := g.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	for _, id := range []string{"p1", "p3", "p4"} {
+		if !g.Stand(id) {
+			t.Fatalf("Stand(%s) failed", id)
+		}
+	}
+	if g.Status != Completed {
+		t.Fatalf("status = %s, want the round completed", g.Status)
+	}
+
+	wantOutcomes := map[string]string{"p1": "win", "p2": "blackjack", "p3": "push", "p4": "lose"}
+	net := make(map[string]int)
+	for _, result := range g.SettleResults() {
+		net[result.PlayerID] += result.Net
+		if result.Outcome == string(SideBetPerfectPairs) {
+			continue
+		}
+		if result.Outcome != wantOutcomes[result.PlayerID] {
+			t.Errorf("%s: %s, want %s", result.PlayerID, result.Outcome, wantOutcomes[result.PlayerID])
+		}
+		if result.Net != result.Winnings-result.Bet {
+			t.Errorf("%s: net %d from winnings %d on %d", result.PlayerID, result.Net, result.Winnings, result.Bet)
+		}
+	}
+
+	for _, p := range g.Players {
+		if p.Balance-1000 != net[p.ID] {
+			t.Errorf("%s's balance moved by %d, the results say %d", p.ID, p.Balance-1000, net[p.ID])
+		}
+		if g.RoundNet(p) != net[p.ID] {
+			t.Errorf("%s: RoundNet = %d, the results say %d", p.ID, g.RoundNet(p), net[p.ID])
+		}
+	}
+}
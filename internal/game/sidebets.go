@@ -0,0 +1,254 @@
+package game
+
+import (
+	"errors"
+	"sort"
+)
+
+// SideBetType identifies a side bet
+type SideBetType string
+
+const (
+	SideBetPerfectPairs SideBetType = "perfectPairs" // The player's first two cards form a pair
+	SideBet21Plus3      SideBetType = "21+3"         // The player's first two cards and the dealer's up card form a poker hand
+)
+
+// Side bet errors
+var (
+	ErrUnknownSideBet    = errors.New("unknown side bet")
+	ErrSideBetNotAllowed = errors.New("side bets can only be placed during betting, after the main bet")
+	ErrSideBetPlaced     = errors.New("side bet already placed")
+	ErrSideBetAmount     = errors.New("side bet amount is outside the table limits or not covered by the balance")
+)
+
+// SideBet is a side bet a player placed for the round. It is settled as soon
+// as the cards are dealt.
+type SideBet struct {
+	Type     SideBetType `json:"type"`
+	Amount   int         `json:"amount"`
+	Settled  bool        `json:"settled"`
+	Outcome  string      `json:"outcome,omitempty"` // Winning hand (e.g. "coloredPair", "flush"), or "lose"
+	Winnings int         `json:"winnings"`          // Paid back to the player, including the stake
+}
+
+// SideBetPayouts holds the side bet payouts, each as x to 1
+type SideBetPayouts struct {
+	PerfectPair int `json:"perfectPair"` // Same rank and suit
+	ColoredPair int `json:"coloredPair"` // Same rank and color
+	MixedPair   int `json:"mixedPair"`   // Same rank
+
+	SuitedTrips   int `json:"suitedTrips"`   // Three of a kind in one suit
+	StraightFlush int `json:"straightFlush"` // Consecutive ranks in one suit
+	ThreeOfAKind  int `json:"threeOfAKind"`  // Three of the same rank
+	Straight      int `json:"straight"`      // Consecutive ranks, ace high or low
+	Flush         int `json:"flush"`         // Three of one suit
+}
+
+// DefaultSideBetPayouts are the commonly offered side bet payouts
+var DefaultSideBetPayouts = SideBetPayouts{
+	PerfectPair: 25,
+	ColoredPair: 12,
+	MixedPair:   6,
+
+	SuitedTrips:   100,
+	StraightFlush: 40,
+	ThreeOfAKind:  30,
+	Straight:      10,
+	Flush:         5,
+}
+
+// WithDefaults fills any unset payout from the given base table
+func (p SideBetPayouts) WithDefaults(base SideBetPayouts) SideBetPayouts {
+	fill := func(v *int, def int) {
+		if *v <= 0 {
+			*v = def
+		}
+	}
+	fill(&p.PerfectPair, base.PerfectPair)
+	fill(&p.ColoredPair, base.ColoredPair)
+	fill(&p.MixedPair, base.MixedPair)
+	fill(&p.SuitedTrips, base.SuitedTrips)
+	fill(&p.StraightFlush, base.StraightFlush)
+	fill(&p.ThreeOfAKind, base.ThreeOfAKind)
+	fill(&p.Straight, base.Straight)
+	fill(&p.Flush, base.Flush)
+	return p
+}
+
+// best returns the highest payout on offer
+func (p SideBetPayouts) best() int {
+	return max(p.PerfectPair, p.ColoredPair, p.MixedPair, p.SuitedTrips, p.StraightFlush, p.ThreeOfAKind, p.Straight, p.Flush)
+}
+
+// PlaceSideBet places a side bet for the round. Side bets are taken during
+// betting from players who have placed their main bet, one of each type.
+func (g *BlackjackGame) PlaceSideBet(playerID string, betType SideBetType, amount int) error {
+	if betType != SideBetPerfectPairs && betType != SideBet21Plus3 {
+		return ErrUnknownSideBet
+	}
+	if g.Status != Betting {
+		return ErrSideBetNotAllowed
+	}
+
+	for i, p := range g.Players {
+		if p.ID != playerID {
+			continue
+		}
+
+		if p.Bet == 0 {
+			return ErrSideBetNotAllowed
+		}
+		for _, sb := range p.SideBets {
+			if sb.Type == betType {
+				return ErrSideBetPlaced
+			}
+		}
+		if amount <= 0 || amount > g.MaxBet || amount > p.Balance {
+			return ErrSideBetAmount
+		}
+
+		g.adjustBalance(i, -amount)
+		g.Players[i].SideBets = append(g.Players[i].SideBets, SideBet{Type: betType, Amount: amount})
+		g.record(Action{Type: ActionSideBet, PlayerID: playerID, SideBet: betType, Amount: amount})
+		return nil
+	}
+	return ErrSideBetNotAllowed
+}
+
+// refundSideBets returns a player's unsettled side bets to their balance
+func (g *BlackjackGame) refundSideBets(i int) {
+	for _, sb := range g.Players[i].SideBets {
+		if !sb.Settled {
+			g.adjustBalance(i, sb.Amount)
+		}
+	}
+	g.Players[i].SideBets = nil
+}
+
+// settleSideBets pays out every side bet once the initial cards are dealt
+func (g *BlackjackGame) settleSideBets() {
+	if len(g.Dealer.Hand) == 0 {
+		return
+	}
+	payouts := g.EffectivePayouts()
+	upCard := g.Dealer.Hand[0]
+
+	for i := range g.Players {
+		p := &g.Players[i]
+		if len(p.Hand) < 2 {
+			continue
+		}
+
+		for s := range p.SideBets {
+			sb := &p.SideBets[s]
+			if sb.Settled {
+				continue
+			}
+
+			var outcome string
+			var ratio int
+			switch sb.Type {
+			case SideBetPerfectPairs:
+				outcome, ratio = perfectPairs(p.Hand[0], p.Hand[1], payouts.SideBets)
+			case SideBet21Plus3:
+				outcome, ratio = twentyOnePlusThree(p.Hand[0], p.Hand[1], upCard, payouts.SideBets)
+			}
+
+			sb.Settled = true
+			sb.Outcome = "lose"
+			if outcome != "" {
+				sb.Outcome = outcome
+				sb.Winnings = payouts.SideBetWinnings(sb.Amount, ratio)
+				g.adjustBalance(i, sb.Winnings)
+			}
+		}
+	}
+}
+
+// perfectPairs returns the pair the two cards form and its payout, or an
+// empty outcome if they aren't a pair
+func perfectPairs(a, b Card, payouts SideBetPayouts) (string, int) {
+	switch {
+	case a.Rank != b.Rank:
+		return "", 0
+	case a.Suit == b.Suit:
+		return "perfectPair", payouts.PerfectPair
+	case suitColor(a.Suit) == suitColor(b.Suit):
+		return "coloredPair", payouts.ColoredPair
+	default:
+		return "mixedPair", payouts.MixedPair
+	}
+}
+
+// twentyOnePlusThree returns the poker hand the three cards form and its
+// payout, or an empty outcome if they don't form one
+func twentyOnePlusThree(a, b, c Card, payouts SideBetPayouts) (string, int) {
+	flush := a.Suit == b.Suit && b.Suit == c.Suit
+	trips := a.Rank == b.Rank && b.Rank == c.Rank
+	straight := isStraight(a.Rank, b.Rank, c.Rank)
+
+	switch {
+	case trips && flush:
+		return "suitedTrips", payouts.SuitedTrips
+	case straight && flush:
+		return "straightFlush", payouts.StraightFlush
+	case trips:
+		return "threeOfAKind", payouts.ThreeOfAKind
+	case straight:
+		return "straight", payouts.Straight
+	case flush:
+		return "flush", payouts.Flush
+	default:
+		return "", 0
+	}
+}
+
+// isStraight reports whether three ranks are consecutive, with the ace
+// counting high or low
+func isStraight(ranks ...Rank) bool {
+	order := make([]int, len(ranks))
+	for i, r := range ranks {
+		order[i] = rankOrder(r)
+	}
+	sort.Ints(order)
+
+	consecutive := func(o []int) bool {
+		for i := 1; i < len(o); i++ {
+			if o[i] != o[i-1]+1 {
+				return false
+			}
+		}
+		return true
+	}
+	if consecutive(order) {
+		return true
+	}
+
+	// Ace low: A-2-3
+	if order[len(order)-1] == rankOrder(Ace) {
+		low := append([]int{1}, order[:len(order)-1]...)
+		return consecutive(low)
+	}
+	return false
+}
+
+// rankOrder returns a rank's position for poker hands, with the ace high
+func rankOrder(r Rank) int {
+	for i, rank := range allRanks {
+		if rank == r {
+			if r == Ace {
+				return 14
+			}
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// suitColor returns "red" or "black"
+func suitColor(s Suit) string {
+	if s == Hearts || s == Diamonds {
+		return "red"
+	}
+	return "black"
+}
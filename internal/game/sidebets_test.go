@@ -0,0 +1,120 @@
+package game
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPerfectPairs(t *testing.T) {
+	tests := []struct {
+		hand  []string
+		want  string
+		ratio int
+	}{
+		{[]string{"8H", "8H"}, "perfectPair", 25},
+		{[]string{"8H", "8D"}, "coloredPair", 12},
+		{[]string{"8H", "8S"}, "mixedPair", 6},
+		{[]string{"8H", "9H"}, "", 0},
+	}
+
+	for _, tt := range tests {
+		c := cards(t, tt.hand...)
+		outcome, ratio := perfectPairs(c[0], c[1], DefaultSideBetPayouts)
+		if outcome != tt.want || ratio != tt.ratio {
+			t.Errorf("perfectPairs(%v) = %q, %d, want %q, %d", tt.hand, outcome, ratio, tt.want, tt.ratio)
+		}
+	}
+}
+
+func TestTwentyOnePlusThree(t *testing.T) {
+	tests := []struct {
+		hand  []string
+		want  string
+		ratio int
+	}{
+		{[]string{"2H", "9H", "KH"}, "flush", 5},
+		{[]string{"9H", "10S", "JD"}, "straight", 10},
+		{[]string{"AH", "2S", "3D"}, "straight", 10},
+		{[]string{"QH", "KS", "AD"}, "straight", 10},
+		{[]string{"KH", "AS", "2D"}, "", 0},
+		{[]string{"9C", "10C", "JC"}, "straightFlush", 40},
+		{[]string{"7H", "7S", "7D"}, "threeOfAKind", 30},
+		{[]string{"7H", "7H", "7H"}, "suitedTrips", 100},
+		{[]string{"2H", "9S", "KH"}, "", 0},
+	}
+
+	for _, tt := range tests {
+		c := cards(t, tt.hand...)
+		outcome, ratio := twentyOnePlusThree(c[0], c[1], c[2], DefaultSideBetPayouts)
+		if outcome != tt.want || ratio != tt.ratio {
+			t.Errorf("twentyOnePlusThree(%v) = %q, %d, want %q, %d", tt.hand, outcome, ratio, tt.want, tt.ratio)
+		}
+	}
+}
+
+func TestSideBetsSettledOnDeal(t *testing.T) {
+	tests := []struct {
+		name        string
+		deal        []string
+		betType     SideBetType
+		wantOutcome string
+		wantBalance int
+	}{
+		// Balances are after the stakes of 10 and 5, before the main bet settles
+		{"colored pair", []string{"8H", "8D", "10S", "7C"}, SideBetPerfectPairs, "coloredPair", 985 + 65},
+		{"flush", []string{"2H", "9H", "KH", "7C"}, SideBet21Plus3, "flush", 985 + 30},
+		{"straight", []string{"9H", "10S", "JD", "7C"}, SideBet21Plus3, "straight", 985 + 55},
+		{"no hand", []string{"2H", "9S", "KH", "7C"}, SideBet21Plus3, "lose", 985},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame("p1")
+			g.OpenBetting()
+			g.PlaceBet("p1", 10)
+			if err := g.PlaceSideBet("p1", tt.betType, 5); err != nil {
+				t.Fatalf("PlaceSideBet: %v", err)
+			}
+			stackDeck(t, g, tt.deal...)
+			if err := g.Start(); err != nil {
+				t.Fatalf("Start: %v", err)
+			}
+
+			p := g.Players[0]
+			if len(p.SideBets) != 1 || !p.SideBets[0].Settled || p.SideBets[0].Outcome != tt.wantOutcome {
+				t.Fatalf("side bets = %+v, want one settled as %s", p.SideBets, tt.wantOutcome)
+			}
+			if p.Balance != tt.wantBalance {
+				t.Errorf("balance = %d, want %d", p.Balance, tt.wantBalance)
+			}
+		})
+	}
+}
+
+func TestPlaceSideBetRefused(t *testing.T) {
+	g := newTestGame("p1", "p2")
+	g.OpenBetting()
+	g.PlaceBet("p1", 10)
+	if err := g.PlaceSideBet("p1", SideBetPerfectPairs, 5); err != nil {
+		t.Fatalf("PlaceSideBet: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		playerID string
+		betType  SideBetType
+		amount   int
+		want     error
+	}{
+		{"unknown bet", "p1", "insurance", 5, ErrUnknownSideBet},
+		{"no main bet", "p2", SideBet21Plus3, 5, ErrSideBetNotAllowed},
+		{"placed twice", "p1", SideBetPerfectPairs, 5, ErrSideBetPlaced},
+		{"over the table maximum", "p1", SideBet21Plus3, 501, ErrSideBetAmount},
+		{"nothing staked", "p1", SideBet21Plus3, 0, ErrSideBetAmount},
+	}
+	for _, tt := range tests {
+		if err := g.PlaceSideBet(tt.playerID, tt.betType, tt.amount); !errors.Is(err, tt.want) {
+			t.Errorf("%s: PlaceSideBet = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
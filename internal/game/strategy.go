@@ -0,0 +1,185 @@
+package game
+
+// Play is a move recommended by basic strategy
+type Play string
+
+const (
+	PlayHit       Play = "hit"
+	PlayStand     Play = "stand"
+	PlayDouble    Play = "double"
+	PlaySplit     Play = "split"
+	PlaySurrender Play = "surrender"
+)
+
+// playOptions are the moves open to a hand besides hitting and standing
+type playOptions struct {
+	double    bool
+	split     bool
+	surrender bool
+}
+
+// BasicStrategy returns the textbook multi-deck basic strategy play for a
+// hand against the dealer's up card. Doubling and surrendering are only
+// recommended on the first two cards, surrendering only when the rules
+// allow it and splitting only on a pair while rules allow a split; otherwise
+// the next best play is returned. Cards count at their standard values.
+func BasicStrategy(playerHand []Card, dealerUp Card, rules RuleSet) Play {
+	twoCards := len(playerHand) == 2
+	return basicStrategy(playerHand, dealerUp, rules, playOptions{
+		double:    twoCards,
+		split:     twoCards && playerHand[0].Rank == playerHand[1].Rank && rules.MaxSplits > 0,
+		surrender: twoCards && rules.SurrenderAllowed,
+	})
+}
+
+// Advice returns the basic strategy play for a player's active hand. It
+// returns false if the player isn't the one to act.
+func (g *BlackjackGame) Advice(playerID string) (Play, bool) {
+	if g.Status != InProgress || len(g.Dealer.Hand) == 0 {
+		return "", false
+	}
+
+	for i, p := range g.Players {
+		if p.ID != playerID || i != g.CurrentPlayerIndex {
+			continue
+		}
+		if !p.IsActive || p.Status != PlayerActive {
+			return "", false
+		}
+
+		// A split player plays each hand for its own bet, which they need
+		// to be able to match to double or split again
+		rules := g.Rules()
+		stake := p.Bet
+		if p.IsSplit() {
+			stake = p.Hands[p.ActiveHand].Bet
+		}
+		twoCards := len(p.Hand) == 2
+		options := playOptions{
+			double:    twoCards && p.Balance >= stake && (!p.IsSplit() || rules.DoubleAfterSplit),
+			split:     twoCards && p.Hand[0].Rank == p.Hand[1].Rank && g.splitsLeft(p) > 0 && p.Balance >= stake,
+			surrender: twoCards && rules.SurrenderAllowed && !p.IsSplit(),
+		}
+
+		return basicStrategy(p.Hand, g.Dealer.Hand[0], rules, options), true
+	}
+	return "", false
+}
+
+// basicStrategy looks the hand up in the pair, soft and hard total tables
+func basicStrategy(hand []Card, up Card, rules RuleSet, options playOptions) Play {
+	dealer := CardValue(up, nil)
+
+	// Doubles fall back to hitting, or to standing on a soft 18 or 19
+	double := func(otherwise Play) Play {
+		if options.double {
+			return PlayDouble
+		}
+		return otherwise
+	}
+
+	if options.split && splitPair(hand[0].Rank, dealer, rules) {
+		return PlaySplit
+	}
+
+	total, soft := scoreHand(hand, nil)
+
+	if options.surrender && !soft && surrenderHand(total, dealer, rules) {
+		return PlaySurrender
+	}
+
+	if soft {
+		switch {
+		case total >= 20:
+			return PlayStand
+		case total == 19:
+			if dealer == 6 && rules.DealerHitsSoft17 {
+				return double(PlayStand)
+			}
+			return PlayStand
+		case total == 18:
+			if dealer <= 6 && (dealer >= 3 || rules.DealerHitsSoft17) {
+				return double(PlayStand)
+			}
+			if dealer <= 8 {
+				return PlayStand
+			}
+			return PlayHit
+		case total == 17:
+			if dealer >= 3 && dealer <= 6 {
+				return double(PlayHit)
+			}
+		case total >= 15:
+			if dealer >= 4 && dealer <= 6 {
+				return double(PlayHit)
+			}
+		case total >= 13:
+			if dealer >= 5 && dealer <= 6 {
+				return double(PlayHit)
+			}
+		}
+		return PlayHit
+	}
+
+	switch {
+	case total >= 17:
+		return PlayStand
+	case total >= 13:
+		if dealer <= 6 {
+			return PlayStand
+		}
+	case total == 12:
+		if dealer >= 4 && dealer <= 6 {
+			return PlayStand
+		}
+	case total == 11:
+		if dealer <= 10 || rules.DealerHitsSoft17 {
+			return double(PlayHit)
+		}
+	case total == 10:
+		if dealer <= 9 {
+			return double(PlayHit)
+		}
+	case total == 9:
+		if dealer >= 3 && dealer <= 6 {
+			return double(PlayHit)
+		}
+	}
+	return PlayHit
+}
+
+// splitPair reports whether a pair of rank should be split against the
+// dealer's up card value
+func splitPair(rank Rank, dealer int, rules RuleSet) bool {
+	switch rank {
+	case Ace, Eight:
+		return true
+	case Nine:
+		return dealer <= 9 && dealer != 7
+	case Seven:
+		return dealer <= 7
+	case Six:
+		return dealer <= 6 && (dealer >= 3 || rules.DoubleAfterSplit)
+	case Four:
+		return rules.DoubleAfterSplit && (dealer == 5 || dealer == 6)
+	case Two, Three:
+		return dealer <= 7 && (dealer >= 4 || rules.DoubleAfterSplit)
+	}
+
+	// Fives and tens are played as totals
+	return false
+}
+
+// surrenderHand reports whether a hard total should be surrendered against
+// the dealer's up card value
+func surrenderHand(total, dealer int, rules RuleSet) bool {
+	switch total {
+	case 17:
+		return rules.DealerHitsSoft17 && dealer == 11
+	case 16:
+		return dealer >= 9
+	case 15:
+		return dealer == 10 || (rules.DealerHitsSoft17 && dealer == 11)
+	}
+	return false
+}
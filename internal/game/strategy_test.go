@@ -0,0 +1,84 @@
+package game
+
+import "testing"
+
+func TestBasicStrategy(t *testing.T) {
+	h17 := DefaultRuleSet
+	h17.DealerHitsSoft17 = true
+	surrender := DefaultRuleSet
+	surrender.SurrenderAllowed = true
+	noDAS := DefaultRuleSet
+	noDAS.DoubleAfterSplit = false
+	noSplits := DefaultRuleSet
+	noSplits.MaxSplits = 0
+
+	tests := []struct {
+		name  string
+		hand  []string
+		up    string
+		rules RuleSet
+		want  Play
+	}{
+		{"hard 16 v 10", []string{"10H", "6D"}, "KS", DefaultRuleSet, PlayHit},
+		{"hard 16 v 6", []string{"10H", "6D"}, "6S", DefaultRuleSet, PlayStand},
+		{"hard 12 v 3", []string{"10H", "2D"}, "3S", DefaultRuleSet, PlayHit},
+		{"hard 12 v 4", []string{"10H", "2D"}, "4S", DefaultRuleSet, PlayStand},
+		{"hard 17 v ace", []string{"10H", "7D"}, "AS", DefaultRuleSet, PlayStand},
+		{"11 v 10", []string{"6H", "5D"}, "10S", DefaultRuleSet, PlayDouble},
+		{"11 v ace", []string{"6H", "5D"}, "AS", DefaultRuleSet, PlayHit},
+		{"11 v ace, dealer hits soft 17", []string{"6H", "5D"}, "AS", h17, PlayDouble},
+		{"10 v 10", []string{"6H", "4D"}, "10S", DefaultRuleSet, PlayHit},
+		{"9 v 3", []string{"5H", "4D"}, "3S", DefaultRuleSet, PlayDouble},
+		{"three card 11 can't double", []string{"2H", "4D", "5C"}, "6S", DefaultRuleSet, PlayHit},
+		{"A-7 v 6", []string{"AH", "7D"}, "6S", DefaultRuleSet, PlayDouble},
+		{"A-7 v 7", []string{"AH", "7D"}, "7S", DefaultRuleSet, PlayStand},
+		{"A-7 v 9", []string{"AH", "7D"}, "9S", DefaultRuleSet, PlayHit},
+		{"A-7 v 2, dealer hits soft 17", []string{"AH", "7D"}, "2S", h17, PlayDouble},
+		{"three card soft 18 v 6", []string{"AH", "3D", "4C"}, "6S", DefaultRuleSet, PlayStand},
+		{"A-2 v 5", []string{"AH", "2D"}, "5S", DefaultRuleSet, PlayDouble},
+		{"A-8 v 6", []string{"AH", "8D"}, "6S", DefaultRuleSet, PlayStand},
+		{"A-8 v 6, dealer hits soft 17", []string{"AH", "8D"}, "6S", h17, PlayDouble},
+		{"aces", []string{"AH", "AD"}, "10S", DefaultRuleSet, PlaySplit},
+		{"eights v ace", []string{"8H", "8D"}, "AS", DefaultRuleSet, PlaySplit},
+		{"eights without splitting", []string{"8H", "8D"}, "6S", noSplits, PlayStand},
+		{"nines v 7", []string{"9H", "9D"}, "7S", DefaultRuleSet, PlayStand},
+		{"tens v 6", []string{"10H", "KD"}, "6S", DefaultRuleSet, PlayStand},
+		{"fives v 6", []string{"5H", "5D"}, "6S", DefaultRuleSet, PlayDouble},
+		{"twos v 2", []string{"2H", "2D"}, "2S", DefaultRuleSet, PlaySplit},
+		{"twos v 2, no double after split", []string{"2H", "2D"}, "2S", noDAS, PlayHit},
+		{"hard 16 v 10, surrender", []string{"10H", "6D"}, "10S", surrender, PlaySurrender},
+		{"hard 15 v 9, surrender", []string{"10H", "5D"}, "9S", surrender, PlayHit},
+		{"eights v 10, surrender", []string{"8H", "8D"}, "10S", surrender, PlaySplit},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := BasicStrategy(cards(t, tt.hand...), cards(t, tt.up)[0], tt.rules); got != tt.want {
+				t.Errorf("BasicStrategy(%v v %s) = %s, want %s", tt.hand, tt.up, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdvice(t *testing.T) {
+	g := newTestGame("p1", "p2")
+	dealRound(t, g, "10H", "6D", "AS", "7C", "6C", "KS")
+
+	if play, ok := g.Advice("p1"); !ok || play != PlayStand {
+		t.Errorf("p1's advice = %q, %v, want stand on 16 against a 6", play, ok)
+	}
+	if _, ok := g.Advice("p2"); ok {
+		t.Error("p2 got advice before their turn")
+	}
+
+	g.Stand("p1")
+	if play, ok := g.Advice("p2"); !ok || play != PlayDouble {
+		t.Errorf("p2's advice = %q, %v, want double on soft 18 against a 6", play, ok)
+	}
+
+	// A player who can't cover the bet again isn't told to double
+	g.Players[1].Balance = 5
+	if play, ok := g.Advice("p2"); !ok || play != PlayStand {
+		t.Errorf("p2's advice with 5 left = %q, %v, want stand", play, ok)
+	}
+}
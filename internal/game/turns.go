@@ -0,0 +1,39 @@
+package game
+
+import "time"
+
+// restartTurnClock gives the active player a fresh TurnTimeout to act. It is
+// called whenever the turn passes or the player makes a move, and clears the
+// deadline once no player is acting.
+func (g *BlackjackGame) restartTurnClock() {
+	if g.TurnTimeout <= 0 || g.Status != InProgress {
+		g.TurnDeadline = time.Time{}
+		return
+	}
+	g.TurnDeadline = time.Now().Add(g.TurnTimeout)
+}
+
+// ExpireTurn auto-stands the active player once their turn deadline has
+// passed, returning true if the game changed. A split player stands on every
+// hand they have left.
+func (g *BlackjackGame) ExpireTurn(now time.Time) bool {
+	if g.Status != InProgress || g.TurnDeadline.IsZero() || now.Before(g.TurnDeadline) {
+		return false
+	}
+
+	i := g.CurrentPlayerIndex
+	if i < 0 || i >= len(g.Players) {
+		g.TurnDeadline = time.Time{}
+		return true
+	}
+
+	playerID := g.Players[i].ID
+	for g.Status == InProgress && g.CurrentPlayerIndex == i && g.Players[i].Status == PlayerActive {
+		if !g.Stand(playerID) {
+			// Nothing left to stand on, so don't keep expiring the turn
+			g.TurnDeadline = time.Time{}
+			break
+		}
+	}
+	return true
+}
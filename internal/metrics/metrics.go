@@ -0,0 +1,93 @@
+// Package metrics holds the server's Prometheus collectors
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry holds every collector exposed on /metrics
+var Registry = prometheus.NewRegistry()
+
+var (
+	// GamesCreated counts games created through the API
+	GamesCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "blackjack_games_created_total",
+		Help: "Games created.",
+	})
+
+	// Actions counts successful player actions by type (hit, stand, bet, ...)
+	Actions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "blackjack_actions_total",
+		Help: "Successful player actions by type.",
+	}, []string{"action"})
+
+	// ActiveGames is the number of games that aren't completed, as of the
+	// last table sweep
+	ActiveGames = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "blackjack_active_games",
+		Help: "Games that are not completed.",
+	})
+
+	// RequestDuration observes HTTP request latency by route and status
+	RequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by route, method and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+)
+
+func init() {
+	Registry.MustRegister(
+		GamesCreated,
+		Actions,
+		ActiveGames,
+		RequestDuration,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler serves the registered metrics
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// ObserveRequest records how long a request to route took
+func ObserveRequest(route, method string, status int, d time.Duration) {
+	RequestDuration.WithLabelValues(route, method, strconv.Itoa(status)).Observe(d.Seconds())
+}
+
+// RouteName returns the matched route's path template, so requests for
+// different games share one label
+func RouteName(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tmpl, err := route.GetPathTemplate(); err == nil {
+			return tmpl
+		}
+	}
+	return "unmatched"
+}
+
+// StatusRecorder remembers the status code written through it
+type StatusRecorder struct {
+	http.ResponseWriter
+	Status int
+}
+
+// NewStatusRecorder wraps w, assuming 200 until a status is written
+func NewStatusRecorder(w http.ResponseWriter) *StatusRecorder {
+	return &StatusRecorder{ResponseWriter: w, Status: http.StatusOK}
+}
+
+// WriteHeader records the status and passes it on
+func (s *StatusRecorder) WriteHeader(status int) {
+	s.Status = status
+	s.ResponseWriter.WriteHeader(status)
+}
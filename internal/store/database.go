@@ -1,6 +1,8 @@
 package store
 
 import (
+	"time"
+
 	"github.com/calvinwijaya/card-games-be/internal/db"
 	"github.com/calvinwijaya/card-games-be/internal/game"
 )
@@ -17,7 +19,10 @@ func NewDatabaseStore(database *db.Database) *DatabaseStore {
 	}
 }
 
-// SaveGame saves a game to the database
+// SaveGame saves a game to the database. Games are upserted by ID, so saving
+// the same game again replaces it rather than adding another entry. A game
+// saved by someone else since it was loaded isn't replaced;
+// game.ErrStaleVersion is returned instead.
 func (s *DatabaseStore) SaveGame(g *game.BlackjackGame) error {
 	return s.db.SaveGame(g)
 }
@@ -32,11 +37,27 @@ func (s *DatabaseStore) GetTableGames(tableID string) ([]*game.BlackjackGame, er
 	return s.db.GetTableGames(tableID)
 }
 
-// GetActiveTableGame retrieves the active game for a table
+// GetActiveTableGame retrieves the most recently updated active game for a table
 func (s *DatabaseStore) GetActiveTableGame(tableID string) (*game.BlackjackGame, error) {
 	return s.db.GetActiveTableGame(tableID)
 }
 
+// GetActiveGames retrieves the most recently updated active game of every
+// table
+func (s *DatabaseStore) GetActiveGames() ([]*game.BlackjackGame, error) {
+	return s.db.GetActiveGames()
+}
+
+// JoinGame atomically seats a player in a game
+func (s *DatabaseStore) JoinGame(gameID string, player game.Player) (*game.BlackjackGame, error) {
+	return s.db.JoinGame(gameID, player)
+}
+
+// TransitionStatus atomically moves a game from one status to another
+func (s *DatabaseStore) TransitionStatus(gameID string, from, to game.GameStatus) (bool, error) {
+	return s.db.TransitionStatus(gameID, from, to)
+}
+
 // DeleteGame removes a game from the database
 func (s *DatabaseStore) DeleteGame(id string) error {
 	return s.db.DeleteGame(id)
@@ -46,3 +67,9 @@ func (s *DatabaseStore) DeleteGame(id string) error {
 func (s *DatabaseStore) GetAllGames() ([]*game.BlackjackGame, error) {
 	return s.db.GetAllGames()
 }
+
+// DeleteStaleGames removes waiting and completed games that haven't been
+// updated for longer than olderThan. Games with recorded results are kept.
+func (s *DatabaseStore) DeleteStaleGames(olderThan time.Duration) (int, error) {
+	return s.db.DeleteStaleGames(time.Now().Add(-olderThan))
+}
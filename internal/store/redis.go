@@ -0,0 +1,370 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/calvinwijaya/card-games-be/internal/db"
+	"github.com/calvinwijaya/card-games-be/internal/game"
+	"github.com/redis/go-redis/v9"
+)
+
+// maxTxRetries is how many times an optimistic transaction is retried when
+// another instance changes the game in between
+const maxTxRetries = 10
+
+// RedisStore is a Redis implementation of game storage, shared by every
+// server instance that points at the same Redis.
+//
+// Keys:
+//
+//	game:{id}               the game's JSON
+//	table:{id}              sorted set of the table's game IDs, scored by UpdatedAt
+//	table:{id}:active       the same, for games that aren't completed
+//	games                   set of every game ID
+type RedisStore struct {
+	client *redis.Client
+}
+
+// NewRedisStore connects to the Redis server at addr
+func NewRedisStore(addr string) (*RedisStore, error) {
+	client := redis.NewClient(&redis.Options{Addr: addr})
+
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return &RedisStore{client: client}, nil
+}
+
+// Close closes the connection to Redis
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
+
+func gameKey(id string) string {
+	return "game:" + id
+}
+
+func tableKey(tableID string) string {
+	return "table:" + tableID
+}
+
+func activeTableKey(tableID string) string {
+	return "table:" + tableID + ":active"
+}
+
+const allGamesKey = "games"
+
+// writeGame queues the commands that store a game and update its indexes
+func writeGame(ctx context.Context, pipe redis.Pipeliner, g *game.BlackjackGame, data []byte) {
+	member := redis.Z{Score: float64(g.UpdatedAt.UnixMilli()), Member: g.ID}
+
+	pipe.Set(ctx, gameKey(g.ID), data, 0)
+	pipe.SAdd(ctx, allGamesKey, g.ID)
+	pipe.ZAdd(ctx, tableKey(g.TableID), member)
+	if g.Status == game.Completed {
+		pipe.ZRem(ctx, activeTableKey(g.TableID), g.ID)
+	} else {
+		pipe.ZAdd(ctx, activeTableKey(g.TableID), member)
+	}
+}
+
+// SaveGame saves a game to Redis, bumping its version. Saving the same game
+// again replaces it, unless someone else saved it since it was loaded, in
+// which case game.ErrStaleVersion is returned.
+func (s *RedisStore) SaveGame(g *game.BlackjackGame) error {
+	ctx := context.Background()
+	expected := g.Version
+
+	txf := func(tx *redis.Tx) error {
+		stored, err := tx.Get(ctx, gameKey(g.ID)).Bytes()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+
+		// A game that isn't stored yet is at version 0
+		var current struct {
+			Version int `json:"version"`
+		}
+		if err == nil {
+			if err := json.Unmarshal(stored, &current); err != nil {
+				return err
+			}
+		}
+		if current.Version != expected {
+			return game.ErrStaleVersion
+		}
+
+		g.Version = expected + 1
+		data, err := json.Marshal(g)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			writeGame(ctx, pipe, g, data)
+			return nil
+		})
+		return err
+	}
+
+	err := s.client.Watch(ctx, txf, gameKey(g.ID))
+	if err != nil {
+		g.Version = expected
+	}
+	if err == redis.TxFailedErr {
+		// The game changed while it was being saved
+		return game.ErrStaleVersion
+	}
+	return err
+}
+
+// GetGame retrieves a game by ID
+func (s *RedisStore) GetGame(id string) (*game.BlackjackGame, error) {
+	return s.getGame(context.Background(), s.client, id)
+}
+
+// getGame loads a game through the given client or transaction
+func (s *RedisStore) getGame(ctx context.Context, c redis.Cmdable, id string) (*game.BlackjackGame, error) {
+	data, err := c.Get(ctx, gameKey(id)).Bytes()
+	if err == redis.Nil {
+		return nil, db.ErrGameNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var g game.BlackjackGame
+	if err := json.Unmarshal(data, &g); err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+// getGames loads the games with the given IDs, skipping any that have gone
+func (s *RedisStore) getGames(ctx context.Context, ids []string) ([]*game.BlackjackGame, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = gameKey(id)
+	}
+
+	values, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	games := make([]*game.BlackjackGame, 0, len(values))
+	for _, v := range values {
+		data, ok := v.(string)
+		if !ok {
+			continue
+		}
+
+		var g game.BlackjackGame
+		if err := json.Unmarshal([]byte(data), &g); err != nil {
+			return nil, err
+		}
+		games = append(games, &g)
+	}
+	return games, nil
+}
+
+// GetTableGames retrieves all games for a table, most recently updated first
+func (s *RedisStore) GetTableGames(tableID string) ([]*game.BlackjackGame, error) {
+	ctx := context.Background()
+
+	ids, err := s.client.ZRevRange(ctx, tableKey(tableID), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	return s.getGames(ctx, ids)
+}
+
+// GetActiveTableGame retrieves the most recently updated active game for a table
+func (s *RedisStore) GetActiveTableGame(tableID string) (*game.BlackjackGame, error) {
+	ctx := context.Background()
+
+	ids, err := s.client.ZRevRange(ctx, activeTableKey(tableID), 0, 0).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, errors.New("no active game found for table")
+	}
+	return s.GetGame(ids[0])
+}
+
+// GetActiveGames retrieves the most recently updated active game of every
+// table. Every game is fetched in one round trip and filtered here, since
+// there is no index of tables to walk.
+func (s *RedisStore) GetActiveGames() ([]*game.BlackjackGame, error) {
+	games, err := s.GetAllGames()
+	if err != nil {
+		return nil, err
+	}
+
+	latest := make(map[string]*game.BlackjackGame)
+	var tables []string
+	for _, g := range games {
+		if g.Status == game.Completed {
+			continue
+		}
+
+		current, ok := latest[g.TableID]
+		if !ok {
+			tables = append(tables, g.TableID)
+		}
+		if !ok || g.UpdatedAt.After(current.UpdatedAt) {
+			latest[g.TableID] = g
+		}
+	}
+
+	active := make([]*game.BlackjackGame, len(tables))
+	for i, tableID := range tables {
+		active[i] = latest[tableID]
+	}
+	return active, nil
+}
+
+// updateGame applies change to a game in an optimistic transaction, retrying
+// if the game is modified before the write goes through. change returns
+// false to leave the game as it was.
+func (s *RedisStore) updateGame(gameID string, change func(g *game.BlackjackGame) (bool, error)) (*game.BlackjackGame, bool, error) {
+	ctx := context.Background()
+
+	var g *game.BlackjackGame
+	var changed bool
+	txf := func(tx *redis.Tx) error {
+		var err error
+		if g, err = s.getGame(ctx, tx, gameID); err != nil {
+			return err
+		}
+		if changed, err = change(g); err != nil || !changed {
+			return err
+		}
+
+		g.UpdatedAt = time.Now()
+		data, err := json.Marshal(g)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			writeGame(ctx, pipe, g, data)
+			return nil
+		})
+		return err
+	}
+
+	for i := 0; i < maxTxRetries; i++ {
+		err := s.client.Watch(ctx, txf, gameKey(gameID))
+		if err == redis.TxFailedErr {
+			continue
+		}
+		return g, changed, err
+	}
+	return nil, false, errors.New("game was modified concurrently, please retry")
+}
+
+// JoinGame atomically seats a player in a game
+func (s *RedisStore) JoinGame(gameID string, player game.Player) (*game.BlackjackGame, error) {
+	g, _, err := s.updateGame(gameID, func(g *game.BlackjackGame) (bool, error) {
+		// Players already seated can always rejoin
+		seated := false
+		for _, p := range g.Players {
+			if p.ID == player.ID {
+				seated = true
+				break
+			}
+		}
+		if !seated && g.IsFull() {
+			return false, game.ErrTableFull
+		}
+
+		if g.AddPlayer(player.ID, player.Name, player.Balance) == nil {
+			return false, errors.New("unable to join game")
+		}
+		g.Version++
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// TransitionStatus atomically moves a game from one status to another
+func (s *RedisStore) TransitionStatus(gameID string, from, to game.GameStatus) (bool, error) {
+	_, changed, err := s.updateGame(gameID, func(g *game.BlackjackGame) (bool, error) {
+		if g.Status != from {
+			return false, nil
+		}
+		g.Status = to
+		return true, nil
+	})
+	return changed, err
+}
+
+// DeleteGame removes a game from Redis
+func (s *RedisStore) DeleteGame(id string) error {
+	ctx := context.Background()
+
+	g, err := s.GetGame(id)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, gameKey(id))
+		pipe.SRem(ctx, allGamesKey, id)
+		pipe.ZRem(ctx, tableKey(g.TableID), id)
+		pipe.ZRem(ctx, activeTableKey(g.TableID), id)
+		return nil
+	})
+	return err
+}
+
+// GetAllGames returns all games in Redis
+func (s *RedisStore) GetAllGames() ([]*game.BlackjackGame, error) {
+	ctx := context.Background()
+
+	ids, err := s.client.SMembers(ctx, allGamesKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	return s.getGames(ctx, ids)
+}
+
+// DeleteStaleGames removes waiting and completed games that haven't been
+// updated for longer than olderThan
+func (s *RedisStore) DeleteStaleGames(olderThan time.Duration) (int, error) {
+	games, err := s.GetAllGames()
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+	deleted := 0
+	for _, g := range games {
+		if g.Status != game.Waiting && g.Status != game.Completed {
+			continue
+		}
+		if !g.UpdatedAt.Before(cutoff) {
+			continue
+		}
+
+		if err := s.DeleteGame(g.ID); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
@@ -0,0 +1,282 @@
+package store
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/calvinwijaya/card-games-be/internal/db"
+	"github.com/calvinwijaya/card-games-be/internal/game"
+)
+
+// newTestRedisStore connects a store to an in-memory Redis that lives as
+// long as the test
+func newTestRedisStore(t *testing.T) *RedisStore {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	s, err := NewRedisStore(server.Addr())
+	if err != nil {
+		t.Fatalf("NewRedisStore: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// saveRedisGame stores a new game at the table with the status, last
+// updated at the given time
+func saveRedisGame(t *testing.T, s *RedisStore, tableID string, status game.GameStatus, updatedAt time.Time) *game.BlackjackGame {
+	t.Helper()
+
+	g := game.NewBlackjackGame(tableID, 10, 500, 1)
+	g.Status = status
+	g.UpdatedAt = updatedAt
+	if err := s.SaveGame(g); err != nil {
+		t.Fatalf("SaveGame: %v", err)
+	}
+	return g
+}
+
+func TestRedisSaveAndLoad(t *testing.T) {
+	s := newTestRedisStore(t)
+
+	g := game.NewBlackjackGame("table-1", 10, 500, 1)
+	g.AddPlayer("p1", "Ann", 1000)
+	for i := 1; i <= 3; i++ {
+		if err := s.SaveGame(g); err != nil {
+			t.Fatalf("save %d: %v", i, err)
+		}
+		if g.Version != i {
+			t.Errorf("version after save %d = %d", i, g.Version)
+		}
+	}
+
+	loaded, err := s.GetGame(g.ID)
+	if err != nil {
+		t.Fatalf("GetGame: %v", err)
+	}
+	if loaded.TableID != "table-1" || loaded.Version != 3 || len(loaded.Players) != 1 || loaded.Players[0].Balance != 1000 {
+		t.Errorf("loaded %+v, want the saved game at version 3", loaded)
+	}
+
+	games, err := s.GetTableGames("table-1")
+	if err != nil || len(games) != 1 {
+		t.Errorf("GetTableGames = %d games, %v, want the one game", len(games), err)
+	}
+	if _, err := s.GetGame("missing"); err == nil {
+		t.Error("GetGame found a game that was never saved")
+	}
+}
+
+func TestRedisSaveStaleVersion(t *testing.T) {
+	s := newTestRedisStore(t)
+	g := saveRedisGame(t, s, "table-1", game.Betting, time.Now())
+
+	first, _ := s.GetGame(g.ID)
+	second, _ := s.GetGame(g.ID)
+	if err := s.SaveGame(first); err != nil {
+		t.Fatalf("first save: %v", err)
+	}
+
+	if err := s.SaveGame(second); !errors.Is(err, game.ErrStaleVersion) {
+		t.Fatalf("second save = %v, want %v", err, game.ErrStaleVersion)
+	}
+	if second.Version != 1 {
+		t.Errorf("refused game's version = %d, want it left at 1", second.Version)
+	}
+
+	// Reloading picks up the winning save, which can then be saved again
+	reloaded, _ := s.GetGame(g.ID)
+	if reloaded.Version != 2 {
+		t.Errorf("stored version = %d, want 2", reloaded.Version)
+	}
+	if err := s.SaveGame(reloaded); err != nil {
+		t.Errorf("save after reloading: %v", err)
+	}
+}
+
+func TestRedisConcurrentSaves(t *testing.T) {
+	s := newTestRedisStore(t)
+	g := saveRedisGame(t, s, "table-1", game.Betting, time.Now())
+
+	// Every writer loaded the same version, so only one may save it
+	const writers = 10
+	copies := make([]*game.BlackjackGame, writers)
+	for i := range copies {
+		copies[i], _ = s.GetGame(g.ID)
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	saved := 0
+	for _, c := range copies {
+		wg.Add(1)
+		go func(c *game.BlackjackGame) {
+			defer wg.Done()
+			err := s.SaveGame(c)
+			if err != nil && !errors.Is(err, game.ErrStaleVersion) {
+				t.Errorf("SaveGame: %v", err)
+			}
+			if err == nil {
+				mu.Lock()
+				saved++
+				mu.Unlock()
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	if saved != 1 {
+		t.Errorf("%d saves went through, want exactly 1", saved)
+	}
+}
+
+func TestRedisActiveGames(t *testing.T) {
+	s := newTestRedisStore(t)
+	now := time.Now()
+
+	saveRedisGame(t, s, "table-1", game.Waiting, now.Add(-2*time.Hour))
+	latest := saveRedisGame(t, s, "table-1", game.Betting, now.Add(-time.Hour))
+	saveRedisGame(t, s, "table-1", game.Completed, now)
+	only := saveRedisGame(t, s, "table-2", game.InProgress, now.Add(-3*time.Hour))
+	saveRedisGame(t, s, "table-3", game.Completed, now)
+
+	active, err := s.GetActiveTableGame("table-1")
+	if err != nil || active.ID != latest.ID {
+		t.Errorf("GetActiveTableGame = %v, %v, want %s", active, err, latest.ID)
+	}
+	if _, err := s.GetActiveTableGame("table-3"); err == nil {
+		t.Error("a table with only completed games has an active game")
+	}
+
+	games, err := s.GetActiveGames()
+	if err != nil {
+		t.Fatalf("GetActiveGames: %v", err)
+	}
+	got := make(map[string]string)
+	for _, g := range games {
+		got[g.TableID] = g.ID
+	}
+	want := map[string]string{"table-1": latest.ID, "table-2": only.ID}
+	if len(games) != len(want) || got["table-1"] != want["table-1"] || got["table-2"] != want["table-2"] {
+		t.Errorf("GetActiveGames = %v, want %v", got, want)
+	}
+
+	// Completing a game takes it out of the table's active index
+	latest.Status = game.Completed
+	if err := s.SaveGame(latest); err != nil {
+		t.Fatalf("SaveGame: %v", err)
+	}
+	if active, err := s.GetActiveTableGame("table-1"); err != nil || active.Status != game.Waiting {
+		t.Errorf("after completing: GetActiveTableGame = %v, %v, want the waiting game", active, err)
+	}
+}
+
+func TestRedisJoinGame(t *testing.T) {
+	s := newTestRedisStore(t)
+	g := game.NewBlackjackGame("table-1", 10, 500, 1)
+	g.MaxPlayers = 1
+	if err := s.SaveGame(g); err != nil {
+		t.Fatalf("SaveGame: %v", err)
+	}
+
+	joined, err := s.JoinGame(g.ID, game.Player{ID: "p1", Name: "Ann", Balance: 1000})
+	if err != nil || len(joined.Players) != 1 {
+		t.Fatalf("JoinGame = %v, %v, want p1 seated", joined, err)
+	}
+	if _, err := s.JoinGame(g.ID, game.Player{ID: "p2", Name: "Ben", Balance: 1000}); !errors.Is(err, game.ErrTableFull) {
+		t.Errorf("joining a full table = %v, want %v", err, game.ErrTableFull)
+	}
+	if _, err := s.JoinGame(g.ID, game.Player{ID: "p1", Name: "Ann", Balance: 1000}); err != nil {
+		t.Errorf("rejoining = %v, want p1 let back in", err)
+	}
+
+	// Joining bumps the version, so a copy loaded before it is stale
+	if err := s.SaveGame(g); !errors.Is(err, game.ErrStaleVersion) {
+		t.Errorf("saving a copy from before the join = %v, want %v", err, game.ErrStaleVersion)
+	}
+}
+
+func TestRedisMissingGame(t *testing.T) {
+	s := newTestRedisStore(t)
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{"get", func() error {
+			_, err := s.GetGame("missing")
+			return err
+		}},
+		{"join", func() error {
+			_, err := s.JoinGame("missing", game.Player{ID: "p1", Name: "Ann", Balance: 1000})
+			return err
+		}},
+		{"transition", func() error {
+			_, err := s.TransitionStatus("missing", game.Betting, game.InProgress)
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); !errors.Is(err, db.ErrGameNotFound) {
+				t.Errorf("err = %v, want %v", err, db.ErrGameNotFound)
+			}
+		})
+	}
+}
+
+func TestRedisTransitionStatus(t *testing.T) {
+	s := newTestRedisStore(t)
+	g := saveRedisGame(t, s, "table-1", game.Betting, time.Now())
+
+	if ok, err := s.TransitionStatus(g.ID, game.Betting, game.InProgress); !ok || err != nil {
+		t.Fatalf("first transition = %v, %v, want it applied", ok, err)
+	}
+	if ok, err := s.TransitionStatus(g.ID, game.Betting, game.InProgress); ok || err != nil {
+		t.Errorf("second transition = %v, %v, want it refused", ok, err)
+	}
+	if stored, _ := s.GetGame(g.ID); stored.Status != game.InProgress {
+		t.Errorf("stored status = %s, want %s", stored.Status, game.InProgress)
+	}
+}
+
+func TestRedisDeleteStaleGames(t *testing.T) {
+	s := newTestRedisStore(t)
+	old := time.Now().Add(-48 * time.Hour)
+
+	staleCompleted := saveRedisGame(t, s, "table-1", game.Completed, old)
+	staleWaiting := saveRedisGame(t, s, "table-2", game.Waiting, old)
+	staleInProgress := saveRedisGame(t, s, "table-3", game.InProgress, old)
+	fresh := saveRedisGame(t, s, "table-4", game.Completed, time.Now())
+
+	deleted, err := s.DeleteStaleGames(24 * time.Hour)
+	if err != nil || deleted != 2 {
+		t.Fatalf("DeleteStaleGames = %d, %v, want 2 deleted", deleted, err)
+	}
+
+	for _, tt := range []struct {
+		g    *game.BlackjackGame
+		kept bool
+	}{
+		{staleCompleted, false},
+		{staleWaiting, false},
+		{staleInProgress, true},
+		{fresh, true},
+	} {
+		if _, err := s.GetGame(tt.g.ID); (err == nil) != tt.kept {
+			t.Errorf("%s game kept = %v, want %v", tt.g.Status, err == nil, tt.kept)
+		}
+	}
+
+	// Deleted games leave the indexes too
+	if games, _ := s.GetTableGames("table-2"); len(games) != 0 {
+		t.Errorf("table-2 still lists %d games", len(games))
+	}
+	if _, err := s.GetActiveTableGame("table-2"); err == nil {
+		t.Error("table-2 still has an active game")
+	}
+}
@@ -1,10 +1,16 @@
 package store
 
-import "github.com/calvinwijaya/card-games-be/internal/game"
+import (
+	"time"
+
+	"github.com/calvinwijaya/card-games-be/internal/game"
+)
 
 // Store defines the interface for game storage
 type Store interface {
-	// SaveGame saves a game to the store
+	// SaveGame saves a game to the store and bumps its version. It returns
+	// game.ErrStaleVersion if the stored game's version no longer matches,
+	// i.e. the game was saved by someone else since it was loaded.
 	SaveGame(g *game.BlackjackGame) error
 
 	// GetGame retrieves a game by ID
@@ -16,9 +22,27 @@ type Store interface {
 	// GetActiveTableGame retrieves the active game for a table
 	GetActiveTableGame(tableID string) (*game.BlackjackGame, error)
 
+	// GetActiveGames retrieves the active game of every table, at most one
+	// per table, without a query per table
+	GetActiveGames() ([]*game.BlackjackGame, error)
+
+	// JoinGame atomically seats a player in a game, returning the updated
+	// game or game.ErrTableFull if there is no free seat. It bumps the
+	// game's version.
+	JoinGame(gameID string, player game.Player) (*game.BlackjackGame, error)
+
+	// TransitionStatus atomically moves a game from one status to another,
+	// returning false if the game is no longer in the expected status. It
+	// leaves the version alone, since the caller saves the game next.
+	TransitionStatus(gameID string, from, to game.GameStatus) (bool, error)
+
 	// DeleteGame removes a game from the store
 	DeleteGame(id string) error
 
 	// GetAllGames returns all games in the store
 	GetAllGames() ([]*game.BlackjackGame, error)
+
+	// DeleteStaleGames removes waiting and completed games that haven't been
+	// updated for longer than olderThan, returning how many were removed
+	DeleteStaleGames(olderThan time.Duration) (int, error)
 }